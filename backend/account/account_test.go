@@ -0,0 +1,233 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/auth"
+	"backend/config"
+	"backend/storage"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVerifier 令牌 "token-<id>" 有效
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(token string) (int, error) {
+	if rest, ok := strings.CutPrefix(token, "token-"); ok {
+		if id, err := strconv.Atoi(rest); err == nil {
+			return id, nil
+		}
+	}
+	return 0, errors.New("invalid token")
+}
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sessions.Sessions("test", cookie.NewStore([]byte("test-secret"))))
+	r.Use(auth.Middleware(config.Static(config.Default()), fakeVerifier{}))
+	return r
+}
+
+// serve 发送请求，token 为空时不登录
+func serve(r *gin.Engine, method, url, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// expectExport 按 exportSections 的顺序设置查询结果，只有 profile 和 likes 有数据
+func expectExport(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`FROM users WHERE id = \?`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "openid", "nickname"}).AddRow(7, "o-7", []byte("小明")))
+	mock.ExpectQuery("FROM `like`").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id", "dish_name"}).AddRow(3, []byte("宫保鸡丁")))
+	for range exportSections[2:] {
+		mock.ExpectQuery(".+").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	store := storage.NewLocal(t.TempDir(), func() string { return "http://test.com" })
+	store.Put(context.Background(), "avatar/7-abc-64.jpg", []byte("jpeg"), "image/jpeg")
+	r := newRouter()
+	r.GET("/export", ExportHandler(db, store))
+
+	// 未登录
+	w := serve(r, "GET", "/export", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// JSON
+	expectExport(mock)
+	w = serve(r, "GET", "/export?format=json", "token-7")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"nickname":"小明"`)
+	assert.Contains(t, w.Body.String(), `"dish_name":"宫保鸡丁"`)
+
+	// ZIP：每部分一个 JSON 文件，附头像
+	expectExport(mock)
+	w = serve(r, "GET", "/export", "token-7")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if assert.NoError(t, err) {
+		names := map[string]bool{}
+		for _, f := range zr.File {
+			names[f.Name] = true
+		}
+		for _, s := range exportSections {
+			assert.True(t, names[s.name+".json"], s.name)
+		}
+		assert.True(t, names["avatar/7-abc-64.jpg"])
+	}
+
+	// 用户不存在
+	mock.ExpectQuery(`FROM users WHERE id = \?`).WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	for range exportSections[1:] {
+		mock.ExpectQuery(".+").WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+	w = serve(r, "GET", "/export", "token-8")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeletionHandlers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := newRouter()
+	r.POST("/delete", RequestDeletionHandler(db, auth.NewTokens(db, config.Static(config.Default()))))
+	r.POST("/delete/cancel", CancelDeletionHandler(db))
+	r.GET("/delete/status", DeletionStatusHandler(db))
+
+	// 未登录
+	w := serve(r, "POST", "/delete", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 申请注销：写入申请并吊销刷新令牌
+	mock.ExpectQuery(`SELECT scheduled_at FROM account_deletions`).WithArgs(7, StatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled_at"}))
+	mock.ExpectExec(`INSERT INTO account_deletions`).
+		WithArgs(7, StatusPending, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = \?`).
+		WithArgs(sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 2))
+	w = serve(r, "POST", "/delete", "token-7")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"scheduled_at"`)
+
+	// 重复申请时不改变冷静期
+	scheduled := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT scheduled_at FROM account_deletions`).WithArgs(7, StatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled_at"}).AddRow(scheduled))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = \?`).
+		WithArgs(sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 0))
+	w = serve(r, "POST", "/delete", "token-7")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "2026-11-01")
+
+	// 查询状态
+	mock.ExpectQuery(`SELECT scheduled_at FROM account_deletions`).WithArgs(7, StatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled_at"}).AddRow(scheduled))
+	w = serve(r, "GET", "/delete/status", "token-7")
+	assert.Contains(t, w.Body.String(), `"pending":true`)
+
+	// 撤销
+	mock.ExpectExec(`UPDATE account_deletions SET status = \?, cancelled_at = \?`).
+		WithArgs(StatusCancelled, sqlmock.AnyArg(), 7, StatusPending).WillReturnResult(sqlmock.NewResult(0, 1))
+	w = serve(r, "POST", "/delete/cancel", "token-7")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 没有待处理的申请
+	mock.ExpectExec(`UPDATE account_deletions SET status = \?, cancelled_at = \?`).
+		WithArgs(StatusCancelled, sqlmock.AnyArg(), 7, StatusPending).WillReturnResult(sqlmock.NewResult(0, 0))
+	w = serve(r, "POST", "/delete/cancel", "token-7")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	store := storage.NewLocal(t.TempDir(), func() string { return "http://test.com" })
+	ctx := context.Background()
+	for _, key := range []string{"avatar/7-abc-64.jpg", "review/abc.jpg", "avatar/8-def-64.jpg"} {
+		store.Put(ctx, key, []byte("x"), "")
+	}
+	now := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	p := NewPurger(db, store, time.Hour)
+	p.now = func() time.Time { return now }
+
+	mock.ExpectQuery(`SELECT id, user_id FROM account_deletions`).WithArgs(StatusPending, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 7).AddRow(2, 8))
+
+	// 用户 7：删除所有表并完成审计记录，之后重新计算评过分的菜品
+	mock.ExpectQuery(`SELECT p.url FROM review_photos`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow([]byte("/review/abc.jpg")))
+	// 驱动不使用预处理语句时整数列以文本返回
+	mock.ExpectQuery(`SELECT dish_id FROM dish_ratings`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id"}).AddRow([]byte("3")))
+	mock.ExpectBegin()
+	for _, s := range purgeStatements {
+		mock.ExpectExec(regexp.QuoteMeta(s.query)).
+			WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(`UPDATE account_deletions SET status = \?, completed_at = \?, summary = \?`).
+		WithArgs(StatusCompleted, now, sqlmock.AnyArg(), int64(1), StatusPending).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT base_score FROM dishes`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"base_score"}).AddRow(4.0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\), COALESCE\(SUM\(score\), 0\) FROM dish_ratings`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(0, 0))
+	mock.ExpectExec(`UPDATE dishes SET score`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// 用户 8：删除过程中申请被撤销，回滚
+	mock.ExpectQuery(`SELECT p.url FROM review_photos`).WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"url"}))
+	mock.ExpectQuery(`SELECT dish_id FROM dish_ratings`).WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"dish_id"}))
+	mock.ExpectBegin()
+	for range purgeStatements {
+		mock.ExpectExec(`DELETE FROM`).WithArgs(8).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`UPDATE account_deletions SET status = \?`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	n, err := p.Purge(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// 只删除已完成注销的用户的文件
+	keys, _ := store.List(ctx, "")
+	assert.Equal(t, []string{"avatar/8-def-64.jpg"}, keys)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,306 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/auth"
+	"backend/recommend"
+	"backend/review"
+	"backend/storage"
+	"backend/user"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// 注销申请状态
+const (
+	StatusPending   = "pending"   // 冷静期中
+	StatusCancelled = "cancelled" // 用户在冷静期内撤销
+	StatusCompleted = "completed" // 数据已删除
+)
+
+// GracePeriod 申请注销后的冷静期，期间重新登录后可以通过 CancelDeletionHandler 撤销申请
+const GracePeriod = 15 * 24 * time.Hour
+
+// purgeStatements 删除用户数据的语句，参数均为用户 ID，键为审计记录中的表名。
+// review_photos 和 chat_messages 随外键级联删除
+var purgeStatements = []struct{ table, query string }{
+	{"like", "DELETE FROM `like` WHERE user_id = ?"},
+	{"dish_ratings", "DELETE FROM dish_ratings WHERE user_id = ?"},
+	{"reviews", "DELETE FROM reviews WHERE user_id = ?"},
+	{"recommend_history", "DELETE FROM recommend_history WHERE user_id = ?"},
+	{"custom_recommend_history", "DELETE FROM custom_recommend_history WHERE user_id = ?"},
+	{"chat_conversations", "DELETE FROM chat_conversations WHERE user_id = ?"},
+	{"refresh_tokens", "DELETE FROM refresh_tokens WHERE user_id = ?"},
+	{"wx_sessions", "DELETE FROM wx_sessions WHERE user_id = ?"},
+	{"users", "DELETE FROM users WHERE id = ?"},
+}
+
+// pendingDeletion 用户冷静期中的注销申请，没有时返回 sql.ErrNoRows
+func pendingDeletion(db *sql.DB, userID int) (time.Time, error) {
+	var scheduledAt time.Time
+	err := db.QueryRow("SELECT scheduled_at FROM account_deletions WHERE user_id = ? AND status = ? ORDER BY id DESC LIMIT 1",
+		userID, StatusPending).Scan(&scheduledAt)
+	return scheduledAt, err
+}
+
+// loginRequired 注销相关的接口只接受登录身份
+func loginRequired(c *gin.Context) (int, bool) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": auth.ErrUnauthenticated.Error()})
+	}
+	return userID, ok
+}
+
+// RequestDeletionHandler 申请注销账号：冷静期结束后删除全部数据。
+// 申请后立即退出所有设备；登录本身不会撤销申请，冷静期内需要重新登录后调用撤销接口
+func RequestDeletionHandler(db *sql.DB, tokens *auth.Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := loginRequired(c)
+		if !ok {
+			return
+		}
+
+		scheduledAt, err := pendingDeletion(db, userID)
+		if err == sql.ErrNoRows {
+			now := time.Now()
+			scheduledAt = now.Add(GracePeriod)
+			_, err = db.Exec("INSERT INTO account_deletions (user_id, status, requested_at, scheduled_at) VALUES (?, ?, ?, ?)",
+				userID, StatusPending, now, scheduledAt)
+		}
+		if err != nil {
+			log.Printf("申请注销失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "message": "数据库操作失败"})
+			return
+		}
+
+		if err := tokens.RevokeUser(c.Request.Context(), userID); err != nil {
+			log.Printf("注销时吊销刷新令牌失败: %v", err)
+		}
+		session := sessions.Default(c)
+		session.Clear()
+		session.Options(sessions.Options{Path: "/", MaxAge: -1})
+		session.Save()
+
+		c.JSON(http.StatusOK, gin.H{
+			"code":         0,
+			"message":      "已申请注销，冷静期内重新登录后可以撤销申请",
+			"scheduled_at": scheduledAt,
+		})
+	}
+}
+
+// CancelDeletionHandler 冷静期内撤销注销申请
+func CancelDeletionHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := loginRequired(c)
+		if !ok {
+			return
+		}
+
+		res, err := db.Exec("UPDATE account_deletions SET status = ?, cancelled_at = ? WHERE user_id = ? AND status = ?",
+			StatusCancelled, time.Now(), userID, StatusPending)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "message": "数据库操作失败"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"code": 2, "message": "没有待处理的注销申请"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "已撤销注销申请"})
+	}
+}
+
+// DeletionStatusHandler 查询当前用户是否有冷静期中的注销申请
+func DeletionStatusHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := loginRequired(c)
+		if !ok {
+			return
+		}
+
+		scheduledAt, err := pendingDeletion(db, userID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusOK, gin.H{"code": 0, "pending": false})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "message": "数据库查询失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "pending": true, "scheduled_at": scheduledAt})
+	}
+}
+
+// Purger 定期删除冷静期已结束的账号
+type Purger struct {
+	db       *sql.DB
+	store    storage.Storage
+	interval time.Duration
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPurger 创建删除任务，store 为头像和评论图片所在的存储，interval 为检查的间隔
+func NewPurger(db *sql.DB, store storage.Storage, interval time.Duration) *Purger {
+	return &Purger{db: db, store: store, interval: interval, now: time.Now, stop: make(chan struct{})}
+}
+
+// Purge 删除所有到期的账号，返回删除的账号数；单个账号失败不影响其他账号
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT id, user_id FROM account_deletions WHERE status = ? AND scheduled_at <= ?",
+		StatusPending, p.now())
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		id     int64
+		userID int
+	}
+	var list []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.id, &d.userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		list = append(list, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, d := range list {
+		done, err := p.purgeUser(ctx, d.id, d.userID)
+		if err != nil {
+			log.Printf("⚠️ 删除用户 %d 的数据失败: %v", d.userID, err)
+			continue
+		}
+		if done {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// purgeUser 在一个事务中删除用户的所有数据并完成审计记录，提交后再删除头像和评论图片。
+// 申请在此期间被撤销时不删除，返回 false
+func (p *Purger) purgeUser(ctx context.Context, deletionID int64, userID int) (bool, error) {
+	// 先记下要清理的文件和受影响的菜品，删除后就查不到了
+	files, err := user.AvatarKeys(ctx, p.store, userID)
+	if err != nil {
+		return false, err
+	}
+	photos, err := queryMaps(p.db, "SELECT p.url FROM review_photos p JOIN reviews r ON p.review_id = r.id WHERE r.user_id = ?", userID)
+	if err != nil {
+		return false, err
+	}
+	for _, photo := range photos {
+		if url, ok := photo["url"].(string); ok {
+			files = append(files, review.PhotoKey(url))
+		}
+	}
+	rated, err := ratedDishes(ctx, p.db, userID)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	summary := map[string]int64{}
+	for _, s := range purgeStatements {
+		res, err := tx.ExecContext(ctx, s.query, userID)
+		if err != nil {
+			return false, err
+		}
+		summary[s.table], _ = res.RowsAffected()
+	}
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return false, err
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE account_deletions SET status = ?, completed_at = ?, summary = ? WHERE id = ? AND status = ?",
+		StatusCompleted, p.now(), string(summaryJSON), deletionID, StatusPending)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	for _, f := range files {
+		if err := p.store.Delete(ctx, f); err != nil {
+			log.Printf("⚠️ 删除用户 %d 的文件 %s 失败: %v", userID, f, err)
+		}
+	}
+	// 评分已删除，重新计算受影响菜品的评分
+	for _, dishID := range rated {
+		if err := recommend.UpdateDishScore(ctx, p.db, dishID); err != nil {
+			log.Printf("更新菜品评分失败: %v", err)
+		}
+	}
+	log.Printf("用户 %d 的数据已删除: %s", userID, summaryJSON)
+	return true, nil
+}
+
+// ratedDishes 用户评过分的菜品 ID
+func ratedDishes(ctx context.Context, db *sql.DB, userID int) ([]int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT dish_id FROM dish_ratings WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Start 在后台立即执行一次，之后每隔 interval 执行
+func (p *Purger) Start() {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := p.Purge(context.Background()); err != nil {
+				log.Printf("⚠️ 删除到期账号失败: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定期任务
+func (p *Purger) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
@@ -0,0 +1,197 @@
+// Package account 用户账号的个人信息导出和注销（冷静期后删除全部数据），满足个人信息保护法的要求
+package account
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"backend/auth"
+	"backend/review"
+	"backend/storage"
+	"backend/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// section 导出数据中的一部分，对应 ZIP 中的一个 JSON 文件
+type section struct {
+	name  string
+	query string
+}
+
+// exportSections 导出的数据，参数均为用户 ID。session_key、刷新令牌等登录凭证不导出
+var exportSections = []section{
+	{"profile", `SELECT id, openid, unionid, nickname, avatar_url, phone_number, meal_count, favorite_taste, common_mood, mood_food
+		FROM users WHERE id = ?`},
+	{"likes", "SELECT l.dish_id, d.name AS dish_name FROM `like` l LEFT JOIN dishes d ON l.dish_id = d.id WHERE l.user_id = ?"},
+	{"ratings", `SELECT r.dish_id, d.name AS dish_name, r.score, r.review, r.rated_at
+		FROM dish_ratings r LEFT JOIN dishes d ON r.dish_id = d.id WHERE r.user_id = ? ORDER BY r.rated_at`},
+	{"reviews", `SELECT id, dish_id, content, status, reject_reason, created_at FROM reviews WHERE user_id = ? ORDER BY created_at`},
+	{"review_photos", `SELECT p.review_id, p.url FROM review_photos p JOIN reviews r ON p.review_id = r.id
+		WHERE r.user_id = ? ORDER BY p.review_id, p.sort_order`},
+	{"recommend_history", `SELECT h.dish_id, d.name AS dish_name, h.recommended_at
+		FROM recommend_history h LEFT JOIN dishes d ON h.dish_id = d.id WHERE h.user_id = ? ORDER BY h.recommended_at`},
+	{"custom_recommend_history", `SELECT dish_id, taste, distance, budget, mood, weather, reason, recommended_at
+		FROM custom_recommend_history WHERE user_id = ? ORDER BY recommended_at`},
+	{"chat_conversations", `SELECT id, title, created_at, updated_at FROM chat_conversations WHERE user_id = ? ORDER BY id`},
+	{"chat_messages", `SELECT m.conversation_id, m.role, m.content, m.created_at
+		FROM chat_messages m JOIN chat_conversations c ON m.conversation_id = c.id WHERE c.user_id = ? ORDER BY m.id`},
+}
+
+// queryMaps 把查询结果按列名转换成 map，[]byte 转为字符串
+func queryMaps(db *sql.DB, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// collect 查询用户的全部数据，按 exportSections 的名称分组
+func collect(db *sql.DB, userID int) (map[string][]map[string]interface{}, error) {
+	data := make(map[string][]map[string]interface{}, len(exportSections))
+	for _, s := range exportSections {
+		rows, err := queryMaps(db, s.query, userID)
+		if err != nil {
+			return nil, fmt.Errorf("导出 %s 失败: %w", s.name, err)
+		}
+		data[s.name] = rows
+	}
+	return data, nil
+}
+
+// userFiles 用户上传的头像和评论图片，返回 ZIP 中的路径到存储路径的映射
+func userFiles(ctx context.Context, store storage.Storage, userID int, data map[string][]map[string]interface{}) (map[string]string, error) {
+	files := map[string]string{}
+	avatars, err := user.AvatarKeys(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range avatars {
+		files["avatar/"+path.Base(key)] = key
+	}
+	for _, photo := range data["review_photos"] {
+		if url, ok := photo["url"].(string); ok {
+			key := review.PhotoKey(url)
+			files["review_photos/"+path.Base(key)] = key
+		}
+	}
+	return files, nil
+}
+
+// writeZip 每部分数据写成一个 JSON 文件，再附上用户上传的图片；图片已不存在时跳过
+func writeZip(ctx context.Context, w io.Writer, store storage.Storage, data map[string][]map[string]interface{}, files map[string]string) error {
+	zw := zip.NewWriter(w)
+	for _, s := range exportSections {
+		f, err := zw.Create(s.name + ".json")
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data[s.name]); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content, err := store.Get(ctx, files[name])
+		if err == storage.ErrNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		dst, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.Write(content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// ExportHandler 导出当前用户的全部个人数据。默认返回 ZIP（每部分一个 JSON 文件，附头像和评论图片），
+// format=json 时直接返回 JSON（不含图片）。只接受登录身份，不接受客户端传入的 user_id
+func ExportHandler(db *sql.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.UserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": auth.ErrUnauthenticated.Error()})
+			return
+		}
+
+		data, err := collect(db, userID)
+		if err != nil {
+			log.Printf("导出用户 %d 数据失败: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "message": "导出失败"})
+			return
+		}
+		if len(data["profile"]) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"code": 2, "message": "用户不存在"})
+			return
+		}
+
+		exportedAt := time.Now()
+		if c.Query("format") == "json" {
+			c.JSON(http.StatusOK, gin.H{"code": 0, "exported_at": exportedAt, "data": data})
+			return
+		}
+
+		files, err := userFiles(c.Request.Context(), store, userID, data)
+		if err != nil {
+			log.Printf("查询用户 %d 的文件失败: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "message": "导出失败"})
+			return
+		}
+
+		filename := fmt.Sprintf("todayeat-export-%d-%s.zip", userID, exportedAt.Format("20060102"))
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Status(http.StatusOK)
+		if err := writeZip(c.Request.Context(), c.Writer, store, data, files); err != nil {
+			// 响应头已经发出，只能记录日志
+			log.Printf("写入用户 %d 导出文件失败: %v", userID, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package auth 从 session 或 Bearer 令牌中解析当前登录用户
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"backend/config"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// 鉴权模式，迁移期间逐步从 legacy 切换到 strict
+const (
+	ModeLegacy = "legacy" // 信任客户端传入的 user_id（旧行为）
+	ModeCompat = "compat" // 优先使用登录身份；未登录时仍接受 user_id，两者不一致时拒绝
+	ModeStrict = "strict" // 只认登录身份
+)
+
+// SessionUserKey 登录后 session 中保存用户 ID 的键
+const SessionUserKey = "user_id"
+
+// gin.Context 中保存鉴权结果的键
+const (
+	contextUserKey = "auth.user_id"
+	contextModeKey = "auth.mode"
+)
+
+var (
+	ErrUnauthenticated = errors.New("请先登录")
+	ErrForbidden       = errors.New("无权操作其他用户的数据")
+)
+
+// TokenVerifier 校验 Bearer 令牌，返回令牌所属的用户 ID
+type TokenVerifier interface {
+	Verify(token string) (int, error)
+}
+
+// Middleware 解析当前登录用户并写入 gin.Context。
+// 携带 Authorization: Bearer 令牌时以令牌为准，令牌无效直接返回 401；否则读取 session。
+// 没有登录身份时不拦截，由各接口通过 CurrentUser 决定是否需要登录。verifier 为 nil 时不支持令牌
+func Middleware(cfg config.Snapshot, verifier TokenVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextModeKey, cfg().Auth.Mode)
+
+		if header := c.GetHeader("Authorization"); header != "" {
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok || verifier == nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "不支持的认证方式"})
+				return
+			}
+			userID, err := verifier.Verify(strings.TrimSpace(token))
+			if err == ErrTokenExpired {
+				// 客户端收到后用刷新令牌换取新的访问令牌
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "访问令牌已过期"})
+				return
+			} else if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "登录已失效，请重新登录"})
+				return
+			}
+			c.Set(contextUserKey, userID)
+			c.Next()
+			return
+		}
+
+		if userID := sessionUserID(sessions.Default(c)); userID > 0 {
+			c.Set(contextUserKey, userID)
+		}
+		c.Next()
+	}
+}
+
+// sessionUserID 读取 session 中的用户 ID，兼容不同序列化方式得到的数字类型
+func sessionUserID(s sessions.Session) int {
+	switch v := s.Get(SessionUserKey).(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// UserID 返回已登录用户的 ID，未登录时返回 false
+func UserID(c *gin.Context) (int, bool) {
+	id, ok := c.Get(contextUserKey)
+	if !ok {
+		return 0, false
+	}
+	userID, ok := id.(int)
+	return userID, ok && userID > 0
+}
+
+// mode 当前请求的鉴权模式。服务中总是经过 Middleware 使用配置的模式（默认 strict）；
+// 没有经过 Middleware（如单独测试接口）时按 compat 处理
+func mode(c *gin.Context) string {
+	if m := c.GetString(contextModeKey); m != "" {
+		return m
+	}
+	return ModeCompat
+}
+
+// Resolve 结合登录身份和客户端传入的 claimed（没有传时为 0）确定当前用户
+func Resolve(c *gin.Context, claimed int) (int, error) {
+	authed, loggedIn := UserID(c)
+	switch mode(c) {
+	case ModeLegacy:
+		if claimed > 0 {
+			return claimed, nil
+		}
+	case ModeStrict:
+		if !loggedIn {
+			return 0, ErrUnauthenticated
+		}
+	default:
+		if !loggedIn && claimed > 0 {
+			log.Printf("⚠️ %s %s 未登录，使用客户端传入的 user_id=%d", c.Request.Method, c.FullPath(), claimed)
+			return claimed, nil
+		}
+	}
+	if !loggedIn {
+		return 0, ErrUnauthenticated
+	}
+	if claimed > 0 && claimed != authed {
+		return 0, ErrForbidden
+	}
+	return authed, nil
+}
+
+// CurrentUser 确定当前用户，失败时直接返回 401 或 403，调用方只需 return
+func CurrentUser(c *gin.Context, claimed int) (int, bool) {
+	userID, err := Resolve(c, claimed)
+	switch err {
+	case nil:
+		return userID, true
+	case ErrForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": err.Error()})
+	default:
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": err.Error()})
+	}
+	return 0, false
+}
+
+// OptionalUser 用于允许匿名访问的接口：没有任何身份时返回 0；有身份时与 CurrentUser 相同
+func OptionalUser(c *gin.Context, claimed int) (int, bool) {
+	if _, loggedIn := UserID(c); !loggedIn && (claimed == 0 || mode(c) == ModeStrict) {
+		return 0, true
+	}
+	return CurrentUser(c, claimed)
+}
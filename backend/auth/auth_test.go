@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"backend/config"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVerifier 令牌 "token-<id>" 有效
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(token string) (int, error) {
+	if rest, ok := strings.CutPrefix(token, "token-"); ok {
+		if id, err := strconv.Atoi(rest); err == nil {
+			return id, nil
+		}
+	}
+	return 0, errors.New("invalid token")
+}
+
+// newRouter 创建带 session 和鉴权中间件的路由：/login 登录为 user_id，/me 返回当前用户
+func newRouter(mode string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Auth.Mode = mode
+
+	r := gin.New()
+	r.Use(sessions.Sessions("test", cookie.NewStore([]byte("test-secret"))))
+	r.Use(Middleware(config.Static(cfg), fakeVerifier{}))
+	r.GET("/login", func(c *gin.Context) {
+		id, _ := strconv.Atoi(c.Query("user_id"))
+		s := sessions.Default(c)
+		s.Set(SessionUserKey, id)
+		s.Save()
+	})
+	r.GET("/me", func(c *gin.Context) {
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 0, "user_id": userID})
+	})
+	return r
+}
+
+// get 发送请求，cookie 和 token 可以为空
+func get(r *gin.Engine, url string, cookies []*http.Cookie, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
+	for _, ck := range cookies {
+		req.AddCookie(ck)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func login(r *gin.Engine, userID int) []*http.Cookie {
+	w := get(r, "/login?user_id="+strconv.Itoa(userID), nil, "")
+	return w.Result().Cookies()
+}
+
+func TestCurrentUser_Modes(t *testing.T) {
+	cases := []struct {
+		mode     string
+		loggedIn bool
+		query    string
+		status   int
+		userID   string
+	}{
+		// legacy：信任客户端
+		{ModeLegacy, false, "?user_id=5", 200, `"user_id":5`},
+		{ModeLegacy, true, "?user_id=5", 200, `"user_id":5`},
+		{ModeLegacy, true, "", 200, `"user_id":7`},
+		{ModeLegacy, false, "", 401, ""},
+		// compat：登录身份优先，未登录时接受 user_id
+		{ModeCompat, false, "?user_id=5", 200, `"user_id":5`},
+		{ModeCompat, true, "", 200, `"user_id":7`},
+		{ModeCompat, true, "?user_id=7", 200, `"user_id":7`},
+		{ModeCompat, true, "?user_id=5", 403, ""},
+		{ModeCompat, false, "", 401, ""},
+		// strict：只认登录身份
+		{ModeStrict, false, "?user_id=5", 401, ""},
+		{ModeStrict, true, "", 200, `"user_id":7`},
+		{ModeStrict, true, "?user_id=5", 403, ""},
+	}
+	for _, tc := range cases {
+		r := newRouter(tc.mode)
+		var cookies []*http.Cookie
+		if tc.loggedIn {
+			cookies = login(r, 7)
+		}
+		w := get(r, "/me"+tc.query, cookies, "")
+		assert.Equal(t, tc.status, w.Code, "%s 登录:%v %s", tc.mode, tc.loggedIn, tc.query)
+		if tc.userID != "" {
+			assert.Contains(t, w.Body.String(), tc.userID)
+		}
+	}
+}
+
+func TestMiddleware_BearerToken(t *testing.T) {
+	r := newRouter(ModeStrict)
+
+	w := get(r, "/me", nil, "token-9")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"user_id":9`)
+
+	// 令牌优先于 session
+	w = get(r, "/me", login(r, 7), "token-9")
+	assert.Contains(t, w.Body.String(), `"user_id":9`)
+
+	// 无效令牌直接拒绝，不回退到 session
+	w = get(r, "/me", login(r, 7), "bogus")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 不支持的认证方式
+	req, _ := http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Basic abc")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestOptionalUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, mode := range []string{ModeCompat, ModeStrict} {
+		cfg := config.Default()
+		cfg.Auth.Mode = mode
+		r := gin.New()
+		r.Use(sessions.Sessions("test", cookie.NewStore([]byte("test-secret"))))
+		r.Use(Middleware(config.Static(cfg), nil))
+		r.GET("/", func(c *gin.Context) {
+			claimed, _ := strconv.Atoi(c.Query("user_id"))
+			userID, ok := OptionalUser(c, claimed)
+			if ok {
+				c.String(http.StatusOK, strconv.Itoa(userID))
+			}
+		})
+
+		// 匿名访问
+		w := get(r, "/", nil, "")
+		assert.Equal(t, "0", w.Body.String())
+
+		// strict 模式下忽略客户端传入的 user_id，按匿名处理
+		w = get(r, "/?user_id=5", nil, "")
+		if mode == ModeStrict {
+			assert.Equal(t, "0", w.Body.String())
+		} else {
+			assert.Equal(t, "5", w.Body.String())
+		}
+	}
+}
+
+func TestCurrentUser_WithoutMiddleware(t *testing.T) {
+	// 没有经过中间件时按 compat 处理
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/", nil)
+	userID, ok := CurrentUser(c, 3)
+	assert.True(t, ok)
+	assert.Equal(t, 3, userID)
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/config"
+)
+
+var (
+	ErrInvalidToken = errors.New("令牌无效")
+	ErrTokenExpired = errors.New("令牌已过期")
+)
+
+// jwtHeader 访问令牌的 JWT 头，kid 标明签名所用的密钥，便于轮换
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// accessClaims 访问令牌携带的声明
+type accessClaims struct {
+	Sub string `json:"sub"` // 用户 ID
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// signAccessToken 用 key 签发 HS256 访问令牌
+func signAccessToken(key config.TokenKey, userID int, now time.Time, ttl time.Duration) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(accessClaims{Sub: strconv.Itoa(userID), Iat: now.Unix(), Exp: now.Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	signingInput := b64.EncodeToString(header) + "." + b64.EncodeToString(claims)
+	return signingInput + "." + b64.EncodeToString(hs256(key.Secret, signingInput)), nil
+}
+
+// parseAccessToken 校验访问令牌的签名和有效期，返回用户 ID。
+// 带 kid 时只用对应的密钥校验，没有 kid 时依次尝试所有密钥
+func parseAccessToken(keys []config.TokenKey, token string, now time.Time) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidToken
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return 0, ErrInvalidToken
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	valid := false
+	for _, key := range keys {
+		if header.Kid != "" && key.ID != header.Kid {
+			continue
+		}
+		if hmac.Equal(sig, hs256(key.Secret, signingInput)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return 0, ErrInvalidToken
+	}
+
+	var claims accessClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return 0, ErrInvalidToken
+	}
+	if now.Unix() >= claims.Exp {
+		return 0, ErrTokenExpired
+	}
+	userID, err := strconv.Atoi(claims.Sub)
+	if err != nil || userID <= 0 {
+		return 0, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func hs256(secret []byte, input string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := b64.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"backend/config"
+)
+
+// ErrTokenReused 已经换过新令牌的刷新令牌被再次使用，所在的整组令牌已吊销
+var ErrTokenReused = errors.New("刷新令牌已被使用，请重新登录")
+
+// TokenPair 登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // 访问令牌有效期（秒）
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Tokens 签发和校验访问令牌、轮换刷新令牌。签名密钥和有效期每次从 cfg 读取，
+// 修改 auth.token_keys 后无需重启即可完成密钥轮换
+type Tokens struct {
+	db  *sql.DB
+	cfg config.Snapshot
+	now func() time.Time
+}
+
+// NewTokens 创建令牌服务，刷新令牌保存在 refresh_tokens 表
+func NewTokens(db *sql.DB, cfg config.Snapshot) *Tokens {
+	return &Tokens{db: db, cfg: cfg, now: time.Now}
+}
+
+// Verify 校验访问令牌，实现 TokenVerifier
+func (t *Tokens) Verify(token string) (int, error) {
+	keys, err := config.ParseTokenKeys(t.cfg().Auth.TokenKeys)
+	if err != nil {
+		return 0, err
+	}
+	return parseAccessToken(keys, token, t.now())
+}
+
+// Issue 登录后签发一组新令牌
+func (t *Tokens) Issue(ctx context.Context, userID int) (TokenPair, error) {
+	family, err := randomHex(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	defer tx.Rollback()
+
+	pair, err := t.issue(ctx, tx, userID, family)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return pair, tx.Commit()
+}
+
+// issue 签发访问令牌，并在 family 中保存一个新的刷新令牌
+func (t *Tokens) issue(ctx context.Context, tx *sql.Tx, userID int, family string) (TokenPair, error) {
+	cfg := t.cfg().Auth
+	keys, err := config.ParseTokenKeys(cfg.TokenKeys)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	now := t.now()
+	ttl := time.Duration(cfg.AccessTokenMinutes) * time.Minute
+	access, err := signAccessToken(keys[0], userID, now, ttl)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refresh, err := randomHex(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, family, hashToken(refresh), now.AddDate(0, 0, cfg.RefreshTokenDays), now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(ttl.Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// Refresh 用刷新令牌换取新的一组令牌，旧的刷新令牌随即失效。
+// 已经用过的刷新令牌再次出现说明可能被盗用，吊销同一次登录产生的所有刷新令牌并返回 ErrTokenReused
+func (t *Tokens) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var userID int
+	var family string
+	var expiresAt time.Time
+	var used, revoked bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, expires_at, used_at IS NOT NULL, revoked_at IS NOT NULL
+		FROM refresh_tokens WHERE token_hash = ? FOR UPDATE
+	`, hashToken(refreshToken)).Scan(&id, &userID, &family, &expiresAt, &used, &revoked)
+	if err == sql.ErrNoRows {
+		return TokenPair{}, ErrInvalidToken
+	} else if err != nil {
+		return TokenPair{}, err
+	}
+
+	now := t.now()
+	switch {
+	case revoked:
+		return TokenPair{}, ErrInvalidToken
+	case used:
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now, family); err != nil {
+			return TokenPair{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrTokenReused
+	case !now.Before(expiresAt):
+		return TokenPair{}, ErrTokenExpired
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = ? WHERE id = ?", now, id); err != nil {
+		return TokenPair{}, err
+	}
+	pair, err := t.issue(ctx, tx, userID, family)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return pair, tx.Commit()
+}
+
+// Revoke 退出登录：吊销刷新令牌所属的整组令牌。令牌不存在时不报错。
+// 已签发的访问令牌不会立即失效，最长在 auth.access_token_minutes 后过期
+func (t *Tokens) Revoke(ctx context.Context, refreshToken string) error {
+	_, err := t.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = ?
+		WHERE revoked_at IS NULL AND family_id = (
+			SELECT family_id FROM (SELECT family_id FROM refresh_tokens WHERE token_hash = ?) f
+		)
+	`, t.now(), hashToken(refreshToken))
+	return err
+}
+
+// RevokeUser 吊销用户的所有刷新令牌，用于退出所有设备
+func (t *Tokens) RevokeUser(ctx context.Context, userID int) error {
+	_, err := t.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", t.now(), userID)
+	return err
+}
+
+// hashToken 刷新令牌只保存 SHA-256，数据库泄露时无法直接使用
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
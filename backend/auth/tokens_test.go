@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/config"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSecretA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	testSecretB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+func TestAccessToken_SignAndParse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	keyA := config.TokenKey{ID: "a", Secret: []byte(testSecretA)}
+	keyB := config.TokenKey{ID: "b", Secret: []byte(testSecretB)}
+
+	token, err := signAccessToken(keyA, 42, now, 15*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(token, "."))
+
+	userID, err := parseAccessToken([]config.TokenKey{keyA}, token, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 42, userID)
+
+	// 轮换后旧密钥仍在列表中，旧令牌继续有效
+	userID, err = parseAccessToken([]config.TokenKey{keyB, keyA}, token, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, userID)
+
+	// 旧密钥删除后失效
+	_, err = parseAccessToken([]config.TokenKey{keyB}, token, now)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	// 过期
+	_, err = parseAccessToken([]config.TokenKey{keyA}, token, now.Add(15*time.Minute))
+	assert.Equal(t, ErrTokenExpired, err)
+
+	// 篡改声明
+	parts := strings.Split(token, ".")
+	forged, _ := signAccessToken(keyB, 1, now, time.Hour)
+	_, err = parseAccessToken([]config.TokenKey{keyA}, parts[0]+"."+strings.Split(forged, ".")[1]+"."+parts[2], now)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	for _, bad := range []string{"", "a.b", "a.b.c", token + "x"} {
+		_, err := parseAccessToken([]config.TokenKey{keyA}, bad, now)
+		assert.Error(t, err, bad)
+	}
+}
+
+// newTestTokens 创建使用固定时间和 mock 数据库的令牌服务
+func newTestTokens(t *testing.T, keys string) (*Tokens, sqlmock.Sqlmock, time.Time) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := config.Default()
+	cfg.Auth.TokenKeys = keys
+	now := time.Unix(1700000000, 0)
+	tokens := NewTokens(db, config.Static(cfg))
+	tokens.now = func() time.Time { return now }
+	return tokens, mock, now
+}
+
+func TestTokens_IssueAndVerify(t *testing.T) {
+	tokens, mock, now := newTestTokens(t, "a:"+testSecretA)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO refresh_tokens`).
+		WithArgs(7, sqlmock.AnyArg(), sqlmock.AnyArg(), now.AddDate(0, 0, 30), now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	pair, err := tokens.Issue(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer", pair.TokenType)
+	assert.Equal(t, 15*60, pair.ExpiresIn)
+	assert.Len(t, pair.RefreshToken, 64)
+
+	userID, err := tokens.Verify(pair.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, userID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTokens_Refresh(t *testing.T) {
+	tokens, mock, now := newTestTokens(t, "a:"+testSecretA)
+	columns := []string{"id", "user_id", "family_id", "expires_at", "used", "revoked"}
+	lookup := `FROM refresh_tokens WHERE token_hash = \? FOR UPDATE`
+
+	// 1. 正常轮换：旧令牌标记为已使用，同一 family 下签发新令牌
+	mock.ExpectBegin()
+	mock.ExpectQuery(lookup).WithArgs(hashToken("old")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 7, "fam", now.Add(time.Hour), false, false))
+	mock.ExpectExec(`UPDATE refresh_tokens SET used_at = \? WHERE id = \?`).WithArgs(now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO refresh_tokens`).WithArgs(7, "fam", sqlmock.AnyArg(), sqlmock.AnyArg(), now).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	pair, err := tokens.Refresh(context.Background(), "old")
+	assert.NoError(t, err)
+	assert.NotEqual(t, "old", pair.RefreshToken)
+	userID, _ := tokens.Verify(pair.AccessToken)
+	assert.Equal(t, 7, userID)
+
+	// 2. 重复使用：吊销整个 family
+	mock.ExpectBegin()
+	mock.ExpectQuery(lookup).WithArgs(hashToken("old")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 7, "fam", now.Add(time.Hour), true, false))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = \? WHERE family_id = \? AND revoked_at IS NULL`).
+		WithArgs(now, "fam").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	_, err = tokens.Refresh(context.Background(), "old")
+	assert.Equal(t, ErrTokenReused, err)
+
+	// 3. 已过期
+	mock.ExpectBegin()
+	mock.ExpectQuery(lookup).WithArgs(hashToken("expired")).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 7, "fam2", now.Add(-time.Second), false, false))
+	mock.ExpectRollback()
+
+	_, err = tokens.Refresh(context.Background(), "expired")
+	assert.Equal(t, ErrTokenExpired, err)
+
+	// 4. 不存在
+	mock.ExpectBegin()
+	mock.ExpectQuery(lookup).WithArgs(hashToken("unknown")).WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectRollback()
+
+	_, err = tokens.Refresh(context.Background(), "unknown")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTokens_KeyRotation(t *testing.T) {
+	tokens, mock, _ := newTestTokens(t, "a:"+testSecretA)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO refresh_tokens`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	pair, err := tokens.Issue(context.Background(), 7)
+	assert.NoError(t, err)
+
+	// 新密钥放在最前面，旧令牌仍然有效
+	cfg := config.Default()
+	cfg.Auth.TokenKeys = "b:" + testSecretB + ",a:" + testSecretA
+	tokens.cfg = config.Static(cfg)
+	userID, err := tokens.Verify(pair.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, userID)
+
+	// 删除旧密钥后失效
+	cfg.Auth.TokenKeys = "b:" + testSecretB
+	_, err = tokens.Verify(pair.AccessToken)
+	assert.Equal(t, ErrInvalidToken, err)
+}
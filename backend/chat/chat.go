@@ -1,28 +1,85 @@
 package chat
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
+
+	"backend/auth"
+	"backend/llm"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+// WSMessage 客户端发送的消息，Type 为空表示普通提问
 type WSMessage struct {
+	Type    string `json:"type"`
 	Message string `json:"message"`
 }
 
+// 控制消息类型
+const msgTypeReset = "reset" // 清空历史，开始新的对话
+
+// idleTimeout 连接空闲超过该时间未发送消息则关闭
+const idleTimeout = 10 * time.Minute
+
+const systemPrompt = "你是一个美食推荐助手，你叫TodayEat，根据用户描述推荐菜品。"
+
 var upgrader = websocket.Upgrader{
+	Subprotocols: []string{SubprotocolV1},
 	CheckOrigin: func(r *http.Request) bool {
 		return true // 允许所有跨域连接，生产环境请限制
 	},
 }
 
-func ChatWSHandler(apiKey string) gin.HandlerFunc {
+// ChatWSHandler 聊天 WebSocket，连接保持打开，同一连接上可以连续多轮提问。
+//
+// 客户端通过 Sec-WebSocket-Protocol 声明 todayeat.chat.v1 时使用结构化 JSON 帧（见 Frame），
+// 否则沿用旧版协议：直接发送回复文本和错误文本。
+//
+// 已登录（兼容模式下也可以带 user_id）时对话会被保存；再带上 conversation_id 可以继续之前的会话，
+// 历史消息会作为上下文发送给 AI。未登录为匿名对话，不保存记录。
+//
+// 每轮回复（包括函数调用）最长 turnTimeout，上游卡住或输出过慢时本轮回复失败，连接可以继续提问。
+func ChatWSHandler(db *sql.DB, provider llm.Provider, turnTimeout time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.OptionalUser(c, claimed)
+		if !ok {
+			return
+		}
+
+		// 恢复之前的会话
+		var conversationID int64
+		var previous []StoredMessage
+		if idStr := c.Query("conversation_id"); idStr != "" {
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil || userID == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数错误"})
+				return
+			}
+			if err := checkConversationOwner(db, id, userID); err == errConversationNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"code": 3, "message": "会话不存在"})
+				return
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+				return
+			}
+			previous, err = loadMessages(db, id, maxHistoryMessages)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+				return
+			}
+			conversationID = id
+		}
+
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			fmt.Println("WebSocket Upgrade error:", err)
@@ -30,85 +87,123 @@ func ChatWSHandler(apiKey string) gin.HandlerFunc {
 		}
 		defer conn.Close()
 
-		// 接收用户问题
-		_, msgBytes, err := conn.ReadMessage()
-		if err != nil {
-			conn.WriteMessage(websocket.TextMessage, []byte("接收消息失败"))
-			return
+		w := newFrameWriter(conn)
+		stop := make(chan struct{})
+		defer close(stop)
+		go w.keepAlive(stop)
+
+		// 有数据库时提供查询菜品的工具；结构化协议下额外要求标注菜品 ID 以下发菜品卡片
+		prompt := systemPrompt
+		if db != nil {
+			prompt += toolsPrompt
+			if w.structured {
+				prompt += dishRefPrompt
+			}
 		}
-
-		var req WSMessage
-		if err := json.Unmarshal(msgBytes, &req); err != nil || req.Message == "" {
-			conn.WriteMessage(websocket.TextMessage, []byte("格式错误"))
-			return
+		sess := newSession(prompt)
+		for _, m := range previous {
+			sess.add(m.Role, m.Content)
 		}
 
-		// 发起 DeepSeek 流式请求
-		err = callDeepSeekStream(apiKey, req.Message, func(content string) {
-			conn.WriteMessage(websocket.TextMessage, []byte(content))
-		})
-
-		if err != nil {
-			conn.WriteMessage(websocket.TextMessage, []byte("AI 调用失败: "+err.Error()))
-		}
-	}
-}
+		for {
+			// 接收用户问题，连接关闭或空闲超时时结束对话
+			conn.SetReadDeadline(time.Now().Add(idleTimeout))
+			_, msgBytes, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
 
-var callDeepSeekStream = realCallDeepSeekStream
+			var req WSMessage
+			if err := json.Unmarshal(msgBytes, &req); err != nil {
+				w.fail(ErrCodeBadRequest, "格式错误")
+				continue
+			}
+			if req.Type == msgTypeReset {
+				// 下一条提问将开启新的会话
+				sess.reset()
+				conversationID = 0
+				continue
+			}
+			if req.Message == "" {
+				w.fail(ErrCodeBadRequest, "格式错误")
+				continue
+			}
 
-func realCallDeepSeekStream(apiKey string, message string, onDelta func(string)) error {
-	url := "https://api.deepseek.com/v1/chat/completions"
+			// 发起流式请求，带上本连接的历史消息
+			sess.add(llm.RoleUser, req.Message)
+			tb := newToolbox(db, userID)
+			ctx, cancel := context.WithTimeout(c.Request.Context(), turnTimeout)
+			resp, err := answer(ctx, provider, tb, sess.messages(), w)
+			cancel()
+			if err != nil {
+				sess.dropLast()
+				w.fail(ErrCodeAIUnavailable, "AI 调用失败: "+err.Error())
+				continue
+			}
+			sess.add(llm.RoleAssistant, resp.Content)
 
-	bodyMap := map[string]interface{}{
-		"model":  "deepseek-chat",
-		"stream": true,
-		"messages": []map[string]string{
-			{"role": "system", "content": "你是一个美食推荐助手，你叫TodayEat，根据用户描述推荐菜品。"},
-			{"role": "user", "content": message},
-		},
-		"temperature": 0.7,
+			if userID != 0 {
+				conversationID = saveTurn(db, userID, conversationID, req.Message, resp.Content)
+			}
+			for _, card := range tb.referencedDishes(resp.Content) {
+				w.dishCard(card)
+			}
+			w.done(conversationID, resp.Usage)
+		}
 	}
+}
 
-	bodyBytes, _ := json.Marshal(bodyMap)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(bodyBytes))
-	if err != nil {
-		return err
+// answer 生成一轮回复：模型要求调用工具时执行工具并把结果发回，直到模型给出最终回答。
+// 返回的 Usage 为本轮所有调用的合计
+func answer(ctx context.Context, provider llm.Provider, tb *toolbox, msgs []llm.Message, w *frameWriter) (*llm.Response, error) {
+	if provider == nil {
+		return nil, errors.New("AI 未启用")
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+	var usage llm.Usage
+	for round := 0; ; round++ {
+		tools := tb.definitions()
+		if round >= maxToolRounds {
+			tools = nil
+		}
 
-	reader := bufio.NewReader(resp.Body)
-	for {
-		line, err := reader.ReadBytes('\n')
+		resp, err := provider.Stream(ctx, llm.Request{
+			Messages:    msgs,
+			Tools:       tools,
+			Temperature: 0.7,
+		}, w.delta)
 		if err != nil {
-			break
+			return nil, err
+		}
+		usage.Add(resp.Usage)
+		if len(resp.ToolCalls) == 0 || tools == nil {
+			resp.Usage = usage
+			return resp, nil
 		}
 
-		// 处理每行：开头是 "data: "
-		if bytes.HasPrefix(line, []byte("data: ")) {
-			jsonPart := bytes.TrimPrefix(line, []byte("data: "))
-			if bytes.Contains(jsonPart, []byte("[DONE]")) {
-				break
-			}
-			var piece map[string]interface{}
-			if err := json.Unmarshal(jsonPart, &piece); err == nil {
-				if delta, ok := piece["choices"].([]interface{}); ok && len(delta) > 0 {
-					if item, ok := delta[0].(map[string]interface{}); ok {
-						if deltaVal, ok := item["delta"].(map[string]interface{}); ok {
-							if content, ok := deltaVal["content"].(string); ok {
-								onDelta(content)
-							}
-						}
-					}
-				}
-			}
+		msgs = append(msgs, llm.Message{Role: llm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			msgs = append(msgs, llm.Message{Role: llm.RoleTool, ToolCallID: call.ID, Content: tb.call(ctx, call)})
 		}
 	}
-	return nil
+}
+
+// saveTurn 保存一轮问答，必要时先创建会话，返回会话 ID；保存失败只记录日志，不影响聊天
+func saveTurn(db *sql.DB, userID int, conversationID int64, question, answer string) int64 {
+	if conversationID == 0 {
+		id, err := createConversation(db, userID, question)
+		if err != nil {
+			log.Printf("创建聊天会话失败: %v", err)
+			return 0
+		}
+		conversationID = id
+	}
+	err := appendMessages(db, conversationID,
+		StoredMessage{Role: llm.RoleUser, Content: question},
+		StoredMessage{Role: llm.RoleAssistant, Content: answer},
+	)
+	if err != nil {
+		log.Printf("保存聊天记录失败: %v", err)
+	}
+	return conversationID
 }
@@ -4,28 +4,21 @@ import (
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"backend/llm"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
-// mock callDeepSeekStream
-var callDeepSeekStreamBak = callDeepSeekStream
-
-func mockCallDeepSeekStream(apiKey string, message string, onDelta func(string)) error {
-	onDelta("AI回复内容")
-	return nil
-}
-
 func TestChatWSHandler(t *testing.T) {
-	// 替换 callDeepSeekStream
-	callDeepSeekStream = mockCallDeepSeekStream
-	defer func() { callDeepSeekStream = callDeepSeekStreamBak }()
+	provider := &llm.Fake{Replies: []string{"AI回复内容"}}
 
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
-	router.GET("/ws", ChatWSHandler("fake-api-key"))
+	router.GET("/ws", ChatWSHandler(nil, provider, time.Minute))
 
 	server := httptest.NewServer(router)
 	defer server.Close()
@@ -46,4 +39,54 @@ func TestChatWSHandler(t *testing.T) {
 	_, reply, err := conn.ReadMessage()
 	assert.NoError(t, err)
 	assert.Equal(t, "AI回复内容", string(reply))
+
+	// AI 收到系统提示和用户问题
+	assert.Len(t, provider.Requests, 1)
+	assert.Equal(t, "你好", provider.Requests[0].Messages[1].Content)
+}
+
+func TestChatWSHandler_MultiTurn(t *testing.T) {
+	provider := &llm.Fake{Replies: []string{"第一轮", "第二轮", "重置后"}}
+	// 旧版协议每轮回复后关闭连接，多轮对话需要结构化协议
+	conn, closeFn := dialV1(t, provider)
+	defer closeFn()
+
+	ask := func(message string) string {
+		conn.WriteJSON(WSMessage{Message: message})
+		var reply string
+		for {
+			var f Frame
+			if err := conn.ReadJSON(&f); err != nil {
+				t.Fatalf("读取帧失败: %v", err)
+			}
+			if f.Type == FrameDone {
+				return reply
+			}
+			reply += f.Content
+		}
+	}
+
+	assert.Equal(t, "第一轮", ask("推荐个菜"))
+	assert.Equal(t, "第二轮", ask("便宜点的呢"))
+	// 第二轮请求带上了第一轮的问答
+	assert.Len(t, provider.Requests[1].Messages, 4)
+	assert.Equal(t, "第一轮", provider.Requests[1].Messages[2].Content)
+
+	// 重置后历史清空
+	conn.WriteJSON(WSMessage{Type: "reset"})
+	assert.Equal(t, "重置后", ask("再来一个"))
+	assert.Len(t, provider.Requests[2].Messages, 2)
+}
+
+func TestSessionTrim(t *testing.T) {
+	s := newSession(systemPrompt)
+	s.maxRunes = 10
+	s.add(llm.RoleUser, "一二三四五")
+	s.add(llm.RoleAssistant, "一二三四五")
+	s.add(llm.RoleUser, "六七八")
+
+	// 超出预算后丢弃最早的一问一答，且不以 assistant 开头
+	assert.Len(t, s.history, 1)
+	assert.Equal(t, "六七八", s.history[0].Content)
+	assert.Equal(t, llm.RoleSystem, s.messages()[0].Role)
 }
@@ -0,0 +1,171 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Conversation 一次聊天会话
+type Conversation struct {
+	ID        int64  `json:"id"`
+	Title     string `json:"title"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// StoredMessage 已保存的聊天消息
+type StoredMessage struct {
+	ID        int64  `json:"id"`
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+// 会话标题取第一条提问的前若干个字
+const titleRunes = 20
+
+var errConversationNotFound = errors.New("会话不存在")
+
+// createConversation 创建会话，标题取自第一条提问
+func createConversation(db *sql.DB, userID int, firstMessage string) (int64, error) {
+	title := []rune(firstMessage)
+	if len(title) > titleRunes {
+		title = title[:titleRunes]
+	}
+	res, err := db.Exec("INSERT INTO chat_conversations (user_id, title) VALUES (?, ?)", userID, string(title))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// appendMessages 保存一轮问答并刷新会话更新时间
+func appendMessages(db *sql.DB, conversationID int64, msgs ...StoredMessage) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, m := range msgs {
+		if _, err := tx.Exec("INSERT INTO chat_messages (conversation_id, role, content) VALUES (?, ?, ?)",
+			conversationID, m.Role, m.Content); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE chat_conversations SET updated_at = NOW() WHERE id = ?", conversationID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkConversationOwner 确认会话属于该用户，不存在或不属于该用户都返回 errConversationNotFound
+func checkConversationOwner(db *sql.DB, conversationID int64, userID int) error {
+	var owner int
+	err := db.QueryRow("SELECT user_id FROM chat_conversations WHERE id = ?", conversationID).Scan(&owner)
+	if err == sql.ErrNoRows || (err == nil && owner != userID) {
+		return errConversationNotFound
+	}
+	return err
+}
+
+// loadMessages 按时间顺序返回会话最近的 limit 条消息
+func loadMessages(db *sql.DB, conversationID int64, limit int) ([]StoredMessage, error) {
+	rows, err := db.Query(`
+		SELECT id, role, content, created_at FROM (
+			SELECT id, role, content, created_at
+			FROM chat_messages
+			WHERE conversation_id = ?
+			ORDER BY id DESC
+			LIMIT ?
+		) t ORDER BY id ASC
+	`, conversationID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	msgs := []StoredMessage{}
+	for rows.Next() {
+		var m StoredMessage
+		if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// ListConversationsHandler 获取用户的聊天会话列表（最近更新的在前）
+func ListConversationsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT id, title, created_at, updated_at
+			FROM chat_conversations
+			WHERE user_id = ?
+			ORDER BY updated_at DESC
+			LIMIT 50
+		`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		defer rows.Close()
+
+		conversations := []Conversation{}
+		for rows.Next() {
+			var conv Conversation
+			if err := rows.Scan(&conv.ID, &conv.Title, &conv.CreatedAt, &conv.UpdatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": "数据转换失败"})
+				return
+			}
+			conversations = append(conversations, conv)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "conversations": conversations})
+	}
+}
+
+// GetConversationMessagesHandler 获取某个会话的消息
+func GetConversationMessagesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
+		conversationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "会话 ID 无效"})
+			return
+		}
+
+		if err := checkConversationOwner(db, conversationID, userID); err == errConversationNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"code": 3, "message": "会话不存在"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+
+		msgs, err := loadMessages(db, conversationID, 500)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "conversation_id": conversationID, "messages": msgs})
+	}
+}
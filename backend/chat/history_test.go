@@ -0,0 +1,112 @@
+package chat
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/llm"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListConversationsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/conversations", ListConversationsHandler(db))
+
+	rows := sqlmock.NewRows([]string{"id", "title", "created_at", "updated_at"}).
+		AddRow(3, "推荐个菜", "2024-01-01 12:00:00", "2024-01-01 12:05:00")
+	mock.ExpectQuery("SELECT id, title, created_at, updated_at").WithArgs(7).WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/conversations?user_id=7", nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("推荐个菜")) {
+		t.Errorf("查询会话列表失败，返回: %s", w.Body.String())
+	}
+}
+
+func TestGetConversationMessagesHandler_NotOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/conversations/:id/messages", GetConversationMessagesHandler(db))
+
+	mock.ExpectQuery("SELECT user_id FROM chat_conversations").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(8))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/conversations/3/messages?user_id=7", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestChatWSHandler_ResumeConversation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT user_id FROM chat_conversations").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	mock.ExpectQuery("SELECT id, role, content, created_at FROM").WithArgs(3, maxHistoryMessages).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role", "content", "created_at"}).
+			AddRow(1, "user", "推荐个辣的", "2024-01-01").
+			AddRow(2, "assistant", "水煮鱼", "2024-01-01"))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO chat_messages").WithArgs(3, "user", "再来一个").WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("INSERT INTO chat_messages").WithArgs(3, "assistant", "麻婆豆腐").WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec("UPDATE chat_conversations SET updated_at").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	provider := &llm.Fake{Replies: []string{"麻婆豆腐"}}
+	router := gin.New()
+	router.GET("/ws", ChatWSHandler(db, provider, time.Minute))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolV1}}
+	conn, _, err := dialer.Dial("ws"+server.URL[len("http"):]+"/ws?user_id=7&conversation_id=3", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	conn.WriteJSON(WSMessage{Message: "再来一个"})
+	var reply string
+	for {
+		var f Frame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatalf("读取帧失败: %v", err)
+		}
+		if f.Type == FrameDone {
+			assert.Equal(t, int64(3), f.ConversationID)
+			break
+		}
+		reply += f.Content
+	}
+	assert.Equal(t, "麻婆豆腐", reply)
+
+	// 之前的问答作为上下文发送给 AI
+	msgs := provider.Requests[0].Messages
+	assert.Len(t, msgs, 4)
+	assert.Equal(t, "水煮鱼", msgs[2].Content)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
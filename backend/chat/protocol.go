@@ -0,0 +1,141 @@
+package chat
+
+import (
+	"sync"
+	"time"
+
+	"backend/llm"
+
+	"github.com/gorilla/websocket"
+)
+
+// SubprotocolV1 结构化帧协议，客户端在 Sec-WebSocket-Protocol 请求头中声明后启用；
+// 未声明时使用旧版纯文本协议，兼容现有前端
+const SubprotocolV1 = "todayeat.chat.v1"
+
+// 帧类型
+const (
+	FrameDelta    = "delta"     // AI 回复的增量内容
+	FrameDone     = "done"      // 本轮回复结束，携带用量统计
+	FrameError    = "error"     // 出错，本轮回复结束
+	FrameDishCard = "dish_card" // 可以渲染为卡片的菜品
+	FramePing     = "ping"      // 心跳
+)
+
+// 错误码
+const (
+	ErrCodeBadRequest    = "bad_request"
+	ErrCodeAIUnavailable = "ai_unavailable"
+)
+
+// pingInterval 结构化协议下发送心跳帧的间隔
+const pingInterval = 25 * time.Second
+
+// Frame 结构化协议下服务端发送的每一帧，Seq 在同一连接内从 1 开始递增
+type Frame struct {
+	Type           string       `json:"type"`
+	Seq            int64        `json:"seq"`
+	Content        string       `json:"content,omitempty"`
+	ConversationID int64        `json:"conversation_id,omitempty"`
+	Usage          *llm.Usage   `json:"usage,omitempty"`
+	Error          *ErrorDetail `json:"error,omitempty"`
+	Dish           *DishCard    `json:"dish,omitempty"`
+}
+
+// ErrorDetail 错误详情
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// DishCard 菜品卡片
+type DishCard struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Image    string  `json:"image"`
+	Price    float64 `json:"price"`
+	Taste    string  `json:"taste"`
+	Score    float64 `json:"score"`
+	PriceMin int     `json:"priceMin"`
+	PriceMax int     `json:"priceMax"`
+}
+
+// frameWriter 按协商的协议向连接写帧，可被心跳协程并发调用
+type frameWriter struct {
+	conn       *websocket.Conn
+	structured bool
+
+	mu  sync.Mutex
+	seq int64
+}
+
+func newFrameWriter(conn *websocket.Conn) *frameWriter {
+	return &frameWriter{conn: conn, structured: conn.Subprotocol() == SubprotocolV1}
+}
+
+func (w *frameWriter) delta(content string) {
+	w.write(Frame{Type: FrameDelta, Content: content})
+}
+
+func (w *frameWriter) done(conversationID int64, usage llm.Usage) {
+	w.write(Frame{Type: FrameDone, ConversationID: conversationID, Usage: &usage})
+}
+
+func (w *frameWriter) fail(code, message string) {
+	w.write(Frame{Type: FrameError, Error: &ErrorDetail{Code: code, Message: message}})
+}
+
+func (w *frameWriter) dishCard(card DishCard) {
+	w.write(Frame{Type: FrameDishCard, Dish: &card})
+}
+
+func (w *frameWriter) ping() {
+	w.write(Frame{Type: FramePing})
+}
+
+// write 结构化协议下写 JSON 帧；旧版协议只写增量内容和错误文本，其它帧忽略。
+// 旧版协议没有结束帧，客户端以连接关闭判断回复结束，因此回复结束或出错后关闭连接，每个连接只回答一个问题
+func (w *frameWriter) write(f Frame) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.structured {
+		switch f.Type {
+		case FrameDelta:
+			w.conn.WriteMessage(websocket.TextMessage, []byte(f.Content))
+		case FrameError:
+			w.conn.WriteMessage(websocket.TextMessage, []byte(f.Error.Message))
+			w.closeLegacy()
+		case FrameDone:
+			w.closeLegacy()
+		}
+		return
+	}
+
+	w.seq++
+	f.Seq = w.seq
+	w.conn.WriteJSON(f)
+}
+
+// closeLegacy 发送正常关闭帧，之后读取客户端消息时返回错误，对话循环随之结束
+func (w *frameWriter) closeLegacy() {
+	w.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+}
+
+// keepAlive 结构化协议下定期发送心跳帧，直到 stop 被关闭
+func (w *frameWriter) keepAlive(stop <-chan struct{}) {
+	if !w.structured {
+		return
+	}
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.ping()
+		case <-stop:
+			return
+		}
+	}
+}
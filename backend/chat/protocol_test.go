@@ -0,0 +1,139 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/llm"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func dialV1(t *testing.T, provider llm.Provider) (*websocket.Conn, func()) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", ChatWSHandler(nil, provider, time.Minute))
+	server := httptest.NewServer(router)
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolV1}}
+	conn, _, err := dialer.Dial("ws"+server.URL[len("http"):]+"/ws", nil)
+	if err != nil {
+		server.Close()
+		t.Fatalf("连接失败: %v", err)
+	}
+	assert.Equal(t, SubprotocolV1, conn.Subprotocol())
+	return conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestStructuredFrames(t *testing.T) {
+	conn, closeFn := dialV1(t, &llm.Fake{Replies: []string{"麻婆豆腐"}, ChunkSize: 2})
+	defer closeFn()
+
+	conn.WriteJSON(WSMessage{Message: "推荐个辣的"})
+
+	var frames []Frame
+	for {
+		var f Frame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatalf("读取帧失败: %v", err)
+		}
+		frames = append(frames, f)
+		if f.Type == FrameDone {
+			break
+		}
+	}
+
+	// 两段增量 + 结束帧，序号递增
+	assert.Len(t, frames, 3)
+	assert.Equal(t, FrameDelta, frames[0].Type)
+	assert.Equal(t, "麻婆", frames[0].Content)
+	assert.Equal(t, "豆腐", frames[1].Content)
+	for i, f := range frames {
+		assert.Equal(t, int64(i+1), f.Seq)
+	}
+	assert.NotNil(t, frames[2].Usage)
+	assert.Equal(t, 4, frames[2].Usage.CompletionTokens)
+}
+
+func TestStructuredFrames_Error(t *testing.T) {
+	conn, closeFn := dialV1(t, &llm.Fake{Err: errors.New("timeout")})
+	defer closeFn()
+
+	conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	var f Frame
+	assert.NoError(t, conn.ReadJSON(&f))
+	assert.Equal(t, FrameError, f.Type)
+	assert.Equal(t, ErrCodeBadRequest, f.Error.Code)
+
+	conn.WriteJSON(WSMessage{Message: "你好"})
+	assert.NoError(t, conn.ReadJSON(&f))
+	assert.Equal(t, FrameError, f.Type)
+	assert.Equal(t, ErrCodeAIUnavailable, f.Error.Code)
+	assert.Equal(t, int64(2), f.Seq)
+}
+
+func TestLegacyProtocol_ClosesAfterAnswer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", ChatWSHandler(nil, &llm.Fake{Replies: []string{"麻婆豆腐"}, ChunkSize: 2}, time.Minute))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+server.URL[len("http"):]+"/ws", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// 旧版客户端收到纯文本增量，之后连接正常关闭表示回复结束
+	conn.WriteJSON(WSMessage{Message: "推荐个辣的"})
+	var reply string
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+			break
+		}
+		reply += string(data)
+	}
+	assert.Equal(t, "麻婆豆腐", reply)
+}
+
+func TestStructuredFrames_TurnTimeout(t *testing.T) {
+	// 上游发出一段内容后不再输出也不结束
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"麻婆\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", ChatWSHandler(nil, llm.NewOpenAI(upstream.URL, "key", "model"), 100*time.Millisecond))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolV1}}
+	conn, _, err := dialer.Dial("ws"+server.URL[len("http"):]+"/ws", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// 超时后本轮以错误帧结束
+	conn.WriteJSON(WSMessage{Message: "推荐个辣的"})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var f Frame
+	assert.NoError(t, conn.ReadJSON(&f))
+	assert.Equal(t, FrameDelta, f.Type)
+	assert.NoError(t, conn.ReadJSON(&f))
+	assert.Equal(t, FrameError, f.Type)
+	assert.Equal(t, ErrCodeAIUnavailable, f.Error.Code)
+}
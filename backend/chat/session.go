@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"unicode/utf8"
+
+	"backend/llm"
+)
+
+// 单个连接保留的对话历史上限，超出后从最早的轮次开始丢弃。
+// 按字符数粗略估算 token，中文场景下一个字约等于一个 token。
+const (
+	maxHistoryRunes    = 6000
+	maxHistoryMessages = 20
+)
+
+// session 一个 WebSocket 连接上的多轮对话状态
+type session struct {
+	system      string
+	history     []llm.Message // 不含系统提示
+	maxRunes    int
+	maxMessages int
+}
+
+func newSession(system string) *session {
+	return &session{system: system, maxRunes: maxHistoryRunes, maxMessages: maxHistoryMessages}
+}
+
+// add 追加一条消息并按预算裁剪历史
+func (s *session) add(role, content string) {
+	s.history = append(s.history, llm.Message{Role: role, Content: content})
+	s.trim()
+}
+
+// dropLast 撤销最后一条消息（AI 调用失败时用户消息不计入历史）
+func (s *session) dropLast() {
+	if len(s.history) > 0 {
+		s.history = s.history[:len(s.history)-1]
+	}
+}
+
+// reset 清空历史，开始新的对话
+func (s *session) reset() {
+	s.history = nil
+}
+
+// messages 返回发送给 AI 的完整消息列表
+func (s *session) messages() []llm.Message {
+	msgs := make([]llm.Message, 0, len(s.history)+1)
+	msgs = append(msgs, llm.Message{Role: llm.RoleSystem, Content: s.system})
+	return append(msgs, s.history...)
+}
+
+// trim 从最早的消息开始丢弃，直到满足条数和字数预算；最后一条消息始终保留，
+// 并保证历史不以 assistant 消息开头
+func (s *session) trim() {
+	total := 0
+	for _, m := range s.history {
+		total += utf8.RuneCountInString(m.Content)
+	}
+	for len(s.history) > 1 && (total > s.maxRunes || len(s.history) > s.maxMessages) {
+		total -= utf8.RuneCountInString(s.history[0].Content)
+		s.history = s.history[1:]
+		for len(s.history) > 1 && s.history[0].Role != llm.RoleUser {
+			total -= utf8.RuneCountInString(s.history[0].Content)
+			s.history = s.history[1:]
+		}
+	}
+}
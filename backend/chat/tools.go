@@ -0,0 +1,230 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+
+	"backend/llm"
+	"backend/profile"
+)
+
+// maxToolRounds 一轮回复中最多允许模型连续调用函数的次数，超过后要求模型直接回答
+const maxToolRounds = 4
+
+// 结构化协议下要求模型用 [dish:ID] 标注提到的菜品，服务端据此下发菜品卡片
+var dishRefPattern = regexp.MustCompile(`\[dish:(\d+)\]`)
+
+const dishRefPrompt = "回答中提到菜品时，在菜名后用 [dish:菜品ID] 标注，ID 必须来自工具返回的结果。"
+
+const toolsPrompt = "你可以调用工具查询菜品库，只推荐工具返回的真实菜品，不要编造菜品。"
+
+// toolbox 聊天中提供给模型的服务端工具，查询结果直接来自 dishes 表
+type toolbox struct {
+	db     *sql.DB
+	userID int              // 0 表示匿名用户，收藏和点赞工具不可用
+	seen   map[int]DishCard // 本轮工具返回过的菜品
+}
+
+func newToolbox(db *sql.DB, userID int) *toolbox {
+	return &toolbox{db: db, userID: userID, seen: map[int]DishCard{}}
+}
+
+// definitions 返回工具定义，没有数据库时不提供工具
+func (t *toolbox) definitions() []llm.Tool {
+	if t.db == nil {
+		return nil
+	}
+	return []llm.Tool{
+		{
+			Name:        "search_dishes",
+			Description: "按口味、价格或关键词搜索菜品，按评分从高到低返回",
+			Parameters: json.RawMessage(`{"type":"object","properties":{
+				"taste":{"type":"string","description":"口味关键词，如 辣、清淡、酸甜"},
+				"keyword":{"type":"string","description":"菜名或描述中的关键词"},
+				"max_price":{"type":"number","description":"最高价格（元）"},
+				"limit":{"type":"integer","description":"返回数量，最多 10"}}}`),
+		},
+		{
+			Name:        "get_dish_detail",
+			Description: "获取菜品详情",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"dish_id":{"type":"integer"}},"required":["dish_id"]}`),
+		},
+		{
+			Name:        "get_user_favorites",
+			Description: "获取当前用户收藏（点赞）的菜品",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{}}`),
+		},
+		{
+			Name:        "like_dish",
+			Description: "把菜品加入当前用户的收藏，只在用户明确要求时调用",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"dish_id":{"type":"integer"}},"required":["dish_id"]}`),
+		},
+	}
+}
+
+// toolDish 工具返回给模型的菜品信息
+type toolDish struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Price       float64 `json:"price"`
+	Taste       string  `json:"taste"`
+	Score       float64 `json:"score"`
+	Description string  `json:"description,omitempty"`
+}
+
+// call 执行一次函数调用，返回给模型的 JSON 结果；出错时结果中带 error 字段，让模型自行处理
+func (t *toolbox) call(ctx context.Context, call llm.ToolCall) string {
+	var args struct {
+		Taste    string  `json:"taste"`
+		Keyword  string  `json:"keyword"`
+		MaxPrice float64 `json:"max_price"`
+		Limit    int     `json:"limit"`
+		DishID   int     `json:"dish_id"`
+	}
+	if call.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return toolError("参数格式错误")
+		}
+	}
+
+	var result interface{}
+	var err error
+	switch call.Name {
+	case "search_dishes":
+		result, err = t.searchDishes(ctx, args.Taste, args.Keyword, args.MaxPrice, args.Limit)
+	case "get_dish_detail":
+		result, err = t.dishDetail(ctx, args.DishID)
+	case "get_user_favorites":
+		if t.userID == 0 {
+			return toolError("用户未登录")
+		}
+		result, err = t.favorites(ctx)
+	case "like_dish":
+		if t.userID == 0 {
+			return toolError("用户未登录")
+		}
+		result, err = t.like(ctx, args.DishID)
+	default:
+		return toolError("未知工具: " + call.Name)
+	}
+	if err != nil {
+		log.Printf("聊天工具 %s 执行失败: %v", call.Name, err)
+		return toolError(err.Error())
+	}
+
+	out, _ := json.Marshal(result)
+	return string(out)
+}
+
+func toolError(message string) string {
+	out, _ := json.Marshal(map[string]string{"error": message})
+	return string(out)
+}
+
+func (t *toolbox) searchDishes(ctx context.Context, taste, keyword string, maxPrice float64, limit int) ([]toolDish, error) {
+	if limit <= 0 || limit > 10 {
+		limit = 10
+	}
+	query := "SELECT id, name, price, description, taste, score, image_url FROM dishes WHERE 1 = 1"
+	var args []interface{}
+	if taste != "" {
+		query += " AND taste LIKE ?"
+		args = append(args, "%"+taste+"%")
+	}
+	if keyword != "" {
+		query += " AND (name LIKE ? OR description LIKE ?)"
+		args = append(args, "%"+keyword+"%", "%"+keyword+"%")
+	}
+	if maxPrice > 0 {
+		query += " AND price <= ?"
+		args = append(args, maxPrice)
+	}
+	query += " ORDER BY score DESC LIMIT ?"
+	args = append(args, limit)
+
+	return t.queryDishes(ctx, query, args...)
+}
+
+func (t *toolbox) dishDetail(ctx context.Context, dishID int) (*toolDish, error) {
+	dishes, err := t.queryDishes(ctx,
+		"SELECT id, name, price, description, taste, score, image_url FROM dishes WHERE id = ?", dishID)
+	if err != nil {
+		return nil, err
+	}
+	if len(dishes) == 0 {
+		return nil, fmt.Errorf("菜品 %d 不存在", dishID)
+	}
+	return &dishes[0], nil
+}
+
+func (t *toolbox) favorites(ctx context.Context) ([]toolDish, error) {
+	return t.queryDishes(ctx, `
+		SELECT d.id, d.name, d.price, d.description, d.taste, d.score, d.image_url
+		FROM `+"`like`"+` l
+		JOIN dishes d ON l.dish_id = d.id
+		WHERE l.user_id = ?
+	`, t.userID)
+}
+
+func (t *toolbox) like(ctx context.Context, dishID int) (map[string]interface{}, error) {
+	dish, err := t.dishDetail(ctx, dishID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.db.ExecContext(ctx, "INSERT IGNORE INTO `like`(user_id, dish_id) VALUES (?, ?)", t.userID, dishID); err != nil {
+		return nil, err
+	}
+	profile.RefreshAsync(t.db, t.userID)
+	return map[string]interface{}{"liked": true, "dish": dish}, nil
+}
+
+// queryDishes 查询菜品并记录到 seen 中，供之后生成菜品卡片
+func (t *toolbox) queryDishes(ctx context.Context, query string, args ...interface{}) ([]toolDish, error) {
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dishes := []toolDish{}
+	for rows.Next() {
+		var d toolDish
+		var imageURL string
+		if err := rows.Scan(&d.ID, &d.Name, &d.Price, &d.Description, &d.Taste, &d.Score, &imageURL); err != nil {
+			return nil, err
+		}
+		dishes = append(dishes, d)
+		t.seen[d.ID] = DishCard{
+			ID:       d.ID,
+			Name:     d.Name,
+			Image:    imageURL,
+			Price:    d.Price,
+			Taste:    d.Taste,
+			Score:    d.Score,
+			PriceMin: int(d.Price * 0.9),
+			PriceMax: int(d.Price * 1.2),
+		}
+	}
+	return dishes, rows.Err()
+}
+
+// referencedDishes 返回回答中用 [dish:ID] 标注、且确实由工具查到的菜品，按出现顺序去重
+func (t *toolbox) referencedDishes(answer string) []DishCard {
+	var cards []DishCard
+	used := map[int]bool{}
+	for _, m := range dishRefPattern.FindAllStringSubmatch(answer, -1) {
+		id, _ := strconv.Atoi(m[1])
+		card, ok := t.seen[id]
+		if !ok || used[id] {
+			continue
+		}
+		used[id] = true
+		cards = append(cards, card)
+	}
+	return cards
+}
@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/llm"
+	"backend/profile"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatWSHandler_ToolCalling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, price, description, taste, score, image_url FROM dishes WHERE 1 = 1 AND taste LIKE").
+		WithArgs("%辣%", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "description", "taste", "score", "image_url"}).
+			AddRow(5, "水煮鱼", 48.0, "麻辣鲜香", "麻辣", 4.9, "http://img.com/5.jpg"))
+
+	provider := &llm.Fake{Responses: []llm.Response{
+		{ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "search_dishes", Arguments: `{"taste":"辣"}`}}},
+		{Content: "推荐水煮鱼[dish:5]，也可以试试[dish:99]"},
+	}}
+	router := gin.New()
+	router.GET("/ws", ChatWSHandler(db, provider, time.Minute))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolV1}}
+	conn, _, err := dialer.Dial("ws"+server.URL[len("http"):]+"/ws", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	conn.WriteJSON(WSMessage{Message: "想吃辣的"})
+	var frames []Frame
+	for {
+		var f Frame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatalf("读取帧失败: %v", err)
+		}
+		frames = append(frames, f)
+		if f.Type == FrameDone {
+			break
+		}
+	}
+
+	// 只为工具真实查到的菜品下发卡片
+	var cards []DishCard
+	for _, f := range frames {
+		if f.Type == FrameDishCard {
+			cards = append(cards, *f.Dish)
+		}
+	}
+	assert.Len(t, cards, 1)
+	assert.Equal(t, 5, cards[0].ID)
+	assert.Equal(t, "水煮鱼", cards[0].Name)
+
+	// 工具结果作为 tool 消息发回给模型
+	assert.Len(t, provider.Requests, 2)
+	msgs := provider.Requests[1].Messages
+	last := msgs[len(msgs)-1]
+	assert.Equal(t, llm.RoleTool, last.Role)
+	assert.Equal(t, "call_1", last.ToolCallID)
+	assert.True(t, strings.Contains(last.Content, "水煮鱼"))
+	assert.True(t, strings.Contains(msgs[0].Content, "[dish:"))
+}
+
+func TestToolbox_AnonymousUser(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	tb := newToolbox(db, 0)
+	result := tb.call(context.Background(), llm.ToolCall{Name: "like_dish", Arguments: `{"dish_id":1}`})
+	assert.Contains(t, result, "用户未登录")
+}
+
+func TestToolbox_LikeRefreshesProfile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, price, description, taste, score, image_url FROM dishes WHERE id = ?").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "description", "taste", "score", "image_url"}).
+			AddRow(5, "水煮鱼", 48.0, "麻辣鲜香", "麻辣", 4.9, "http://img.com/5.jpg"))
+	mock.ExpectExec("INSERT IGNORE INTO `like`").WithArgs(3, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	// 点赞后在后台重新计算画像，这里只确认开始计算
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM recommend_history WHERE user_id = \?`).WillReturnError(sqlmock.ErrCancelled)
+
+	tb := newToolbox(db, 3)
+	result := tb.call(context.Background(), llm.ToolCall{Name: "like_dish", Arguments: `{"dish_id":5}`})
+	assert.Contains(t, result, `"liked":true`)
+	profile.Wait()
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,91 +1,106 @@
 package config
 
-import (
-	"encoding/json"
-	"os"
-)
+// AppConfig 应用完整配置，由 Load 从配置文件、环境变量和命令行参数合并得到
+type AppConfig struct {
+	DB      DBConfig      `json:"db" yaml:"db"`
+	Wx      WxConfig      `json:"wx" yaml:"wx"`
+	Server  ServerConfig  `json:"server" yaml:"server"`
+	AI      AIConfig      `json:"ai" yaml:"ai"`
+	Admin   AdminConfig   `json:"admin" yaml:"admin"`
+	Auth    AuthConfig    `json:"auth" yaml:"auth"`
+	Storage StorageConfig `json:"storage" yaml:"storage"`
+}
 
 // 数据库配置结构
 type DBConfig struct {
-	DBUser     string `json:"db_user"`
-	DBPassword string `json:"db_password"`
-	DBHost     string `json:"db_host"`
-	DBPort     int    `json:"db_port"`
-	DBName     string `json:"db_name"`
+	DBUser     string `json:"db_user" yaml:"db_user"`
+	DBPassword string `json:"db_password" yaml:"db_password"`
+	DBHost     string `json:"db_host" yaml:"db_host"`
+	DBPort     int    `json:"db_port" yaml:"db_port"`
+	DBName     string `json:"db_name" yaml:"db_name"`
 }
 
 // 微信小程序配置结构
 type WxConfig struct {
-	AppID     string `json:"app_id"`
-	AppSecret string `json:"app_secret"`
+	AppID     string `json:"app_id" yaml:"app_id"`
+	AppSecret string `json:"app_secret" yaml:"app_secret"`
+	BaseURL   string `json:"base_url" yaml:"base_url"` // 微信接口地址，为空时使用官方地址，fake 时使用本地模拟服务
+
+	SessionKeySecret string `json:"session_key_secret" yaml:"session_key_secret"` // 加密保存 session_key 的密钥，至少 32 字节
 }
 
 // 服务器配置
 type ServerConfig struct {
-	Domain string `json:"domain"`
+	Addr   string `json:"addr" yaml:"addr"`
+	Domain string `json:"domain" yaml:"domain"`
 }
 
 // AI配置
 type AIConfig struct {
-	APIKey string `json:"api_key"`
-}
+	Provider string `json:"provider" yaml:"provider"` // deepseek、openai（兼容 OpenAI 接口的服务）、fake（离线测试）或 none（不使用 AI）
+	BaseURL  string `json:"base_url" yaml:"base_url"` // 为空时使用对应服务的默认地址
+	Model    string `json:"model" yaml:"model"`       // 为空时使用对应服务的默认模型
+	APIKey   string `json:"api_key" yaml:"api_key"`
 
-// LoadDBConfig 读取数据库配置
-func LoadDBConfig(path string) (*DBConfig, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+	TimeoutSeconds int `json:"timeout_seconds" yaml:"timeout_seconds"` // 聊天中每轮回复的最长时间（秒），超时后本轮回复失败
+}
 
-	var cfg DBConfig
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
-		return nil, err
-	}
-	return &cfg, nil
+// 管理后台配置
+type AdminConfig struct {
+	Token string `json:"token" yaml:"token"` // 管理接口的访问令牌，为空时管理接口不可用
 }
 
-// LoadWxConfig 读取微信配置
-func LoadWxConfig(path string) (*WxConfig, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+// 登录鉴权配置
+type AuthConfig struct {
+	// Mode 鉴权模式，默认 strict 只认登录身份；迁移期间可以显式设为 compat（优先使用登录身份，
+	// 未登录时仍接受 user_id）或 legacy（信任客户端传入的 user_id）
+	Mode string `json:"mode" yaml:"mode"`
 
-	var cfg WxConfig
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
-		return nil, err
-	}
-	return &cfg, nil
+	SessionSecret string `json:"session_secret" yaml:"session_secret"` // session cookie 的签名密钥，至少 32 字节
+
+	// TokenKeys 访问令牌的签名密钥，格式为 "kid:secret,kid:secret"。第一个用于签发新令牌，
+	// 其余只用于校验，轮换密钥时把新密钥放在最前面，等旧令牌过期后再删除旧密钥
+	TokenKeys          string `json:"token_keys" yaml:"token_keys"`
+	AccessTokenMinutes int    `json:"access_token_minutes" yaml:"access_token_minutes"` // 访问令牌有效期（分钟）
+	RefreshTokenDays   int    `json:"refresh_token_days" yaml:"refresh_token_days"`     // 刷新令牌有效期（天）
 }
 
-// LoadServerConfig 读取服务器配置
-func LoadServerConfig(path string) (*ServerConfig, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+// 上传文件（头像、评论图片）的存储配置
+type StorageConfig struct {
+	Driver   string `json:"driver" yaml:"driver"`       // local（本地目录，只适合单实例）或 s3（兼容 S3 接口的对象存储）
+	LocalDir string `json:"local_dir" yaml:"local_dir"` // local 时的保存目录
 
-	var cfg ServerConfig
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
-		return nil, err
-	}
-	return &cfg, nil
+	S3Endpoint  string `json:"s3_endpoint" yaml:"s3_endpoint"` // 如 https://s3.us-east-1.amazonaws.com，fake 时使用本地模拟服务
+	S3Region    string `json:"s3_region" yaml:"s3_region"`
+	S3Bucket    string `json:"s3_bucket" yaml:"s3_bucket"`
+	S3AccessKey string `json:"s3_access_key" yaml:"s3_access_key"`
+	S3SecretKey string `json:"s3_secret_key" yaml:"s3_secret_key"`
+	S3PublicURL string `json:"s3_public_url" yaml:"s3_public_url"` // 文件的公开访问地址（如 CDN），为空时使用 endpoint/bucket
 }
 
-// LoadAIConfig 读取AI配置
-func LoadAIConfig(path string) (*AIConfig, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var cfg AIConfig
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
-		return nil, err
+// Default 返回带默认值的配置，作为各配置层合并的起点
+func Default() *AppConfig {
+	return &AppConfig{
+		DB: DBConfig{
+			DBHost: "127.0.0.1",
+			DBPort: 3306,
+		},
+		Server: ServerConfig{
+			Addr: ":8080",
+		},
+		AI: AIConfig{
+			Provider:       "deepseek",
+			TimeoutSeconds: 120,
+		},
+		Auth: AuthConfig{
+			Mode:               "strict",
+			AccessTokenMinutes: 15,
+			RefreshTokenDays:   30,
+		},
+		Storage: StorageConfig{
+			Driver:   "local",
+			LocalDir: "data",
+			S3Region: "us-east-1",
+		},
 	}
-	return &cfg, nil
 }
@@ -0,0 +1,316 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量前缀，例如 db.host 对应 TODAYEAT_DB_HOST
+const EnvPrefix = "TODAYEAT_"
+
+// 未指定配置文件时依次尝试的默认路径，都不存在则只使用环境变量和命令行参数
+var defaultPaths = []string{"config/config.yaml", "config/config.yml", "config/config.json"}
+
+// ValidationError 汇总所有配置校验问题，一次性报告而不是遇到第一个就返回
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "配置无效: " + strings.Join(e.Problems, "; ")
+}
+
+// binding 描述一个可以被环境变量和命令行参数覆盖的配置项
+type binding struct {
+	key   string // 形如 db.host，环境变量为 TODAYEAT_DB_HOST，命令行参数为 -db-host
+	usage string
+	str   *string
+	num   *int
+}
+
+func bindings(cfg *AppConfig) []binding {
+	return []binding{
+		{key: "db.user", usage: "数据库用户名", str: &cfg.DB.DBUser},
+		{key: "db.password", usage: "数据库密码", str: &cfg.DB.DBPassword},
+		{key: "db.host", usage: "数据库地址", str: &cfg.DB.DBHost},
+		{key: "db.port", usage: "数据库端口", num: &cfg.DB.DBPort},
+		{key: "db.name", usage: "数据库名", str: &cfg.DB.DBName},
+		{key: "wx.app_id", usage: "微信小程序 AppID", str: &cfg.Wx.AppID},
+		{key: "wx.app_secret", usage: "微信小程序 AppSecret", str: &cfg.Wx.AppSecret},
+		{key: "wx.base_url", usage: "微信接口地址，为空时使用官方地址，fake 为本地模拟服务（需要 -tags dev 编译）", str: &cfg.Wx.BaseURL},
+		{key: "wx.session_key_secret", usage: "加密保存微信 session_key 的密钥（至少 32 字节）", str: &cfg.Wx.SessionKeySecret},
+		{key: "server.addr", usage: "服务监听地址", str: &cfg.Server.Addr},
+		{key: "server.domain", usage: "对外访问域名，用于拼接头像等资源地址", str: &cfg.Server.Domain},
+		{key: "ai.provider", usage: "AI 服务类型：deepseek、openai、fake 或 none（不使用 AI）", str: &cfg.AI.Provider},
+		{key: "ai.base_url", usage: "AI 接口地址（兼容 OpenAI 接口）", str: &cfg.AI.BaseURL},
+		{key: "ai.model", usage: "AI 模型名称", str: &cfg.AI.Model},
+		{key: "ai.api_key", usage: "AI 接口密钥", str: &cfg.AI.APIKey},
+		{key: "ai.timeout_seconds", usage: "聊天中每轮回复的最长时间（秒）", num: &cfg.AI.TimeoutSeconds},
+		{key: "auth.mode", usage: "鉴权模式：legacy、compat 或 strict", str: &cfg.Auth.Mode},
+		{key: "auth.session_secret", usage: "session cookie 签名密钥（至少 32 字节）", str: &cfg.Auth.SessionSecret},
+		{key: "auth.token_keys", usage: "访问令牌签名密钥，格式 kid:secret,kid:secret，第一个用于签发", str: &cfg.Auth.TokenKeys},
+		{key: "auth.access_token_minutes", usage: "访问令牌有效期（分钟）", num: &cfg.Auth.AccessTokenMinutes},
+		{key: "auth.refresh_token_days", usage: "刷新令牌有效期（天）", num: &cfg.Auth.RefreshTokenDays},
+		{key: "admin.token", usage: "管理接口访问令牌（请求头 X-Admin-Token），为空时禁用管理接口", str: &cfg.Admin.Token},
+		{key: "storage.driver", usage: "上传文件存储：local 或 s3", str: &cfg.Storage.Driver},
+		{key: "storage.local_dir", usage: "local 存储的保存目录", str: &cfg.Storage.LocalDir},
+		{key: "storage.s3_endpoint", usage: "S3 接口地址，fake 为本地模拟服务（需要 -tags dev 编译）", str: &cfg.Storage.S3Endpoint},
+		{key: "storage.s3_region", usage: "S3 区域", str: &cfg.Storage.S3Region},
+		{key: "storage.s3_bucket", usage: "S3 存储桶", str: &cfg.Storage.S3Bucket},
+		{key: "storage.s3_access_key", usage: "S3 Access Key", str: &cfg.Storage.S3AccessKey},
+		{key: "storage.s3_secret_key", usage: "S3 Secret Key", str: &cfg.Storage.S3SecretKey},
+		{key: "storage.s3_public_url", usage: "上传文件的公开访问地址（如 CDN），为空时使用 S3 地址", str: &cfg.Storage.S3PublicURL},
+	}
+}
+
+func (b binding) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(b.key, ".", "_"))
+}
+
+func (b binding) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(b.key)
+}
+
+func (b binding) set(value string) error {
+	if b.num != nil {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s 需要整数，实际为 %q", b.key, value)
+		}
+		*b.num = n
+		return nil
+	}
+	*b.str = value
+	return nil
+}
+
+// Load 按 默认值 < 配置文件 < 环境变量 < 命令行参数 的优先级合并配置并校验
+//
+// 配置文件通过 -config 参数或 TODAYEAT_CONFIG 环境变量指定，支持 JSON 和 YAML；
+// 敏感配置可以改用 TODAYEAT_XXX_FILE 指向一个文件（如容器挂载的 secret）。
+func Load(args []string) (*AppConfig, error) {
+	cfg, _, err := load(args)
+	return cfg, err
+}
+
+// load 与 Load 相同，额外返回实际读取的配置文件路径（没有则为空）
+func load(args []string) (*AppConfig, string, error) {
+	cfg := Default()
+	binds := bindings(cfg)
+
+	fs := flag.NewFlagSet("todayeat", flag.ContinueOnError)
+	path := fs.String("config", "", "配置文件路径（JSON 或 YAML）")
+	type flagValue struct {
+		b     binding
+		value string
+	}
+	var flagValues []flagValue
+	for _, b := range binds {
+		b := b
+		fs.Func(b.flagName(), b.usage, func(v string) error {
+			flagValues = append(flagValues, flagValue{b, v})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	// 1. 配置文件
+	file := *path
+	if file == "" {
+		file = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if file == "" {
+		for _, p := range defaultPaths {
+			if _, err := os.Stat(p); err == nil {
+				file = p
+				break
+			}
+		}
+	}
+	if file != "" {
+		if err := decodeFile(file, cfg); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// 2. 环境变量
+	var problems []string
+	for _, b := range binds {
+		value, ok, err := lookupEnv(b.envName())
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := b.set(value); err != nil {
+			problems = append(problems, fmt.Sprintf("环境变量 %s: %v", b.envName(), err))
+		}
+	}
+
+	// 3. 命令行参数
+	for _, fv := range flagValues {
+		if err := fv.b.set(fv.value); err != nil {
+			problems = append(problems, fmt.Sprintf("参数 -%s: %v", fv.b.flagName(), err))
+		}
+	}
+
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, file, &ValidationError{Problems: problems}
+	}
+	return cfg, file, nil
+}
+
+// lookupEnv 读取环境变量，NAME 未设置时再尝试从 NAME_FILE 指向的文件读取
+func lookupEnv(name string) (string, bool, error) {
+	if v, ok := os.LookupEnv(name); ok {
+		return v, true, nil
+	}
+	p, ok := os.LookupEnv(name + "_FILE")
+	if !ok {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", false, fmt.Errorf("读取 %s_FILE 失败: %v", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+// decodeFile 根据扩展名解析 JSON 或 YAML 配置文件，覆盖 cfg 中已有的值
+func decodeFile(path string, cfg *AppConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	default:
+		err = json.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// validate 检查必填项，返回所有问题
+func (c *AppConfig) validate() []string {
+	var problems []string
+	required := func(value, key string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, fmt.Sprintf("缺少 %s（环境变量 %s）", key, binding{key: key}.envName()))
+		}
+	}
+
+	required(c.DB.DBUser, "db.user")
+	required(c.DB.DBHost, "db.host")
+	required(c.DB.DBName, "db.name")
+	if c.DB.DBPort <= 0 || c.DB.DBPort > 65535 {
+		problems = append(problems, fmt.Sprintf("db.port 超出范围: %d", c.DB.DBPort))
+	}
+	required(c.Wx.AppID, "wx.app_id")
+	required(c.Wx.AppSecret, "wx.app_secret")
+	required(c.Wx.SessionKeySecret, "wx.session_key_secret")
+	if c.Wx.SessionKeySecret != "" && len(c.Wx.SessionKeySecret) < MinSecretLength {
+		problems = append(problems, fmt.Sprintf("wx.session_key_secret 至少需要 %d 字节", MinSecretLength))
+	}
+	required(c.Server.Addr, "server.addr")
+	required(c.Server.Domain, "server.domain")
+	switch c.AI.Provider {
+	case "deepseek":
+		required(c.AI.APIKey, "ai.api_key")
+	case "openai":
+		required(c.AI.APIKey, "ai.api_key")
+		required(c.AI.Model, "ai.model")
+	case "fake", "none":
+	default:
+		problems = append(problems, fmt.Sprintf("ai.provider 不支持: %q", c.AI.Provider))
+	}
+	if c.AI.TimeoutSeconds <= 0 {
+		problems = append(problems, fmt.Sprintf("ai.timeout_seconds 必须大于 0: %d", c.AI.TimeoutSeconds))
+	}
+	switch c.Auth.Mode {
+	case "legacy", "compat", "strict":
+	default:
+		problems = append(problems, fmt.Sprintf("auth.mode 不支持: %q", c.Auth.Mode))
+	}
+	required(c.Auth.SessionSecret, "auth.session_secret")
+	if c.Auth.SessionSecret != "" && len(c.Auth.SessionSecret) < MinSecretLength {
+		problems = append(problems, fmt.Sprintf("auth.session_secret 至少需要 %d 字节", MinSecretLength))
+	}
+	required(c.Auth.TokenKeys, "auth.token_keys")
+	if c.Auth.TokenKeys != "" {
+		if _, err := ParseTokenKeys(c.Auth.TokenKeys); err != nil {
+			problems = append(problems, "auth.token_keys: "+err.Error())
+		}
+	}
+	if c.Auth.AccessTokenMinutes <= 0 {
+		problems = append(problems, fmt.Sprintf("auth.access_token_minutes 必须大于 0: %d", c.Auth.AccessTokenMinutes))
+	}
+	if c.Auth.RefreshTokenDays <= 0 {
+		problems = append(problems, fmt.Sprintf("auth.refresh_token_days 必须大于 0: %d", c.Auth.RefreshTokenDays))
+	}
+	switch c.Storage.Driver {
+	case "local":
+		required(c.Storage.LocalDir, "storage.local_dir")
+	case "s3":
+		required(c.Storage.S3Endpoint, "storage.s3_endpoint")
+		required(c.Storage.S3Region, "storage.s3_region")
+		required(c.Storage.S3Bucket, "storage.s3_bucket")
+		required(c.Storage.S3AccessKey, "storage.s3_access_key")
+		required(c.Storage.S3SecretKey, "storage.s3_secret_key")
+	default:
+		problems = append(problems, fmt.Sprintf("storage.driver 不支持: %q", c.Storage.Driver))
+	}
+	return problems
+}
+
+// MinSecretLength session、令牌签名和 session_key 加密密钥的最小长度（字节）
+const MinSecretLength = 32
+
+// TokenKey 一个访问令牌签名密钥
+type TokenKey struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseTokenKeys 解析 auth.token_keys，返回的第一个密钥用于签发新令牌
+func ParseTokenKeys(spec string) ([]TokenKey, error) {
+	var keys []TokenKey
+	seen := map[string]bool{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(item, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("密钥格式应为 kid:secret")
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("密钥 ID 重复: %s", id)
+		}
+		if len(secret) < MinSecretLength {
+			return nil, fmt.Errorf("密钥 %s 至少需要 %d 字节", id, MinSecretLength)
+		}
+		seen[id] = true
+		keys = append(keys, TokenKey{ID: id, Secret: []byte(secret)})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("至少需要一个密钥")
+	}
+	return keys, nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testYAML = `
+db:
+  db_user: file_user
+  db_password: file_pass
+  db_host: db.local
+  db_port: 3307
+  db_name: todayeat
+wx:
+  app_id: wx123
+  app_secret: wxsecret
+  session_key_secret: 0123456789abcdef0123456789abcdef
+server:
+  domain: http://file.com
+ai:
+  api_key: file-key
+auth:
+  session_secret: 0123456789abcdef0123456789abcdef
+  token_keys: k1:0123456789abcdef0123456789abcdef
+`
+
+func writeTestConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("写入测试配置失败: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Layering(t *testing.T) {
+	path := writeTestConfig(t, "config.yaml", testYAML)
+	t.Setenv("TODAYEAT_DB_PASSWORD", "env_pass")
+	t.Setenv("TODAYEAT_SERVER_DOMAIN", "http://env.com")
+
+	cfg, err := Load([]string{"-config", path, "-server-domain", "http://flag.com"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "file_user", cfg.DB.DBUser)           // 配置文件
+	assert.Equal(t, 3307, cfg.DB.DBPort)                  // 配置文件
+	assert.Equal(t, "env_pass", cfg.DB.DBPassword)        // 环境变量覆盖文件
+	assert.Equal(t, "http://flag.com", cfg.Server.Domain) // 命令行覆盖环境变量
+	assert.Equal(t, ":8080", cfg.Server.Addr)             // 默认值
+	assert.Equal(t, "strict", cfg.Auth.Mode)              // 默认只认登录身份
+	assert.Equal(t, 120, cfg.AI.TimeoutSeconds)           // 默认值
+}
+
+func TestLoad_JSONAndSecretFile(t *testing.T) {
+	path := writeTestConfig(t, "config.json", `{"db":{"db_user":"u","db_name":"n"},"wx":{"app_id":"a","app_secret":"s","session_key_secret":"0123456789abcdef0123456789abcdef"},"server":{"domain":"http://d"},"auth":{"session_secret":"0123456789abcdef0123456789abcdef","token_keys":"k1:0123456789abcdef0123456789abcdef"}}`)
+	secret := writeTestConfig(t, "ai_key", "secret-key\n")
+	t.Setenv("TODAYEAT_CONFIG", path)
+	t.Setenv("TODAYEAT_AI_API_KEY_FILE", secret)
+
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-key", cfg.AI.APIKey)
+	assert.Equal(t, "127.0.0.1", cfg.DB.DBHost)
+}
+
+func TestLoad_AggregatedErrors(t *testing.T) {
+	path := writeTestConfig(t, "config.yaml", "db:\n  db_user: u\n")
+	t.Setenv("TODAYEAT_DB_PORT", "abc")
+
+	_, err := Load([]string{"-config", path})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("期望 ValidationError，实际: %v", err)
+	}
+	assert.Contains(t, err.Error(), "TODAYEAT_DB_PORT")
+	assert.Contains(t, err.Error(), "db.name")
+	assert.Contains(t, err.Error(), "wx.app_id")
+	assert.Contains(t, err.Error(), "wx.session_key_secret")
+	assert.Contains(t, err.Error(), "ai.api_key")
+	assert.Contains(t, err.Error(), "auth.session_secret")
+	assert.Contains(t, err.Error(), "auth.token_keys")
+}
+
+func TestLoad_Storage(t *testing.T) {
+	path := writeTestConfig(t, "config.yaml", testYAML)
+
+	cfg, err := Load([]string{"-config", path})
+	assert.NoError(t, err)
+	assert.Equal(t, "local", cfg.Storage.Driver) // 默认值
+	assert.Equal(t, "data", cfg.Storage.LocalDir)
+
+	// s3 缺少存储桶和密钥
+	t.Setenv("TODAYEAT_STORAGE_DRIVER", "s3")
+	t.Setenv("TODAYEAT_STORAGE_S3_ENDPOINT", "fake")
+	_, err = Load([]string{"-config", path})
+	assert.Contains(t, err.Error(), "storage.s3_bucket")
+	assert.Contains(t, err.Error(), "storage.s3_secret_key")
+
+	_, err = Load([]string{"-config", path, "-storage-driver", "ftp"})
+	assert.Contains(t, err.Error(), "storage.driver")
+}
+
+func TestLoad_MissingExplicitFile(t *testing.T) {
+	_, err := Load([]string{"-config", filepath.Join(t.TempDir(), "nope.yaml")})
+	assert.Error(t, err)
+}
+
+func TestParseTokenKeys(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	keys, err := ParseTokenKeys(" new:" + secret + "x, old:" + secret + " ")
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, "new", keys[0].ID)
+		assert.Equal(t, secret+"x", string(keys[0].Secret))
+		assert.Equal(t, "old", keys[1].ID)
+	}
+
+	for _, spec := range []string{"", "nokid", ":" + secret, "k1:short", "k1:" + secret + ",k1:" + secret} {
+		_, err := ParseTokenKeys(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot 返回当前生效的配置，处理器在每次请求时调用它读取配置，而不是自己打开配置文件
+type Snapshot func() *AppConfig
+
+// Static 把固定的配置包装成 Snapshot，用于测试或不需要热更新的场景
+func Static(cfg *AppConfig) Snapshot {
+	return func() *AppConfig { return cfg }
+}
+
+// Watcher 定期检查配置文件是否变化，校验通过后原子替换当前配置快照。
+// 新配置无效时记录警告并继续使用旧快照。
+//
+// 只有处理器通过 Snapshot 读取的配置（如 server.domain）会即时生效，
+// 数据库连接、监听地址等在启动时使用的配置仍需重启服务。
+type Watcher struct {
+	args     []string
+	path     string
+	interval time.Duration
+
+	current atomic.Pointer[AppConfig]
+	stamps  map[string]fileStamp
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher 加载一次配置，失败时直接返回错误；args 与 Load 的参数相同
+func NewWatcher(args []string, interval time.Duration) (*Watcher, error) {
+	cfg, path, err := load(args)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		args:     args,
+		path:     path,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+	w.current.Store(cfg)
+	w.stamps = w.statFiles()
+	return w, nil
+}
+
+// Current 返回当前配置快照，可以作为 Snapshot 传给处理器；返回值不应被修改
+func (w *Watcher) Current() *AppConfig {
+	return w.current.Load()
+}
+
+// Start 在后台开始轮询配置文件
+func (w *Watcher) Start() {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.check()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止轮询
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// check 在被监视的文件发生变化时重新加载配置
+func (w *Watcher) check() {
+	stamps := w.statFiles()
+	if sameStamps(stamps, w.stamps) {
+		return
+	}
+	w.stamps = stamps
+	if err := w.Reload(); err != nil {
+		log.Printf("⚠️ 配置文件变更无效，继续使用旧配置: %v", err)
+	}
+}
+
+// Reload 立即重新加载配置，校验失败时保留旧快照并返回错误
+func (w *Watcher) Reload() error {
+	cfg, _, err := load(w.args)
+	if err != nil {
+		return err
+	}
+	old := w.current.Swap(cfg)
+	if old != nil && (old.DB != cfg.DB || old.Server.Addr != cfg.Server.Addr) {
+		log.Printf("⚠️ 数据库或监听地址配置已变更，需要重启服务才能生效")
+	}
+	log.Printf("🔄 配置已重新加载")
+	return nil
+}
+
+// statFiles 记录配置文件以及 TODAYEAT_*_FILE 指向的密钥文件的修改时间
+func (w *Watcher) statFiles() map[string]fileStamp {
+	paths := []string{}
+	if w.path != "" {
+		paths = append(paths, w.path)
+	}
+	for _, b := range bindings(&AppConfig{}) {
+		if p, ok := os.LookupEnv(b.envName() + "_FILE"); ok {
+			paths = append(paths, p)
+		}
+	}
+
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			// 文件暂时不存在（如编辑器先删除再写入）也算作变化
+			stamps[p] = fileStamp{}
+			continue
+		}
+		stamps[p] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, s := range a {
+		if o, ok := b[p]; !ok || !o.modTime.Equal(s.modTime) || o.size != s.size {
+			return false
+		}
+	}
+	return true
+}
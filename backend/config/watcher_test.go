@@ -0,0 +1,35 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher_Reload(t *testing.T) {
+	path := writeTestConfig(t, "config.yaml", testYAML)
+	w, err := NewWatcher([]string{"-config", path}, time.Hour)
+	if err != nil {
+		t.Fatalf("创建 Watcher 失败: %v", err)
+	}
+	assert.Equal(t, "http://file.com", w.Current().Server.Domain)
+
+	// 1. 合法修改：替换快照
+	updated := strings.Replace(testYAML, "http://file.com", "http://new.com", 1)
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatalf("写入配置失败: %v", err)
+	}
+	w.check()
+	assert.Equal(t, "http://new.com", w.Current().Server.Domain)
+
+	// 2. 非法修改：保留旧快照
+	if err := os.WriteFile(path, []byte("db: [broken"), 0644); err != nil {
+		t.Fatalf("写入配置失败: %v", err)
+	}
+	w.check()
+	assert.Equal(t, "http://new.com", w.Current().Server.Domain)
+	assert.Error(t, w.Reload())
+}
@@ -0,0 +1,12 @@
+package llm
+
+// DeepSeek 默认配置
+const (
+	DeepSeekBaseURL = "https://api.deepseek.com/v1"
+	DeepSeekModel   = "deepseek-chat"
+)
+
+// NewDeepSeek 创建 DeepSeek Provider，DeepSeek 的接口与 OpenAI 兼容
+func NewDeepSeek(apiKey string) *OpenAI {
+	return NewOpenAI(DeepSeekBaseURL, apiKey, DeepSeekModel)
+}
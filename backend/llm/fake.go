@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"unicode/utf8"
+)
+
+// Fake 确定性的离线 Provider，用于测试和本地开发。
+//
+// 按顺序返回 Replies 中的回复，用完后重复最后一条；Replies 为空时回显最后一条用户消息。
+// 需要模拟函数调用时使用 Responses，它优先于 Replies。
+// Err 不为空时所有调用都返回该错误。收到的请求记录在 Requests 中。
+type Fake struct {
+	Replies   []string
+	Responses []Response
+	Err       error
+	ChunkSize int // 流式返回时每段的字符数，为 0 时整条回复作为一段
+
+	mu       sync.Mutex
+	Requests []Request
+}
+
+// Complete 实现 Provider
+func (f *Fake) Complete(ctx context.Context, req Request) (*Response, error) {
+	return f.next(req)
+}
+
+// Stream 实现 Provider
+func (f *Fake) Stream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	resp, err := f.next(req)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(resp.Content)
+	size := f.ChunkSize
+	if size <= 0 {
+		size = len(runes)
+	}
+	for start := 0; start < len(runes); start += size {
+		end := start + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		onDelta(string(runes[start:end]))
+	}
+	return resp, nil
+}
+
+func (f *Fake) next(req Request) (*Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := len(f.Requests)
+	f.Requests = append(f.Requests, req)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	var resp Response
+	switch {
+	case len(f.Responses) > 0:
+		resp = f.Responses[min(n, len(f.Responses)-1)]
+	case len(f.Replies) > 0:
+		resp.Content = f.Replies[min(n, len(f.Replies)-1)]
+	default:
+		for i := len(req.Messages) - 1; i >= 0; i-- {
+			if req.Messages[i].Role == RoleUser {
+				resp.Content = req.Messages[i].Content
+				break
+			}
+		}
+	}
+	resp.Usage = fakeUsage(req, resp.Content)
+	return &resp, nil
+}
+
+// fakeUsage 按字符数粗略估算用量
+func fakeUsage(req Request, reply string) Usage {
+	prompt := 0
+	for _, m := range req.Messages {
+		prompt += utf8.RuneCountInString(m.Content)
+	}
+	completion := utf8.RuneCountInString(reply)
+	return Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
@@ -0,0 +1,106 @@
+// Package llm 封装大模型调用，推荐和聊天模块只依赖 Provider 接口，
+// 具体使用 DeepSeek、其他兼容 OpenAI 接口的服务还是离线的 Fake 由配置决定。
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"backend/config"
+)
+
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message 对话中的一条消息
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // assistant 消息中模型发起的函数调用
+	ToolCallID string     `json:"tool_call_id,omitempty"` // tool 消息对应的调用 ID
+}
+
+// Tool 提供给模型调用的函数
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // 参数的 JSON Schema
+}
+
+// ToolCall 模型发起的一次函数调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON 格式的参数
+}
+
+// Request 一次对话补全请求
+type Request struct {
+	Messages    []Message
+	Tools       []Tool
+	Temperature float64 // 为 0 时使用服务端默认值
+	JSONMode    bool    // 要求模型输出 JSON 对象（提示词中需要说明 JSON 格式）
+}
+
+// Usage token 用量统计
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add 累加另一次调用的用量
+func (u *Usage) Add(o Usage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.TotalTokens += o.TotalTokens
+}
+
+// Response 补全结果，流式调用时 Content 为拼接后的完整回复。
+// ToolCalls 不为空时表示模型要求先执行这些函数，再把结果发回给它
+type Response struct {
+	Content   string
+	ToolCalls []ToolCall
+	Usage     Usage
+}
+
+// Provider 大模型服务
+type Provider interface {
+	// Complete 一次性返回完整回复
+	Complete(ctx context.Context, req Request) (*Response, error)
+	// Stream 流式返回，每收到一段增量内容调用一次 onDelta
+	Stream(ctx context.Context, req Request, onDelta func(string)) (*Response, error)
+}
+
+// New 根据配置创建 Provider，provider 为 none 时返回 nil，表示不使用 AI
+func New(cfg config.AIConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "none":
+		return nil, nil
+	case "", "deepseek":
+		p := NewDeepSeek(cfg.APIKey)
+		if cfg.BaseURL != "" {
+			p.BaseURL = cfg.BaseURL
+		}
+		if cfg.Model != "" {
+			p.Model = cfg.Model
+		}
+		return p, nil
+	case "openai":
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = OpenAIBaseURL
+		}
+		return NewOpenAI(baseURL, cfg.APIKey, cfg.Model), nil
+	case "fake":
+		return &Fake{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的 AI 服务类型: %s", cfg.Provider)
+	}
+}
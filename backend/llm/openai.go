@@ -0,0 +1,259 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIBaseURL OpenAI 官方接口地址
+const OpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAI 兼容 OpenAI Chat Completions 接口的服务（DeepSeek、通义、本地 vLLM 等）
+type OpenAI struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client // 为空时使用 defaultHTTPClient
+}
+
+// defaultHTTPClient 调用 AI 接口的默认客户端。流式回复可能持续较长时间，不设置整体超时，
+// 由调用方的 ctx 限制每轮对话的总时长；这里只限制建立连接和等待响应头的时间，避免上游无响应时一直挂起
+var defaultHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          10,
+	},
+}
+
+// NewOpenAI 创建兼容 OpenAI 接口的 Provider
+func NewOpenAI(baseURL, apiKey, model string) *OpenAI {
+	return &OpenAI{BaseURL: baseURL, APIKey: apiKey, Model: model}
+}
+
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []wireMessage  `json:"messages"`
+	Tools         []wireTool     `json:"tools,omitempty"`
+	Temperature   float64        `json:"temperature,omitempty"`
+	ResponseFmt   *responseFmt   `json:"response_format,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type responseFmt struct {
+	Type string `json:"type"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message wireMessage `json:"message"`
+		Delta   wireMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// 以下为 OpenAI 接口中消息和函数调用的格式
+
+type wireMessage struct {
+	Role       string         `json:"role,omitempty"`
+	Content    string         `json:"content"`
+	ToolCalls  []wireToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type wireToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type wireTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+func toWireMessages(msgs []Message) []wireMessage {
+	out := make([]wireMessage, 0, len(msgs))
+	for _, m := range msgs {
+		wm := wireMessage{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		for i, call := range m.ToolCalls {
+			wc := wireToolCall{Index: i, ID: call.ID, Type: "function"}
+			wc.Function.Name = call.Name
+			wc.Function.Arguments = call.Arguments
+			wm.ToolCalls = append(wm.ToolCalls, wc)
+		}
+		out = append(out, wm)
+	}
+	return out
+}
+
+func toWireTools(tools []Tool) []wireTool {
+	var out []wireTool
+	for _, t := range tools {
+		wt := wireTool{Type: "function"}
+		wt.Function.Name = t.Name
+		wt.Function.Description = t.Description
+		wt.Function.Parameters = t.Parameters
+		out = append(out, wt)
+	}
+	return out
+}
+
+func fromWireToolCalls(calls []wireToolCall) []ToolCall {
+	var out []ToolCall
+	for _, c := range calls {
+		out = append(out, ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments})
+	}
+	return out
+}
+
+// Complete 实现 Provider
+func (p *OpenAI) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.do(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var cr chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		return nil, fmt.Errorf("AI 响应解析失败: %v", err)
+	}
+	if len(cr.Choices) == 0 {
+		return nil, errors.New("AI 未返回结果")
+	}
+
+	msg := cr.Choices[0].Message
+	out := &Response{Content: msg.Content, ToolCalls: fromWireToolCalls(msg.ToolCalls)}
+	if cr.Usage != nil {
+		out.Usage = *cr.Usage
+	}
+	return out, nil
+}
+
+// Stream 实现 Provider，解析 SSE 格式的 "data: {...}" 行。
+// 函数调用的参数会分多段到达，按 index 拼接后放在 Response.ToolCalls 中
+func (p *OpenAI) Stream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	resp, err := p.do(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	out := &Response{}
+	var content strings.Builder
+	var calls []wireToolCall
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && bytes.HasPrefix(line, []byte("data: ")) {
+			jsonPart := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data: ")))
+			if bytes.Equal(jsonPart, []byte("[DONE]")) {
+				break
+			}
+			var piece chatResponse
+			if json.Unmarshal(jsonPart, &piece) == nil {
+				if len(piece.Choices) > 0 {
+					delta := piece.Choices[0].Delta
+					if delta.Content != "" {
+						content.WriteString(delta.Content)
+						onDelta(delta.Content)
+					}
+					for _, tc := range delta.ToolCalls {
+						for len(calls) <= tc.Index {
+							calls = append(calls, wireToolCall{Index: len(calls)})
+						}
+						call := &calls[tc.Index]
+						if tc.ID != "" {
+							call.ID = tc.ID
+						}
+						call.Function.Name += tc.Function.Name
+						call.Function.Arguments += tc.Function.Arguments
+					}
+				}
+				if piece.Usage != nil {
+					out.Usage = *piece.Usage
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out.Content = content.String()
+	out.ToolCalls = fromWireToolCalls(calls)
+	return out, nil
+}
+
+func (p *OpenAI) do(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	body := chatRequest{
+		Model:       p.Model,
+		Messages:    toWireMessages(req.Messages),
+		Tools:       toWireTools(req.Tools),
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+	if req.JSONMode {
+		body.ResponseFmt = &responseFmt{Type: "json_object"}
+	}
+	if stream {
+		body.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimRight(p.BaseURL, "/") + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("AI 接口返回错误状态 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
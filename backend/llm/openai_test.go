@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAI_Complete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+
+		var body chatRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "test-model", body.Model)
+		assert.False(t, body.Stream)
+
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"推荐：宫保鸡丁"}}],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}`)
+	}))
+	defer server.Close()
+
+	p := NewOpenAI(server.URL+"/v1", "key", "test-model")
+	resp, err := p.Complete(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "推荐：宫保鸡丁", resp.Content)
+	assert.Equal(t, 8, resp.Usage.TotalTokens)
+}
+
+func TestOpenAI_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"你\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"好\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"total_tokens\":7}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var deltas []string
+	p := NewOpenAI(server.URL, "key", "m")
+	resp, err := p.Stream(context.Background(), Request{}, func(s string) { deltas = append(deltas, s) })
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"你", "好"}, deltas)
+	assert.Equal(t, "你好", resp.Content)
+	assert.Equal(t, 7, resp.Usage.TotalTokens)
+}
+
+func TestOpenAI_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad key", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAI(server.URL, "key", "m").Complete(context.Background(), Request{})
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestOpenAI_StreamToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body chatRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Len(t, body.Tools, 1)
+		assert.Equal(t, "search_dishes", body.Tools[0].Function.Name)
+
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"search_dishes\",\"arguments\":\"{\\\"taste\\\":\"}}]}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"\\\"辣\\\"}\"}}]}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	req := Request{Tools: []Tool{{Name: "search_dishes", Parameters: json.RawMessage(`{"type":"object"}`)}}}
+	resp, err := NewOpenAI(server.URL, "key", "m").Stream(context.Background(), req, func(string) {})
+	assert.NoError(t, err)
+	assert.Equal(t, []ToolCall{{ID: "call_1", Name: "search_dishes", Arguments: `{"taste":"辣"}`}}, resp.ToolCalls)
+}
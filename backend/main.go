@@ -1,12 +1,21 @@
 package main
 
 import (
+	"backend/account"
+	"backend/auth"
 	"backend/chat"
 	"backend/config"
+	"backend/llm"
+	"backend/profile"
 	"backend/recommend"
+	"backend/review"
+	"backend/storage"
 	"backend/user"
+	"backend/wechat"
 	"database/sql"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
@@ -16,19 +25,22 @@ import (
 )
 
 func main() {
-	// 加载配置文件
-	cfg, err := config.LoadDBConfig("config/db_config.json")
+	// 加载配置（配置文件 < 环境变量 < 命令行参数），并在配置文件变化时热更新
+	watcher, err := config.NewWatcher(os.Args[1:], 5*time.Second)
 	if err != nil {
 		panic(err)
 	}
-	wxCfg, err := config.LoadWxConfig("config/wx_config.json")
-	if err != nil {
-		panic(err)
-	}
-	aiCfg, err := config.LoadAIConfig("config/ai_config.json")
+	watcher.Start()
+	defer watcher.Stop()
+	appCfg := watcher.Current()
+	cfg, wxCfg := appCfg.DB, appCfg.Wx
+
+	// 创建 AI 服务
+	provider, err := llm.New(appCfg.AI)
 	if err != nil {
 		panic(err)
 	}
+	chatTimeout := time.Duration(appCfg.AI.TimeoutSeconds) * time.Second
 
 	// 连接数据库
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
@@ -43,33 +55,114 @@ func main() {
 	if err := db.Ping(); err != nil {
 		panic(fmt.Errorf("数据库无法连接: %v", err))
 	}
+
+	// 定期训练协同过滤模型
+	trainer := recommend.NewTrainer(db, time.Hour)
+	trainer.Start()
+	defer trainer.Stop()
+
+	// 定期批量重新计算菜品评分
+	scores := recommend.NewScoreUpdater(db, time.Hour)
+	scores.Start()
+	defer scores.Stop()
+
+	// 头像和评论图片的存储，storage.driver 为 s3 时多个实例可以共享
+	uploads, stopUploads, err := storage.New(appCfg.Storage, func() string { return watcher.Current().Server.Domain })
+	if err != nil {
+		panic(fmt.Errorf("初始化文件存储失败: %v", err))
+	}
+	defer stopUploads()
+
+	// 定期删除冷静期已结束的注销账号
+	purger := account.NewPurger(db, uploads, time.Hour)
+	purger.Start()
+	defer purger.Stop()
+
 	r := gin.Default()
 
-	r.Static("/avatar", "./data/avatar")
+	// 本地存储时由服务提供文件访问。头像文件名带内容哈希，换头像时地址随之变化，可以长期缓存；
+	// 评论图片只公开审核通过的，其余需要带签名的地址
+	if local, ok := uploads.(*storage.Local); ok {
+		avatars := r.Group("/avatar", func(c *gin.Context) {
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		})
+		avatars.Static("/", local.Dir("avatar"))
+		r.GET("/review/:name", review.LocalPhotoHandler(db, local))
+	}
+
+	// 评论敏感词库，文件不存在时使用内置词表
+	filter, err := review.LoadFilter("data/sensitive_words.txt")
+	if err != nil {
+		panic(fmt.Errorf("加载敏感词库失败: %v", err))
+	}
 
-	store := cookie.NewStore([]byte("secret-key"))
+	// session 签名密钥来自配置 auth.session_secret
+	store := cookie.NewStore([]byte(appCfg.Auth.SessionSecret))
 	r.Use(sessions.Sessions("todayeat-session", store))
 
+	// 从 Bearer 访问令牌或 session 解析当前登录用户，auth.mode 控制是否还接受客户端传入的 user_id
+	tokens := auth.NewTokens(db, watcher.Current)
+	r.Use(auth.Middleware(watcher.Current, tokens))
+
+	// 微信接口，wx.base_url 为 fake 时使用本地模拟服务（只有 -tags dev 编译的版本支持）
+	wx, stopWx, err := wechat.New(wxCfg)
+	if err != nil {
+		panic(err)
+	}
+	defer stopWx()
+
+	// 微信 session_key 加密保存，用于解密手机号等开放数据
+	sessionKeys, err := user.NewSessionKeys(db, wxCfg.SessionKeySecret)
+	if err != nil {
+		panic(err)
+	}
+
 	// 注册接口
-	r.GET("/api/dishes", recommend.GetAllDishes(db))                                   // dishes.go 中的获取菜品接口
-	r.GET("/api/chat/ws", chat.ChatWSHandler(aiCfg.APIKey))                            // chat.go 中的聊天接口
-	r.POST("/api/user/wxlogin", user.WxLoginHandler(db, wxCfg.AppID, wxCfg.AppSecret)) //login.go 中的微信登录接口
-	r.POST("/api/user/avatar", user.UploadAvatarHandler(db))                           //avatar.go 中的上传头像接口
-	r.POST("/api/user/update_nickname", user.UpdateNicknameHandler(db))                //login.go 中的更新昵称接口
-	r.GET("/api/dish/random", recommend.GetRandomDish(db))                             //randomRecom.go 中的随机推荐接口
-	r.POST("/api/like/like", recommend.LikeDish(db))                                   //like.go 中的点赞接口
-	r.POST("/api/like/unlike", recommend.UnlikeDish(db))                               //like.go 中的取消点赞接口
-	r.GET("/api/user/:user_id/favorites", recommend.GetUserLikes(db))                  //like.go 中的获取用户收藏的菜品接口
-	r.POST("/api/history/add", recommend.AddRecommendHistory(db))                      //dishes.go 中的添加推荐历史接口
-	r.GET("/api/history", recommend.GetRecommendHistory(db))                           //dishes.go 中的获取推荐历史接口
-	r.POST("/api/dish/custom", recommend.CustomDishHandler(aiCfg.APIKey, db))          //recommend.go 中的自定义推荐接口
-	r.POST("/api/custom/add", recommend.AddCustomRecordHandler(db))                    //dishes.go 中的添加定制推荐记录接口
-	r.GET("/api/user/info", user.GetUserInfoHandler(db))                               //login.go 中的获取用户完整信息接口
-	r.GET("/api/dish/detail", recommend.GetDishDetailHandler(db))                      //dishes.go 中的获取菜品详情接口
-	r.POST("/api/rating", user.RateDishHandler(db))                                    //rate.go 中的评分接口
+	r.GET("/api/dishes", recommend.GetAllDishes(db))                                       // dishes.go 中的获取菜品接口
+	r.GET("/api/chat/ws", chat.ChatWSHandler(db, provider, chatTimeout))                   // chat.go 中的聊天接口
+	r.GET("/api/chat/conversations", chat.ListConversationsHandler(db))                    //history.go 中的聊天会话列表接口
+	r.GET("/api/chat/conversations/:id/messages", chat.GetConversationMessagesHandler(db)) //history.go 中的会话消息接口
+	r.POST("/api/user/wxlogin", user.WxLoginHandler(db, wx, tokens, sessionKeys))          //login.go 中的微信登录接口
+	r.POST("/api/user/wxdecrypt", user.WxDecryptHandler(db, wxCfg.AppID, sessionKeys))     //wxdata.go 中的解密微信开放数据接口
+	r.POST("/api/user/token/refresh", user.RefreshTokenHandler(tokens))                    //token.go 中的刷新令牌接口
+	r.POST("/api/user/logout", user.LogoutHandler(tokens))                                 //token.go 中的退出登录接口
+	r.POST("/api/user/avatar", user.UploadAvatarHandler(db, uploads))                      //avatar.go 中的上传头像接口
+	r.POST("/api/user/update_nickname", user.UpdateNicknameHandler(db))                    //login.go 中的更新昵称接口
+	r.GET("/api/user/export", account.ExportHandler(db, uploads))                          //export.go 中的导出个人数据接口
+	r.POST("/api/user/delete", account.RequestDeletionHandler(db, tokens))                 //deletion.go 中的申请注销账号接口
+	r.POST("/api/user/delete/cancel", account.CancelDeletionHandler(db))                   //deletion.go 中的撤销注销申请接口
+	r.GET("/api/user/delete/status", account.DeletionStatusHandler(db))                    //deletion.go 中的注销申请状态接口
+	r.GET("/api/dish/random", recommend.GetRandomDish(db))                                 //randomRecom.go 中的随机推荐接口
+	r.POST("/api/like/like", recommend.LikeDish(db))                                       //like.go 中的点赞接口
+	r.POST("/api/like/unlike", recommend.UnlikeDish(db))                                   //like.go 中的取消点赞接口
+	r.GET("/api/user/:user_id/favorites", recommend.GetUserLikes(db))                      //like.go 中的获取用户收藏的菜品接口
+	r.POST("/api/history/add", recommend.AddRecommendHistory(db))                          //dishes.go 中的添加推荐历史接口
+	r.GET("/api/history", recommend.GetRecommendHistory(db))                               //dishes.go 中的获取推荐历史接口
+	r.GET("/api/dish/recommend/for-you", recommend.GetForYouHandler(db))                   //foryou.go 中的猜你喜欢接口
+	r.GET("/api/dish/similar", recommend.GetSimilarDishesHandler(db))                      //similar.go 中的相似菜品接口
+	r.GET("/api/dish/nearby", recommend.GetNearbyDishesHandler(db))                        //nearby.go 中的附近菜品接口
+	r.POST("/api/dish/custom", recommend.CustomDishHandler(provider, db))                  //recommend.go 中的自定义推荐接口
+	r.POST("/api/custom/add", recommend.AddCustomRecordHandler(db))                        //dishes.go 中的添加定制推荐记录接口
+	r.GET("/api/user/info", user.GetUserInfoHandler(db))                                   //login.go 中的获取用户完整信息接口
+	r.GET("/api/user/profile", profile.GetProfileHandler(db))                              //profile.go 中的用户画像接口
+	r.GET("/api/dish/detail", recommend.GetDishDetailHandler(db))                          //dishes.go 中的获取菜品详情接口
+	r.POST("/api/rating", user.RateDishHandler(db))                                        //rate.go 中的评分接口
+	r.GET("/api/rating/mine", user.GetMyRatingHandler(db))                                 //rate.go 中的获取我的评分接口
+	r.GET("/api/rating/dish", user.ListDishRatingsHandler(db))                             //rate.go 中的菜品评分列表接口
+	r.GET("/api/rating/user", user.ListUserRatingsHandler(db))                             //rate.go 中的用户评分列表接口
+	r.POST("/api/rating/delete", user.DeleteRatingHandler(db))                             //rate.go 中的删除评分接口
+
+	r.POST("/api/review", review.CreateReviewHandler(db, uploads, filter)) //review.go 中的发表评论接口
+	r.GET("/api/review/dish", review.ListDishReviewsHandler(db))           //review.go 中的菜品评论列表接口
+	r.GET("/api/review/mine", review.ListMyReviewsHandler(db, uploads))    //review.go 中的我的评论接口
+
+	// 管理接口，需要在请求头 X-Admin-Token 中携带 admin.token
+	admin := r.Group("/api/admin", review.RequireAdmin(watcher.Current))
+	admin.GET("/reviews", review.ListForModerationHandler(db, uploads))            //admin.go 中的评论审核列表接口
+	admin.POST("/reviews/:id/moderate", review.ModerateReviewHandler(db, uploads)) //admin.go 中的评论审核接口
 
 	// 启动服务器
-	if err := r.Run(":8080"); err != nil {
+	if err := r.Run(appCfg.Server.Addr); err != nil {
 		panic(fmt.Errorf("服务器启动失败: %v", err))
 	}
 }
@@ -0,0 +1,73 @@
+// Package media 处理用户上传的图片：大小限制、解码、裁剪缩放和编码，保存由 storage 负责
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// MaxImageSize 单张上传图片的大小上限
+const MaxImageSize = 2 * 1024 * 1024
+
+// 解码后的尺寸上限：压缩率高的图片文件很小，解码后却可能占用几 GB 内存
+const (
+	maxImageSide   = 8192
+	maxImagePixels = 25_000_000
+)
+
+var (
+	ErrTooLarge   = errors.New("图片太大，请上传小于2MB的图片")
+	ErrDecode     = errors.New("图片解码失败（请上传 JPG/PNG 等标准图片）")
+	ErrDimensions = errors.New("图片尺寸太大，请上传边长不超过8192像素、总像素不超过2500万的图片")
+)
+
+// DecodeUpload 检查上传文件大小和图片尺寸并解码为图片，按 JPEG 中的 EXIF 方向转正（手机拍的照片常常是横着存的）
+func DecodeUpload(fh *multipart.FileHeader) (image.Image, error) {
+	if fh.Size > MaxImageSize {
+		return nil, ErrTooLarge
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxImageSize {
+		return nil, ErrTooLarge
+	}
+	// 先只读取文件头中的宽高，尺寸超限时不解码
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrDecode
+	}
+	if cfg.Width > maxImageSide || cfg.Height > maxImageSide || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrDecode
+	}
+	return Orient(img, exifOrientation(data)), nil
+}
+
+// EncodePNG 把图片编码为 PNG
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("PNG 编码失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,162 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fileHeader 把内容包装成上传文件
+func fileHeader(t *testing.T, data []byte) *multipart.FileHeader {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	part, _ := w.CreateFormFile("file", "upload.png")
+	part.Write(data)
+	w.Close()
+
+	form, err := multipart.NewReader(body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("解析表单失败: %v", err)
+	}
+	return form.File["file"][0]
+}
+
+func TestDecodeUploadAndEncode(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 6))))
+
+	img, err := DecodeUpload(fileHeader(t, buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 8, img.Bounds().Dx())
+
+	data, err := EncodePNG(img)
+	assert.NoError(t, err)
+	decoded, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, img.Bounds(), decoded.Bounds())
+
+	_, err = DecodeUpload(fileHeader(t, []byte("not an image")))
+	assert.Equal(t, ErrDecode, err)
+
+	fh := fileHeader(t, buf.Bytes())
+	fh.Size = MaxImageSize + 1
+	_, err = DecodeUpload(fh)
+	assert.Equal(t, ErrTooLarge, err)
+}
+
+// gifWithSize 生成 1x1 的 GIF 并把文件头中的画布尺寸改为 w x h，只有读取尺寸时才有效
+func gifWithSize(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black}), nil); err != nil {
+		t.Fatalf("GIF 编码失败: %v", err)
+	}
+	data := buf.Bytes()
+	data[6], data[7], data[8], data[9] = byte(w), byte(w>>8), byte(h), byte(h>>8)
+	return data
+}
+
+func TestDecodeUploadDimensions(t *testing.T) {
+	// 边长超限
+	_, err := DecodeUpload(fileHeader(t, gifWithSize(t, maxImageSide+1, 1)))
+	assert.Equal(t, ErrDimensions, err)
+	// 边长未超限但总像素超限
+	_, err = DecodeUpload(fileHeader(t, gifWithSize(t, 6000, 6000)))
+	assert.Equal(t, ErrDimensions, err)
+
+	img, err := DecodeUpload(fileHeader(t, gifWithSize(t, 1, 1)))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, img.Bounds().Dx())
+}
+
+// withOrientation 在 JPEG 的 SOI 之后插入只含 Orientation 的 EXIF 段（大端序）
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte{'M', 'M', 0, 0x2A, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(orientation >> 8), byte(orientation), 0, 0, 0, 0, 0, 0, 0, 0}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	out := append([]byte{}, data[:2]...)
+	out = append(out, seg...)
+	out = append(out, payload...)
+	return append(out, data[2:]...)
+}
+
+func TestDecodeUploadOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 4)), nil))
+	assert.Equal(t, 1, exifOrientation(buf.Bytes()))
+
+	// 顺时针旋转 90° 后宽高互换
+	data := withOrientation(buf.Bytes(), 6)
+	assert.Equal(t, 6, exifOrientation(data))
+	img, err := DecodeUpload(fileHeader(t, data))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 4, 8), img.Bounds())
+}
+
+func TestOrient(t *testing.T) {
+	// 2×1：左红右蓝
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	cases := []struct {
+		orientation int
+		w, h        int
+		first       color.RGBA // 左上角
+	}{
+		{1, 2, 1, red},
+		{2, 2, 1, blue},
+		{3, 2, 1, blue},
+		{6, 1, 2, red},
+		{8, 1, 2, blue},
+	}
+	for _, tc := range cases {
+		img := Orient(src, tc.orientation)
+		assert.Equal(t, image.Rect(0, 0, tc.w, tc.h), img.Bounds(), tc.orientation)
+		assert.Equal(t, tc.first, color.RGBAModel.Convert(img.At(0, 0)), tc.orientation)
+	}
+}
+
+func TestCropSquareAndResize(t *testing.T) {
+	// 6×2：中间 2×2 为白色，两侧为黑色
+	src := image.NewRGBA(image.Rect(0, 0, 6, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 6; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if x == 2 || x == 3 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	square := CropSquare(src)
+	assert.Equal(t, image.Rect(0, 0, 2, 2), square.Bounds())
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, square.RGBAAt(0, 0))
+
+	// 透明部分填充白色
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, CropSquare(image.NewRGBA(image.Rect(0, 0, 3, 3))).RGBAAt(1, 1))
+
+	// 缩小时取区域平均：黑白各半为灰色
+	small := Resize(src, 3, 1)
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, small.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, small.RGBAAt(1, 0))
+	half := Resize(src, 1, 1)
+	assert.InDelta(t, 85, int(half.RGBAAt(0, 0).R), 1)
+
+	// 放大
+	big := Resize(square, 64, 64)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), big.Bounds())
+
+	data, err := EncodeJPEG(big)
+	assert.NoError(t, err)
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 64, cfg.Width)
+}
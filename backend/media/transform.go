@@ -0,0 +1,202 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+)
+
+// JPEGQuality 保存 JPEG 时的质量。标准库没有 WebP 编码器，缩放后的图片统一保存为 JPEG
+const JPEGQuality = 85
+
+// exifOrientation 读取 JPEG 中 EXIF 的方向（1~8），没有或无法解析时返回 1
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			return 1 // 已到图像数据，没有 EXIF
+		}
+		seg := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation 在 EXIF 的 TIFF 结构中查找 IFD0 的 Orientation（0x0112）
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// toRGBA 把图片复制为以 (0,0) 为起点的 RGBA
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// Orient 按 EXIF 方向旋转或翻转图片，使其按正常方向显示
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	src := toRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-x, y
+			case 3: // 旋转 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90°
+				sx, sy = y, h-1-x
+			case 7: // 沿右上-左下对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针旋转 90°
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], src.Pix[src.PixOffset(sx, sy):][:4])
+		}
+	}
+	return dst
+}
+
+// CropSquare 居中裁剪为正方形，透明部分填充白色，便于保存为 JPEG
+func CropSquare(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	size := min(b.Dx(), b.Dy())
+	origin := image.Pt(b.Min.X+(b.Dx()-size)/2, b.Min.Y+(b.Dy()-size)/2)
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, origin, draw.Over)
+	return dst
+}
+
+// boxWeight 缩放时一个源像素对目标像素的权重
+type boxWeight struct {
+	index  int
+	weight float64
+}
+
+// boxWeights 计算每个目标像素覆盖的源像素及覆盖比例；放大时退化为最近邻
+func boxWeights(srcLen, dstLen int) [][]boxWeight {
+	scale := float64(srcLen) / float64(dstLen)
+	weights := make([][]boxWeight, dstLen)
+	for i := range weights {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		if scale < 1 {
+			c := int(start + scale/2)
+			weights[i] = []boxWeight{{c, 1}}
+			continue
+		}
+		var sum float64
+		for j := int(start); j < srcLen && float64(j) < end; j++ {
+			w := min(end, float64(j+1)) - max(start, float64(j))
+			if w > 0 {
+				weights[i] = append(weights[i], boxWeight{j, w})
+				sum += w
+			}
+		}
+		for k := range weights[i] {
+			weights[i][k].weight /= sum
+		}
+	}
+	return weights
+}
+
+// Resize 用区域平均把图片缩放为 w×h，缩小时不会出现锯齿。
+// 按目标行逐行计算，临时缓冲只有一行大小，大图缩小时不会额外占用大量内存
+func Resize(img image.Image, w, h int) *image.RGBA {
+	src := toRGBA(img)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	xw, yw := boxWeights(sw, w), boxWeights(sh, h)
+
+	row := make([]float64, w*4) // 水平缩放后的一行源像素
+	sum := make([]float64, w*4) // 当前目标行的累加值
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, ws := range yw {
+		clear(sum)
+		for _, bw := range ws {
+			clear(row)
+			for x, xs := range xw {
+				t := row[x*4:][:4]
+				for _, xb := range xs {
+					p := src.Pix[src.PixOffset(xb.index, bw.index):][:4]
+					for c := range t {
+						t[c] += float64(p[c]) * xb.weight
+					}
+				}
+			}
+			for i, v := range row {
+				sum[i] += v * bw.weight
+			}
+		}
+		p := dst.Pix[dst.PixOffset(0, y):][:w*4]
+		for i := range p {
+			p[i] = uint8(min(max(sum[i]+0.5, 0), 255))
+		}
+	}
+	return dst
+}
+
+// EncodeJPEG 把图片编码为 JPEG
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,20 @@
+-- 聊天会话与消息记录
+CREATE TABLE IF NOT EXISTS chat_conversations (
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT          NOT NULL,
+    title      VARCHAR(100) NOT NULL DEFAULT '',
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_chat_conversations_user (user_id, updated_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id              BIGINT AUTO_INCREMENT PRIMARY KEY,
+    conversation_id BIGINT      NOT NULL,
+    role            VARCHAR(16) NOT NULL,
+    content         TEXT        NOT NULL,
+    created_at      DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_chat_messages_conversation (conversation_id, id),
+    CONSTRAINT fk_chat_messages_conversation FOREIGN KEY (conversation_id)
+        REFERENCES chat_conversations (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,16 @@
+-- 餐厅及其坐标，菜品关联到餐厅后可以按距离推荐
+CREATE TABLE IF NOT EXISTS restaurants (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(100)   NOT NULL,
+    address    VARCHAR(255)   NOT NULL DEFAULT '',
+    latitude   DECIMAL(10, 7) NOT NULL,
+    longitude  DECIMAL(10, 7) NOT NULL,
+    created_at DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_restaurants_location (latitude, longitude)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+ALTER TABLE dishes
+    ADD COLUMN restaurant_id INT NULL,
+    ADD KEY idx_dishes_restaurant (restaurant_id),
+    ADD CONSTRAINT fk_dishes_restaurant FOREIGN KEY (restaurant_id)
+        REFERENCES restaurants (id) ON DELETE SET NULL;
@@ -0,0 +1,10 @@
+-- 协同过滤训练得到的菜品相似度，每道菜只保存最相似的若干道
+CREATE TABLE IF NOT EXISTS dish_similarity (
+    dish_id         INT          NOT NULL,
+    similar_dish_id INT          NOT NULL,
+    score           DOUBLE       NOT NULL,
+    co_count        INT          NOT NULL DEFAULT 0,
+    updated_at      DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (dish_id, similar_dish_id),
+    KEY idx_dish_similarity_score (dish_id, score)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,6 @@
+-- 菜品评分由用户评分按贝叶斯平均计算，原来的评分保存为 base_score 作为先验
+ALTER TABLE dishes
+    ADD COLUMN base_score   DECIMAL(3, 2) NULL,
+    ADD COLUMN rating_count INT           NOT NULL DEFAULT 0;
+
+UPDATE dishes SET base_score = score WHERE base_score IS NULL;
@@ -0,0 +1,4 @@
+-- 评分支持半星和短评
+ALTER TABLE dish_ratings
+    MODIFY COLUMN score DECIMAL(2, 1) NOT NULL,
+    ADD COLUMN review VARCHAR(200) NOT NULL DEFAULT '';
@@ -0,0 +1,24 @@
+-- 菜品评论及图片，评论经审核通过后才公开展示
+CREATE TABLE IF NOT EXISTS reviews (
+    id            BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id       INT          NOT NULL,
+    dish_id       INT          NOT NULL,
+    content       TEXT         NOT NULL,
+    status        VARCHAR(16)  NOT NULL DEFAULT 'pending', -- pending、approved、rejected
+    reject_reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at   DATETIME     NULL,
+    KEY idx_reviews_dish (dish_id, status, created_at),
+    KEY idx_reviews_user (user_id, created_at),
+    KEY idx_reviews_status (status, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS review_photos (
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    review_id  BIGINT       NOT NULL,
+    url        VARCHAR(255) NOT NULL,
+    sort_order INT          NOT NULL DEFAULT 0,
+    KEY idx_review_photos_review (review_id, sort_order),
+    CONSTRAINT fk_review_photos_review FOREIGN KEY (review_id)
+        REFERENCES reviews (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
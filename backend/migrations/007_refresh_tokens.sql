@@ -0,0 +1,15 @@
+-- 刷新令牌：只保存哈希值。同一次登录后轮换得到的令牌属于同一个 family，
+-- 已经换过新令牌的旧令牌再次出现时视为泄露，整个 family 一起吊销
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT      NOT NULL,
+    family_id  CHAR(32) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at    DATETIME NULL, -- 已换取新令牌的时间
+    revoked_at DATETIME NULL, -- 退出登录或检测到重复使用时吊销
+    UNIQUE KEY uk_refresh_tokens_hash (token_hash),
+    KEY idx_refresh_tokens_family (family_id),
+    KEY idx_refresh_tokens_user (user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,12 @@
+-- 保存微信 unionid（同一开放平台下的多个应用据此关联账号）和解密得到的手机号
+ALTER TABLE users
+    ADD COLUMN unionid      VARCHAR(64) NULL,
+    ADD COLUMN phone_number VARCHAR(32) NULL,
+    ADD UNIQUE KEY uk_users_unionid (unionid);
+
+-- 微信 session_key，用于解密 encryptedData；以 AES-GCM 加密后保存，密钥为配置项 wx.session_key_secret
+CREATE TABLE IF NOT EXISTS wx_sessions (
+    user_id     INT            NOT NULL PRIMARY KEY,
+    session_key VARBINARY(128) NOT NULL,
+    updated_at  DATETIME       NOT NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,14 @@
+-- 注销账号申请及审计记录。申请后进入冷静期，到期由后台任务删除用户的全部数据；
+-- 记录本身在删除后保留，只包含用户 ID 和各表删除的行数，不含个人信息
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id      INT         NOT NULL,
+    status       VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending、cancelled、completed
+    requested_at DATETIME    NOT NULL,
+    scheduled_at DATETIME    NOT NULL, -- 冷静期结束、开始删除的时间
+    cancelled_at DATETIME    NULL,
+    completed_at DATETIME    NULL,
+    summary      TEXT        NULL,     -- 各表删除的行数（JSON）
+    KEY idx_account_deletions_user (user_id, status),
+    KEY idx_account_deletions_due (status, scheduled_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
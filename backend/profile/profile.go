@@ -0,0 +1,261 @@
+// Package profile 根据用户的推荐记录、点赞和评分计算口味画像
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"backend/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 计算偏好口味时各类行为的权重：选过一次记 1，点赞或打高分说明更喜欢
+const (
+	choiceWeight = 1
+	likeWeight   = 2
+	ratingWeight = 2
+	goodRating   = 4 // 不低于该分数的评分计入偏好口味
+)
+
+// Stat 一项统计：口味时为加权次数，心情时为出现次数
+type Stat struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// MoodDish 某种心情下选得最多的菜
+type MoodDish struct {
+	Mood     string `json:"mood"`
+	DishID   int    `json:"dish_id"`
+	DishName string `json:"dish_name"`
+	Count    int    `json:"count"`
+}
+
+// Profile 用户画像。前四项同时保存在 users 表中，登录和用户信息接口直接读取
+type Profile struct {
+	UserID        int    `json:"user_id"`
+	MealCount     int    `json:"meal_count"`     // 随机推荐和定制推荐中选定的次数
+	FavoriteTaste string `json:"favorite_taste"` // 加权次数最多的口味
+	CommonMood    string `json:"common_mood"`    // 定制推荐中最常见的心情
+	MoodFood      string `json:"mood_food"`      // 最常见的心情下选得最多的菜
+
+	RecommendCount int        `json:"recommend_count"`
+	CustomCount    int        `json:"custom_count"`
+	LikeCount      int        `json:"like_count"`
+	RatingCount    int        `json:"rating_count"`
+	AvgRating      float64    `json:"avg_rating"`
+	Tastes         []Stat     `json:"tastes"`
+	Moods          []Stat     `json:"moods"`
+	MoodDishes     []MoodDish `json:"mood_dishes"` // 每种心情选得最多的菜，按心情出现次数排序
+}
+
+// sortStats 按次数从多到少排序，次数相同时按名称，保证结果稳定
+func sortStats(stats []Stat) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Name < stats[j].Name
+	})
+}
+
+// queryStats 查询 (名称, 次数) 并排序
+func queryStats(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]Stat, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := []Stat{}
+	for rows.Next() {
+		var s Stat
+		if err := rows.Scan(&s.Name, &s.Count); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	sortStats(stats)
+	return stats, rows.Err()
+}
+
+// Build 从推荐记录、定制推荐记录、点赞和评分计算用户画像
+func Build(ctx context.Context, db *sql.DB, userID int) (Profile, error) {
+	p := Profile{UserID: userID}
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM recommend_history WHERE user_id = ?),
+			(SELECT COUNT(*) FROM custom_recommend_history WHERE user_id = ?),
+			(SELECT COUNT(*) FROM `+"`like`"+` WHERE user_id = ?),
+			(SELECT COUNT(*) FROM dish_ratings WHERE user_id = ?),
+			(SELECT COALESCE(AVG(score), 0) FROM dish_ratings WHERE user_id = ?)
+	`, userID, userID, userID, userID, userID).Scan(&p.RecommendCount, &p.CustomCount, &p.LikeCount, &p.RatingCount, &p.AvgRating)
+	if err != nil {
+		return p, err
+	}
+	p.MealCount = p.RecommendCount + p.CustomCount
+
+	p.Tastes, err = queryStats(ctx, db, `
+		SELECT taste, SUM(weight) FROM (
+			SELECT d.taste, ? AS weight FROM recommend_history h JOIN dishes d ON h.dish_id = d.id WHERE h.user_id = ?
+			UNION ALL
+			SELECT taste, ? FROM custom_recommend_history WHERE user_id = ?
+			UNION ALL
+			SELECT d.taste, ? FROM `+"`like`"+` l JOIN dishes d ON l.dish_id = d.id WHERE l.user_id = ?
+			UNION ALL
+			SELECT d.taste, ? FROM dish_ratings r JOIN dishes d ON r.dish_id = d.id WHERE r.user_id = ? AND r.score >= ?
+		) t
+		WHERE taste <> ''
+		GROUP BY taste
+	`, choiceWeight, userID, choiceWeight, userID, likeWeight, userID, ratingWeight, userID, goodRating)
+	if err != nil {
+		return p, err
+	}
+	if len(p.Tastes) > 0 {
+		p.FavoriteTaste = p.Tastes[0].Name
+	}
+
+	p.Moods, err = queryStats(ctx, db,
+		"SELECT mood, COUNT(*) FROM custom_recommend_history WHERE user_id = ? AND mood <> '' GROUP BY mood", userID)
+	if err != nil {
+		return p, err
+	}
+	if len(p.Moods) > 0 {
+		p.CommonMood = p.Moods[0].Name
+	}
+
+	p.MoodDishes, err = moodDishes(ctx, db, userID, p.Moods)
+	if err != nil {
+		return p, err
+	}
+	if len(p.MoodDishes) > 0 && p.MoodDishes[0].Mood == p.CommonMood {
+		p.MoodFood = p.MoodDishes[0].DishName
+	}
+	return p, nil
+}
+
+// moodDishes 每种心情下选得最多的菜，顺序与 moods 一致
+func moodDishes(ctx context.Context, db *sql.DB, userID int, moods []Stat) ([]MoodDish, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.mood, c.dish_id, d.name, COUNT(*)
+		FROM custom_recommend_history c
+		JOIN dishes d ON c.dish_id = d.id
+		WHERE c.user_id = ? AND c.mood <> ''
+		GROUP BY c.mood, c.dish_id, d.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	top := map[string]MoodDish{}
+	for rows.Next() {
+		var m MoodDish
+		if err := rows.Scan(&m.Mood, &m.DishID, &m.DishName, &m.Count); err != nil {
+			return nil, err
+		}
+		best, ok := top[m.Mood]
+		if !ok || m.Count > best.Count || (m.Count == best.Count && m.DishID < best.DishID) {
+			top[m.Mood] = m
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []MoodDish{}
+	for _, mood := range moods {
+		if m, ok := top[mood.Name]; ok {
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
+
+// Refresh 重新计算用户画像并写回 users 表，只计算该用户。写入接口中使用 RefreshAsync
+func Refresh(ctx context.Context, db *sql.DB, userID int) (Profile, error) {
+	p, err := Build(ctx, db, userID)
+	if err != nil {
+		return p, err
+	}
+	_, err = db.ExecContext(ctx, "UPDATE users SET meal_count = ?, favorite_taste = ?, common_mood = ?, mood_food = ? WHERE id = ?",
+		p.MealCount, p.FavoriteTaste, p.CommonMood, p.MoodFood, userID)
+	return p, err
+}
+
+// refreshTimeout 后台更新一次画像的超时时间
+const refreshTimeout = 10 * time.Second
+
+// 后台更新画像的状态：正在更新的用户 -> 更新期间是否又有新的写入
+var (
+	refreshMu  sync.Mutex
+	refreshing = map[int]bool{}
+	refreshWG  sync.WaitGroup
+)
+
+// RefreshAsync 在后台重新计算用户画像并写回 users 表，在推荐记录、点赞、评分写入后调用，不阻塞请求。
+// 同一用户正在更新时不重复启动，只在本次完成后再计算一次，连续写入不会堆积查询。
+// 失败只记录日志：画像完全由原始记录汇总得到，下次写入或登录时会整体重新计算，不会丢失数据
+func RefreshAsync(db *sql.DB, userID int) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	if _, running := refreshing[userID]; running {
+		refreshing[userID] = true
+		return
+	}
+	refreshing[userID] = false
+
+	refreshWG.Add(1)
+	go func() {
+		defer refreshWG.Done()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+			if _, err := Refresh(ctx, db, userID); err != nil {
+				log.Printf("⚠️ 更新用户 %d 的画像失败: %v", userID, err)
+			}
+			cancel()
+
+			refreshMu.Lock()
+			again := refreshing[userID]
+			if !again {
+				delete(refreshing, userID)
+			} else {
+				refreshing[userID] = false
+			}
+			refreshMu.Unlock()
+			if !again {
+				return
+			}
+		}
+	}()
+}
+
+// Wait 等待后台的画像更新全部完成
+func Wait() {
+	refreshWG.Wait()
+}
+
+// GetProfileHandler 获取用户画像及各项明细
+func GetProfileHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
+
+		p, err := Build(c.Request.Context(), db, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "data": p})
+	}
+}
@@ -0,0 +1,104 @@
+package profile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectBuild 用户 3：选过 4 次，偏好"麻辣"，"开心"时最常选水煮鱼
+func expectBuild(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM recommend_history WHERE user_id = \?`).WithArgs(3, 3, 3, 3, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"r", "c", "l", "n", "avg"}).AddRow(1, 3, 2, 1, 4.5))
+	mock.ExpectQuery(`SELECT taste, SUM\(weight\)`).
+		WithArgs(choiceWeight, 3, choiceWeight, 3, likeWeight, 3, ratingWeight, 3, goodRating).
+		WillReturnRows(sqlmock.NewRows([]string{"taste", "n"}).AddRow("清淡", 2).AddRow("麻辣", 5).AddRow("酸甜", 2))
+	mock.ExpectQuery(`SELECT mood, COUNT\(\*\) FROM custom_recommend_history`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"mood", "n"}).AddRow("疲惫", 1).AddRow("开心", 2))
+	mock.ExpectQuery(`SELECT c.mood, c.dish_id, d.name, COUNT\(\*\)`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"mood", "dish_id", "name", "n"}).
+			AddRow("开心", 8, "宫保鸡丁", 1).
+			AddRow("开心", 5, "水煮鱼", 1).
+			AddRow("疲惫", 9, "白粥", 1))
+}
+
+func TestBuild(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	expectBuild(mock)
+	p, err := Build(context.Background(), db, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, p.MealCount)
+	assert.Equal(t, "麻辣", p.FavoriteTaste)
+	// 次数相同按名称排序
+	assert.Equal(t, []Stat{{"麻辣", 5}, {"清淡", 2}, {"酸甜", 2}}, p.Tastes)
+	assert.Equal(t, "开心", p.CommonMood)
+	// 次数相同时取 ID 较小的菜
+	assert.Equal(t, "水煮鱼", p.MoodFood)
+	assert.Equal(t, []MoodDish{{"开心", 5, "水煮鱼", 1}, {"疲惫", 9, "白粥", 1}}, p.MoodDishes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefresh(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	expectBuild(mock)
+	mock.ExpectExec(`UPDATE users SET meal_count = \?, favorite_taste = \?, common_mood = \?, mood_food = \? WHERE id = \?`).
+		WithArgs(4, "麻辣", "开心", "水煮鱼", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = Refresh(context.Background(), db, 3)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshAsync(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	// 两次写入：第二次发生在第一次更新期间时合并为再算一次，否则各算一次，都只计算两次
+	for i := 0; i < 2; i++ {
+		expectBuild(mock)
+		mock.ExpectExec(`UPDATE users SET meal_count = \?`).
+			WithArgs(4, "麻辣", "开心", "水煮鱼", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	RefreshAsync(db, 3)
+	RefreshAsync(db, 3)
+	Wait()
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetProfileHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/profile", GetProfileHandler(db))
+
+	expectBuild(mock)
+	req, _ := http.NewRequest("GET", "/profile?user_id=3", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"favorite_taste":"麻辣"`)
+	assert.Contains(t, w.Body.String(), `"mood_dishes":[{"mood":"开心","dish_id":5`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,42 @@
+package recommend
+
+// maxCandidates 提交给 AI 的候选菜品上限，保证 prompt 长度不随菜品数量增长
+const maxCandidates = 20
+
+// minTasteMatches 符合口味的菜品少于这个数量时不按口味过滤，避免候选太少
+const minTasteMatches = 3
+
+// selectCandidates 从菜品中预选候选：预算是硬性条件，口味尽量满足，
+// 再按规则得分排序并截取前 limit 个
+func selectCandidates(req CustomRequest, dishes []Dish, limit int) []Dish {
+	var inBudget []Dish
+	for _, d := range dishes {
+		if req.Budget <= 0 || d.Price <= float64(req.Budget) {
+			inBudget = append(inBudget, d)
+		}
+	}
+
+	candidates := inBudget
+	if tags := splitTags(req.Taste); len(tags) > 0 {
+		var tasteMatched []Dish
+		for _, d := range inBudget {
+			if keywordHits(d, tags) > 0 {
+				tasteMatched = append(tasteMatched, d)
+			}
+		}
+		if len(tasteMatched) >= minTasteMatches {
+			candidates = tasteMatched
+		}
+	}
+
+	ranked := rankDishes(req, candidates)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// overBudget AI 选出的菜品是否超出预算
+func overBudget(req CustomRequest, d Dish) bool {
+	return req.Budget > 0 && d.Price > float64(req.Budget)
+}
@@ -0,0 +1,53 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"backend/llm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectCandidates(t *testing.T) {
+	var dishes []Dish
+	for i := 1; i <= 30; i++ {
+		taste := "清淡"
+		if i%2 == 0 {
+			taste = "麻辣"
+		}
+		dishes = append(dishes, Dish{ID: i, Name: fmt.Sprintf("菜%d", i), Price: float64(i * 3), Taste: taste, Score: 4})
+	}
+
+	// 预算 60 元以内、口味麻辣：只剩 ID 为偶数且价格不超过 60 的 10 道菜
+	candidates := selectCandidates(CustomRequest{Taste: "麻辣", Budget: 60}, dishes, maxCandidates)
+	assert.Len(t, candidates, 10)
+	for _, d := range candidates {
+		assert.Equal(t, "麻辣", d.Taste)
+		assert.LessOrEqual(t, d.Price, 60.0)
+	}
+
+	// 没有条件时截取上限
+	assert.Len(t, selectCandidates(CustomRequest{}, dishes, maxCandidates), maxCandidates)
+
+	// 符合口味的太少时不按口味过滤
+	candidates = selectCandidates(CustomRequest{Taste: "酸甜", Budget: 9}, dishes, maxCandidates)
+	assert.Len(t, candidates, 3)
+}
+
+func TestAskAI_RejectOverBudget(t *testing.T) {
+	dishes := []Dish{
+		{ID: 1, Name: "清蒸鲈鱼", Price: 58},
+		{ID: 2, Name: "番茄蛋汤", Price: 15},
+	}
+	provider := &llm.Fake{Replies: []string{
+		`{"dish_id":1,"name":"清蒸鲈鱼","reason":"鲜美","confidence":0.9}`,
+		`{"dish_id":2,"name":"番茄蛋汤","reason":"实惠暖胃","confidence":0.8}`,
+	}}
+
+	dish, _, err := askAI(context.Background(), provider, "prompt", CustomRequest{Budget: 30}, dishes)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dish.ID)
+	assert.Contains(t, provider.Requests[1].Messages[2].Content, "超出了 30 元的预算")
+}
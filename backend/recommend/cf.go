@@ -0,0 +1,317 @@
+package recommend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 协同过滤（基于菜品的 item-item 模型）参数
+const (
+	cfNeighbors      = 30  // 每道菜保存的最相似菜品数
+	cfShrinkage      = 5.0 // 共同用户少时相似度向 0 收缩，n / (n + cfShrinkage)
+	cfPredictDamping = 0.5 // 预测时分母的平滑项，避免只有一个弱邻居时得分虚高
+	cfLikePref       = 1.0 // 只点赞、没有评分时的偏好值
+	cfLikedFloor     = 0.5 // 点赞且评分时偏好值的下限
+	cfInsertBatch    = 500 // 保存相似度时每条 INSERT 的行数
+)
+
+// preference 把评分和点赞换算成 -1 ~ 1 的偏好值：3 分为中性，点赞视为喜欢
+func preference(rating float64, rated, liked bool) float64 {
+	switch {
+	case rated && liked:
+		return max((rating-3)/2, cfLikedFloor)
+	case rated:
+		return (rating - 3) / 2
+	case liked:
+		return cfLikePref
+	}
+	return 0
+}
+
+// userPreferences 用户对每道菜的偏好值
+func userPreferences(s userSignals) map[int]float64 {
+	prefs := map[int]float64{}
+	for id, r := range s.ratings {
+		prefs[id] = preference(r, true, s.liked[id])
+	}
+	for id := range s.liked {
+		if _, ok := s.ratings[id]; !ok {
+			prefs[id] = preference(0, false, true)
+		}
+	}
+	return prefs
+}
+
+// loadAllPreferences 读取所有用户的评分和点赞，返回 用户 -> 菜品 -> 偏好值
+func loadAllPreferences(ctx context.Context, db *sql.DB) (map[int]map[int]float64, error) {
+	signals := map[int]*userSignals{}
+	get := func(userID int) *userSignals {
+		s, ok := signals[userID]
+		if !ok {
+			s = &userSignals{liked: map[int]bool{}, ratings: map[int]float64{}}
+			signals[userID] = s
+		}
+		return s
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT user_id, dish_id, score FROM dish_ratings")
+	if err != nil {
+		return nil, fmt.Errorf("查询评分失败: %w", err)
+	}
+	for rows.Next() {
+		var userID, dishID int
+		var score float64
+		if err := rows.Scan(&userID, &dishID, &score); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		get(userID).ratings[dishID] = score
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, "SELECT user_id, dish_id FROM `like`")
+	if err != nil {
+		return nil, fmt.Errorf("查询点赞失败: %w", err)
+	}
+	for rows.Next() {
+		var userID, dishID int
+		if err := rows.Scan(&userID, &dishID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		get(userID).liked[dishID] = true
+	}
+	rows.Close()
+
+	prefs := make(map[int]map[int]float64, len(signals))
+	for userID, s := range signals {
+		prefs[userID] = userPreferences(*s)
+	}
+	return prefs, nil
+}
+
+// neighbor 一道相似菜品
+type neighbor struct {
+	DishID  int
+	Score   float64
+	CoCount int // 同时对两道菜有过评分或点赞的用户数
+}
+
+// trainItemSimilarity 计算菜品两两之间的余弦相似度（带收缩），每道菜只保留最相似的 topK 个正相关菜品
+func trainItemSimilarity(prefs map[int]map[int]float64, topK int) map[int][]neighbor {
+	type pair struct{ a, b int }
+	dots := map[pair]float64{}
+	counts := map[pair]int{}
+	norms := map[int]float64{}
+
+	for _, items := range prefs {
+		ids := make([]int, 0, len(items))
+		for id, p := range items {
+			if p == 0 {
+				continue
+			}
+			ids = append(ids, id)
+			norms[id] += p * p
+		}
+		sort.Ints(ids)
+		for i := 0; i < len(ids); i++ {
+			for j := i + 1; j < len(ids); j++ {
+				k := pair{ids[i], ids[j]}
+				dots[k] += items[ids[i]] * items[ids[j]]
+				counts[k]++
+			}
+		}
+	}
+
+	sims := map[int][]neighbor{}
+	for k, dot := range dots {
+		n := counts[k]
+		score := dot / math.Sqrt(norms[k.a]*norms[k.b]) * float64(n) / (float64(n) + cfShrinkage)
+		if score <= 0 {
+			continue
+		}
+		sims[k.a] = append(sims[k.a], neighbor{DishID: k.b, Score: score, CoCount: n})
+		sims[k.b] = append(sims[k.b], neighbor{DishID: k.a, Score: score, CoCount: n})
+	}
+	for id, list := range sims {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].DishID < list[j].DishID
+		})
+		if len(list) > topK {
+			list = list[:topK]
+		}
+		sims[id] = list
+	}
+	return sims
+}
+
+// saveSimilarity 用新的训练结果整体替换 dish_similarity 表
+func saveSimilarity(ctx context.Context, db *sql.DB, sims map[int][]neighbor) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM dish_similarity"); err != nil {
+		return 0, err
+	}
+
+	ids := make([]int, 0, len(sims))
+	for id := range sims {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	now := time.Now()
+	var values []string
+	var args []interface{}
+	total := 0
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO dish_similarity (dish_id, similar_dish_id, score, co_count, updated_at) VALUES "+strings.Join(values, ", "),
+			args...)
+		values, args = values[:0], args[:0]
+		return err
+	}
+	for _, id := range ids {
+		for _, n := range sims[id] {
+			values = append(values, "(?, ?, ?, ?, ?)")
+			args = append(args, id, n.DishID, n.Score, n.CoCount, now)
+			total++
+			if len(values) == cfInsertBatch {
+				if err := flush(); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return total, tx.Commit()
+}
+
+// Trainer 定期重新训练协同过滤模型并保存到 dish_similarity 表
+type Trainer struct {
+	db       *sql.DB
+	interval time.Duration
+
+	mu       sync.Mutex // 同一时间只进行一次训练
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTrainer 创建训练任务，interval 为重新训练的间隔
+func NewTrainer(db *sql.DB, interval time.Duration) *Trainer {
+	return &Trainer{db: db, interval: interval, stop: make(chan struct{})}
+}
+
+// Train 训练一次并保存结果，返回保存的相似菜品对数；可以在服务之外单独调用
+func (t *Trainer) Train(ctx context.Context) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prefs, err := loadAllPreferences(ctx, t.db)
+	if err != nil {
+		return 0, err
+	}
+	return saveSimilarity(ctx, t.db, trainItemSimilarity(prefs, cfNeighbors))
+}
+
+// Start 在后台立即训练一次，之后每隔 interval 重新训练
+func (t *Trainer) Start() {
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			t.run()
+			select {
+			case <-ticker.C:
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定期训练
+func (t *Trainer) Stop() {
+	t.stopOnce.Do(func() { close(t.stop) })
+}
+
+func (t *Trainer) run() {
+	start := time.Now()
+	n, err := t.Train(context.Background())
+	if err != nil {
+		log.Printf("⚠️ 协同过滤训练失败: %v", err)
+		return
+	}
+	log.Printf("协同过滤训练完成，%d 对相似菜品，耗时 %v", n, time.Since(start))
+}
+
+// placeholders 生成 n 个以逗号分隔的 ?，用于 IN 查询
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// predictScores 按用户偏好和相似菜品预测对未接触过的菜品的喜爱程度
+func predictScores(prefs map[int]float64, sims map[int][]neighbor) map[int]float64 {
+	num := map[int]float64{}
+	den := map[int]float64{}
+	for dishID, p := range prefs {
+		for _, n := range sims[dishID] {
+			if _, seen := prefs[n.DishID]; seen {
+				continue
+			}
+			num[n.DishID] += n.Score * p
+			den[n.DishID] += math.Abs(n.Score)
+		}
+	}
+	scores := make(map[int]float64, len(num))
+	for id, v := range num {
+		scores[id] = v / (den[id] + cfPredictDamping)
+	}
+	return scores
+}
+
+// loadNeighbors 读取指定菜品的相似菜品
+func loadNeighbors(db *sql.DB, dishIDs []int) (map[int][]neighbor, error) {
+	sims := map[int][]neighbor{}
+	if len(dishIDs) == 0 {
+		return sims, nil
+	}
+	args := make([]interface{}, len(dishIDs))
+	for i, id := range dishIDs {
+		args[i] = id
+	}
+	rows, err := db.Query(
+		"SELECT dish_id, similar_dish_id, score, co_count FROM dish_similarity WHERE dish_id IN ("+placeholders(len(dishIDs))+")",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var n neighbor
+		if err := rows.Scan(&id, &n.DishID, &n.Score, &n.CoCount); err != nil {
+			return nil, err
+		}
+		sims[id] = append(sims[id], n)
+	}
+	return sims, rows.Err()
+}
@@ -0,0 +1,155 @@
+package recommend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreference(t *testing.T) {
+	assert.Equal(t, 1.0, preference(5, true, false))
+	assert.Equal(t, 0.0, preference(3, true, false))
+	assert.Equal(t, -1.0, preference(1, true, false))
+	assert.Equal(t, cfLikePref, preference(0, false, true))
+	assert.Equal(t, cfLikedFloor, preference(2, true, true))
+}
+
+func TestTrainItemSimilarity(t *testing.T) {
+	prefs := map[int]map[int]float64{
+		1: {10: 1, 11: 1, 12: -1},
+		2: {10: 1, 11: 0.5},
+		3: {10: 1, 11: 1, 13: 1},
+		4: {12: 1, 13: 1},
+	}
+	sims := trainItemSimilarity(prefs, 2)
+
+	// 10 和 11 被同样的用户喜欢，最相似
+	if assert.NotEmpty(t, sims[10]) {
+		assert.Equal(t, 11, sims[10][0].DishID)
+		assert.Equal(t, 3, sims[10][0].CoCount)
+	}
+	// 负相关的不保存
+	for _, n := range sims[10] {
+		assert.NotEqual(t, 12, n.DishID)
+	}
+	// 相似度对称
+	var s1011, s1110 float64
+	for _, n := range sims[10] {
+		if n.DishID == 11 {
+			s1011 = n.Score
+		}
+	}
+	for _, n := range sims[11] {
+		if n.DishID == 10 {
+			s1110 = n.Score
+		}
+	}
+	assert.InDelta(t, s1011, s1110, 1e-12)
+	assert.LessOrEqual(t, len(sims[10]), 2)
+}
+
+func TestPredictScores(t *testing.T) {
+	sims := map[int][]neighbor{
+		10: {{DishID: 11, Score: 0.8}, {DishID: 12, Score: 0.4}},
+		13: {{DishID: 12, Score: 0.9}},
+	}
+	scores := predictScores(map[int]float64{10: 1, 13: -1}, sims)
+	assert.Greater(t, scores[11], 0.0)
+	assert.Less(t, scores[12], 0.0) // 与不喜欢的菜更相似
+	_, seen := scores[10]
+	assert.False(t, seen)
+}
+
+func TestTrainer_Train(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT user_id, dish_id, score FROM dish_ratings`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "dish_id", "score"}).
+			AddRow(1, 10, 5.0).AddRow(1, 11, 4.0).AddRow(2, 10, 4.5))
+	mock.ExpectQuery("SELECT user_id, dish_id FROM `like`").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "dish_id"}).AddRow(2, 11))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM dish_similarity`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO dish_similarity \(dish_id, similar_dish_id, score, co_count, updated_at\) VALUES \(\?, \?, \?, \?, \?\), \(\?, \?, \?, \?, \?\)`).
+		WithArgs(10, 11, sqlmock.AnyArg(), 2, sqlmock.AnyArg(), 11, 10, sqlmock.AnyArg(), 2, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	n, err := NewTrainer(db, 0).Train(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetForYouHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/for-you", GetForYouHandler(db))
+
+	dishCols := []string{"id", "name", "price", "description", "taste", "score", "image_url", "created_at"}
+	mock.ExpectQuery("SELECT dish_id FROM `like` WHERE user_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id"}).AddRow(10))
+	mock.ExpectQuery(`SELECT dish_id, score FROM dish_ratings WHERE user_id = \?`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id", "score"}))
+	mock.ExpectQuery(`SELECT dish_id FROM recommend_history`).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id"}))
+	mock.ExpectQuery(`SELECT dish_id, similar_dish_id, score, co_count FROM dish_similarity WHERE dish_id IN \(\?\)`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id", "similar_dish_id", "score", "co_count"}).
+			AddRow(10, 11, 0.9, 3).AddRow(10, 12, 0.3, 2))
+	mock.ExpectQuery(`FROM dishes WHERE id IN \(\?, \?\)`).
+		WithArgs(11, 12).
+		WillReturnRows(sqlmock.NewRows(dishCols).
+			AddRow(12, "番茄蛋汤", 15.0, "", "酸甜", 4.2, "", "2024-01-01").
+			AddRow(11, "水煮鱼", 48.0, "", "麻辣", 4.6, "", "2024-01-01"))
+	mock.ExpectQuery(`FROM dishes ORDER BY score DESC LIMIT \?`).
+		WithArgs(6).
+		WillReturnRows(sqlmock.NewRows(dishCols).
+			AddRow(10, "麻婆豆腐", 20.0, "", "麻辣", 4.9, "", "2024-01-01").
+			AddRow(11, "水煮鱼", 48.0, "", "麻辣", 4.6, "", "2024-01-01").
+			AddRow(13, "清蒸鲈鱼", 58.0, "", "清淡", 4.5, "", "2024-01-01"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/for-you?user_id=1&limit=3", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Code   int          `json:"code"`
+		Dishes []ScoredDish `json:"dishes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Dishes, 3) {
+		assert.Equal(t, 11, resp.Dishes[0].ID)
+		assert.Equal(t, SourceCF, resp.Dishes[0].Source)
+		assert.Equal(t, 12, resp.Dishes[1].ID)
+		// 已点赞的 10 不推荐，补足的是高分菜品
+		assert.Equal(t, 13, resp.Dishes[2].ID)
+		assert.Equal(t, SourcePopular, resp.Dishes[2].Source)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// 未登录且 user_id 无效
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/for-you?user_id=abc", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
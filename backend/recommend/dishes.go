@@ -1,6 +1,9 @@
 package recommend
 
 import (
+	"backend/auth"
+	"backend/profile"
+	"backend/review"
 	"database/sql"
 	"fmt"
 	"net/http"
@@ -20,6 +23,9 @@ type Dish struct {
 	Score       float64 `json:"score"`
 	ImageURL    string  `json:"image_url"`
 	CreatedAt   string  `json:"created_at"`
+
+	Restaurant *Restaurant `json:"restaurant,omitempty"`  // 所属餐厅，只在按位置查询时返回
+	DistanceKm *float64    `json:"distance_km,omitempty"` // 与用户的距离（公里）
 }
 
 // GetAllDishes 获取所有菜品(评分从高到低)
@@ -49,26 +55,14 @@ func GetAllDishes(db *sql.DB) gin.HandlerFunc {
 // GetRandomDish 随机推荐菜品
 func GetRandomDish(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userIDStr := c.Query("user_id") // 从请求查询参数获取用户ID
-		if userIDStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "缺少 user_id 参数"})
+		// 当前登录用户；兼容模式下未登录时使用查询参数中的 user_id
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
 			return
 		}
 
-		var userID int
-		_, err := fmt.Sscanf(userIDStr, "%d", &userID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "user_id 参数无效"})
-			return
-		}
-
-		// 查询5个随机菜品
-		rows, err := db.Query(`
-			SELECT id, name, price, description, taste, score, image_url, created_at 
-			FROM dishes 
-			ORDER BY RAND() 
-			LIMIT 5
-		`)
+		rows, err := db.Query("SELECT id, name, price, description, taste, score, image_url, created_at FROM dishes")
 		if err != nil {
 			fmt.Println("查询失败:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "message": "查询失败"})
@@ -76,10 +70,7 @@ func GetRandomDish(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		var dishes []gin.H
-		var firstDishID int
-		var firstDishLiked bool
-
+		var all []Dish
 		for rows.Next() {
 			var d Dish
 			err := rows.Scan(&d.ID, &d.Name, &d.Price, &d.Description, &d.Taste, &d.Score, &d.ImageURL, &d.CreatedAt)
@@ -87,12 +78,15 @@ func GetRandomDish(db *sql.DB) gin.HandlerFunc {
 				c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据转换失败"})
 				return
 			}
+			all = append(all, d)
+		}
 
-			// 记录第一个菜品的ID
-			if len(dishes) == 0 {
-				firstDishID = d.ID
-			}
+		// 按用户的点赞、评分和最近推荐记录加权抽取
+		signals := loadUserSignals(db, userID)
+		picked := newRandomSampler(all, signals).sample(newRand(), all, randomCount)
 
+		dishes := []gin.H{}
+		for _, d := range picked {
 			dishes = append(dishes, gin.H{
 				"id":       d.ID,
 				"name":     d.Name,
@@ -100,22 +94,10 @@ func GetRandomDish(db *sql.DB) gin.HandlerFunc {
 				"reason":   d.Description,
 				"priceMin": int(d.Price * 0.9),
 				"priceMax": int(d.Price * 1.2),
-				"liked":    false, // 默认未点赞
+				"liked":    signals.liked[d.ID],
 			})
 		}
 
-		// 只查询第一个菜品的点赞情况
-		if len(dishes) > 0 {
-			err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM `like` WHERE user_id = ? AND dish_id = ?)", userID, firstDishID).Scan(&firstDishLiked)
-			if err != nil {
-				fmt.Println("查询点赞状态失败:", err)
-				// 不返回错误，继续执行
-			} else {
-				// 更新第一个菜品的点赞状态
-				dishes[0]["liked"] = firstDishLiked
-			}
-		}
-
 		c.JSON(http.StatusOK, gin.H{
 			"code":   0,
 			"dishes": dishes,
@@ -135,10 +117,14 @@ func AddRecommendHistory(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "请求参数错误"})
 			return
 		}
+		userID, ok := auth.CurrentUser(c, req.UserID)
+		if !ok {
+			return
+		}
 
 		_, err := db.Exec(`
 			INSERT INTO recommend_history (user_id, dish_id) 
-			VALUES (?, ?)`, req.UserID, req.DishID)
+			VALUES (?, ?)`, userID, req.DishID)
 
 		if err != nil {
 			fmt.Println("插入推荐历史失败：", err)
@@ -146,6 +132,8 @@ func AddRecommendHistory(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		profile.RefreshAsync(db, userID)
+
 		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "记录成功"})
 	}
 }
@@ -153,9 +141,9 @@ func AddRecommendHistory(db *sql.DB) gin.HandlerFunc {
 // GetRecommendHistory 获取用户最近的推荐记录
 func GetRecommendHistory(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Query("user_id")
-		if userID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "缺少 user_id"})
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
 			return
 		}
 
@@ -207,6 +195,10 @@ func AddCustomRecordHandler(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数绑定失败"})
 			return
 		}
+		userID, ok := auth.CurrentUser(c, req.UserID)
+		if !ok {
+			return
+		}
 
 		stmt := `
 			INSERT INTO custom_recommend_history 
@@ -214,7 +206,7 @@ func AddCustomRecordHandler(db *sql.DB) gin.HandlerFunc {
 			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`
 		_, err := db.Exec(stmt,
-			req.UserID, req.DishID, req.Taste, req.Distance,
+			userID, req.DishID, req.Taste, req.Distance,
 			req.Budget, req.Mood, req.Weather, req.Reason, time.Now(),
 		)
 
@@ -223,10 +215,15 @@ func AddCustomRecordHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		profile.RefreshAsync(db, userID)
+
 		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "定制推荐记录已保存"})
 	}
 }
 
+// detailReviewCount 菜品详情中展示的评论条数
+const detailReviewCount = 3
+
 // GetDishDetailHandler 获取菜品详情
 func GetDishDetailHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -260,32 +257,65 @@ func GetDishDetailHandler(db *sql.DB) gin.HandlerFunc {
 		// 默认未点赞
 		isLiked := false
 
-		// 从 query 中获取 user_id
-		userIDStr := c.Query("user_id")
-		if userIDStr != "" {
-			userID, err := strconv.Atoi(userIDStr)
-			if err == nil {
-				var count int
-				err := db.QueryRow("SELECT COUNT(*) FROM `like` WHERE user_id = ? AND dish_id = ?", userID, dishID).Scan(&count)
-				if err == nil && count > 0 {
-					isLiked = true
-				}
+		// 登录用户（或兼容模式下 query 中的 user_id）返回是否已点赞，匿名访问不查询
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.OptionalUser(c, claimed)
+		if !ok {
+			return
+		}
+		if userID > 0 {
+			var count int
+			err := db.QueryRow("SELECT COUNT(*) FROM `like` WHERE user_id = ? AND dish_id = ?", userID, dishID).Scan(&count)
+			if err == nil && count > 0 {
+				isLiked = true
+			}
+		}
+
+		data := gin.H{
+			"id":          dish.ID,
+			"name":        dish.Name,
+			"priceMin":    int(dish.Price * 0.9),
+			"priceMax":    int(dish.Price * 1.2),
+			"taste":       dish.Taste,
+			"score":       dish.Score,
+			"description": dish.Description,
+			"image":       dish.ImageURL,
+			"liked":       isLiked,
+		}
+
+		// 评分分布，查询失败时不返回
+		if dist, err := ratingDistribution(db, dishID); err != nil {
+			fmt.Println("查询评分分布失败:", err)
+		} else {
+			count := 0
+			for _, n := range dist {
+				count += n
+			}
+			data["rating_count"] = count
+			data["rating_distribution"] = dist
+		}
+
+		// 最新的几条已审核评论
+		if reviews, total, err := review.ApprovedReviews(db, dishID, detailReviewCount); err != nil {
+			fmt.Println("查询菜品评论失败:", err)
+		} else {
+			data["review_count"] = total
+			data["reviews"] = reviews
+		}
+
+		// with_similar=1 时一并返回相似菜品，查询失败不影响详情
+		if withSimilar, _ := strconv.ParseBool(c.Query("with_similar")); withSimilar {
+			similar, err := similarDishes(db, dish, defaultSimilarLimit)
+			if err != nil {
+				fmt.Println("查询相似菜品失败:", err)
+			} else {
+				data["similar"] = similar
 			}
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"code": 0,
-			"data": gin.H{
-				"id":          dish.ID,
-				"name":        dish.Name,
-				"priceMin":    int(dish.Price * 0.9),
-				"priceMax":    int(dish.Price * 1.2),
-				"taste":       dish.Taste,
-				"score":       dish.Score,
-				"description": dish.Description,
-				"image":       dish.ImageURL,
-				"liked":       isLiked,
-			},
+			"data": data,
 		})
 	}
 }
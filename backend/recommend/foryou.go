@@ -0,0 +1,166 @@
+package recommend
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"backend/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 猜你喜欢接口的默认值和上限
+const (
+	defaultForYouLimit = 10
+	maxForYouLimit     = 50
+)
+
+// 猜你喜欢结果来源
+const (
+	SourceCF      = "cf"      // 协同过滤
+	SourcePopular = "popular" // 数据不足时用高分菜品补足
+)
+
+// ScoredDish 带推荐得分的菜品
+type ScoredDish struct {
+	Dish
+	MatchScore float64 `json:"match_score"` // 预测的喜爱程度，-1 ~ 1
+	Source     string  `json:"source"`
+}
+
+// GetForYouHandler 猜你喜欢：根据协同过滤模型返回用户没有评过分、没有点过赞的菜品
+func GetForYouHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
+		limit := defaultForYouLimit
+		if s := c.Query("limit"); s != "" {
+			if v, err := strconv.Atoi(s); err == nil && v > 0 {
+				limit = min(v, maxForYouLimit)
+			}
+		}
+
+		prefs := userPreferences(loadUserSignals(db, userID))
+		rated := make([]int, 0, len(prefs))
+		for id := range prefs {
+			rated = append(rated, id)
+		}
+		sort.Ints(rated)
+
+		sims, err := loadNeighbors(db, rated)
+		if err != nil {
+			fmt.Println("查询相似菜品失败:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+
+		type candidate struct {
+			id    int
+			score float64
+		}
+		var ranked []candidate
+		for id, s := range predictScores(prefs, sims) {
+			if s > 0 {
+				ranked = append(ranked, candidate{id, s})
+			}
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			if ranked[i].score != ranked[j].score {
+				return ranked[i].score > ranked[j].score
+			}
+			return ranked[i].id < ranked[j].id
+		})
+		if len(ranked) > limit {
+			ranked = ranked[:limit]
+		}
+
+		ids := make([]int, len(ranked))
+		for i, r := range ranked {
+			ids[i] = r.id
+		}
+		byID, err := loadDishesByID(db, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+
+		result := []ScoredDish{}
+		used := map[int]bool{}
+		for _, r := range ranked {
+			if d, ok := byID[r.id]; ok {
+				result = append(result, ScoredDish{Dish: d, MatchScore: r.score, Source: SourceCF})
+				used[r.id] = true
+			}
+		}
+
+		// 新用户或模型还没有覆盖到时，用高分菜品补足
+		if len(result) < limit {
+			popular, err := loadPopularDishes(db, limit+len(prefs)+len(used))
+			if err != nil {
+				fmt.Println("查询高分菜品失败:", err)
+			}
+			for _, d := range popular {
+				if len(result) >= limit {
+					break
+				}
+				if _, seen := prefs[d.ID]; seen || used[d.ID] {
+					continue
+				}
+				result = append(result, ScoredDish{Dish: d, Source: SourcePopular})
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "dishes": result})
+	}
+}
+
+// loadDishesByID 按 ID 批量查询菜品
+func loadDishesByID(db *sql.DB, ids []int) (map[int]Dish, error) {
+	dishes := map[int]Dish{}
+	if len(ids) == 0 {
+		return dishes, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.Query(
+		"SELECT id, name, price, description, taste, score, image_url, created_at FROM dishes WHERE id IN ("+placeholders(len(ids))+")",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d Dish
+		if err := rows.Scan(&d.ID, &d.Name, &d.Price, &d.Description, &d.Taste, &d.Score, &d.ImageURL, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		dishes[d.ID] = d
+	}
+	return dishes, rows.Err()
+}
+
+// loadPopularDishes 按评分从高到低查询菜品
+func loadPopularDishes(db *sql.DB, limit int) ([]Dish, error) {
+	rows, err := db.Query("SELECT id, name, price, description, taste, score, image_url, created_at FROM dishes ORDER BY score DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dishes []Dish
+	for rows.Next() {
+		var d Dish
+		if err := rows.Scan(&d.ID, &d.Name, &d.Price, &d.Description, &d.Taste, &d.Score, &d.ImageURL, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		dishes = append(dishes, d)
+	}
+	return dishes, rows.Err()
+}
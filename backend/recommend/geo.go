@@ -0,0 +1,103 @@
+package recommend
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Restaurant 菜品所属餐厅
+type Restaurant struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// earthRadiusKm 地球平均半径
+const earthRadiusKm = 6371.0
+
+// haversineKm 计算两个经纬度之间的球面距离（公里）
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// boundingBox 返回以 (lat, lng) 为中心、半径 km 的经纬度范围，用于在 SQL 中先粗筛。
+// 经度范围跨过 ±180° 时 minLng > maxLng（如 [179.9, -179.9]），用 lngCondition 生成查询条件；
+// 范围包含极点时经度不限
+func boundingBox(lat, lng, km float64) (minLat, maxLat, minLng, maxLng float64) {
+	dLat := km / earthRadiusKm * 180 / math.Pi
+	dLng := dLat / math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	minLat, maxLat = math.Max(lat-dLat, -90), math.Min(lat+dLat, 90)
+	if dLng >= 180 || minLat == -90 || maxLat == 90 {
+		return minLat, maxLat, -180, 180
+	}
+	return minLat, maxLat, wrapLng(lng - dLng), wrapLng(lng + dLng)
+}
+
+// wrapLng 把经度换算到 [-180, 180]
+func wrapLng(lng float64) float64 {
+	if lng < -180 {
+		return lng + 360
+	}
+	if lng > 180 {
+		return lng - 360
+	}
+	return lng
+}
+
+// lngCondition 经度在 [minLng, maxLng] 内的查询条件，跨过 ±180° 时拆成两段
+func lngCondition(column string, minLng, maxLng float64) (string, []interface{}) {
+	if minLng <= maxLng {
+		return column + " BETWEEN ? AND ?", []interface{}{minLng, maxLng}
+	}
+	return "(" + column + " >= ? OR " + column + " <= ?)", []interface{}{minLng, maxLng}
+}
+
+var distancePattern = regexp.MustCompile(`^([\d.]+)\s*(km|千米|公里|m|米)?`)
+
+// 前端可能传入的描述性距离，按顺序匹配第一个包含的词
+var distanceWords = []struct {
+	word string
+	km   float64
+}{
+	{"不限", 0},
+	{"步行", 1},
+	{"附近", 1},
+	{"很近", 1},
+	{"骑车", 3},
+	{"打车", 10},
+	{"近", 2},
+	{"远", 10},
+}
+
+// parseDistance 解析距离偏好为公里数，如 "500m"、"1.5公里"、"步行"；无法解析或 "不限" 返回 0
+func parseDistance(s string) float64 {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "<")
+	s = strings.TrimPrefix(s, "≤")
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(s, "以内"), "内"))
+	if m := distancePattern.FindStringSubmatch(s); m != nil {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0
+		}
+		if m[2] == "m" || m[2] == "米" {
+			return v / 1000
+		}
+		return v
+	}
+	for _, w := range distanceWords {
+		if strings.Contains(s, w.word) {
+			return w.km
+		}
+	}
+	return 0
+}
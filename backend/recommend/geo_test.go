@@ -0,0 +1,158 @@
+package recommend
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHaversineKm(t *testing.T) {
+	assert.InDelta(t, 0, haversineKm(39.9, 116.4, 39.9, 116.4), 1e-9)
+	// 北京天安门到上海人民广场约 1067 公里
+	assert.InDelta(t, 1067, haversineKm(39.9087, 116.3975, 31.2304, 121.4737), 5)
+	// 纬度相差 0.01 度约 1.11 公里
+	assert.InDelta(t, 1.112, haversineKm(30, 120, 30.01, 120), 0.01)
+}
+
+func TestBoundingBox(t *testing.T) {
+	minLat, maxLat, minLng, maxLng := boundingBox(30, 120, 5)
+	// 范围四边到中心的距离都不小于半径
+	assert.GreaterOrEqual(t, haversineKm(30, 120, minLat, 120), 4.999)
+	assert.GreaterOrEqual(t, haversineKm(30, 120, maxLat, 120), 4.999)
+	assert.GreaterOrEqual(t, haversineKm(30, 120, 30, minLng), 4.999)
+	assert.GreaterOrEqual(t, haversineKm(30, 120, 30, maxLng), 4.999)
+
+	// 跨过 180° 经线时范围绕回负经度
+	_, _, minLng, maxLng = boundingBox(0, 179.99, 5)
+	assert.InDelta(t, 179.945, minLng, 0.001)
+	assert.InDelta(t, -179.965, maxLng, 0.001)
+	cond, args := lngCondition("lng", minLng, maxLng)
+	assert.Equal(t, "(lng >= ? OR lng <= ?)", cond)
+	assert.Equal(t, []interface{}{minLng, maxLng}, args)
+	_, _, minLng, maxLng = boundingBox(0, -179.99, 5)
+	assert.Greater(t, minLng, maxLng)
+
+	// 包含极点时经度不限
+	minLat, maxLat, minLng, maxLng = boundingBox(89.99, 0, 5)
+	assert.Equal(t, []float64{90, -180, 180}, []float64{maxLat, minLng, maxLng})
+	assert.Less(t, minLat, 89.99)
+}
+
+func TestParseDistance(t *testing.T) {
+	cases := map[string]float64{
+		"":       0,
+		"不限":     0,
+		"3":      3,
+		"1.5公里":  1.5,
+		"2km":    2,
+		"2 KM":   2,
+		"500m":   0.5,
+		"800米以内": 0.8,
+		"<3千米":   3,
+		"步行可达":   1,
+		"打车也行":   10,
+		"离我近一点":  2,
+		"随便":     0,
+	}
+	for in, want := range cases {
+		assert.InDelta(t, want, parseDistance(in), 1e-9, "输入 %q", in)
+	}
+}
+
+func TestScoreDish_Distance(t *testing.T) {
+	near, far := 0.5, 4.5
+	req := CustomRequest{Distance: "5公里"}
+	nearScore, reasons := scoreDish(req, Dish{ID: 1, DistanceKm: &near})
+	farScore, _ := scoreDish(req, Dish{ID: 2, DistanceKm: &far})
+	assert.Greater(t, nearScore, farScore)
+	assert.Contains(t, reasons, "距离你约0.5公里")
+}
+
+func mockDishWithRestaurantRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "name", "price", "description", "taste", "score", "image_url", "created_at",
+		"r_id", "r_name", "r_address", "latitude", "longitude",
+	}).AddRow(1, "鱼香肉丝", 28.0, "经典川菜", "咸鲜微辣", 4.7, "http://img.com/1.jpg", "2024-01-01",
+		1, "川味小馆", "一号路", 30.02, 120.0).
+		AddRow(2, "清蒸鲈鱼", 58.0, "清淡鲜美", "清淡", 4.8, "http://img.com/2.jpg", "2024-01-01",
+			2, "江南人家", "二号路", 30.005, 120.0).
+		AddRow(3, "番茄蛋汤", 15.0, "家常", "酸甜", 4.2, "http://img.com/3.jpg", "2024-01-01",
+			nil, nil, nil, nil, nil)
+}
+
+func TestGetNearbyDishesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/nearby", GetNearbyDishesHandler(db))
+
+	// 1. 参数错误
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/nearby?lat=abc&lng=120", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 2. 按距离排序，超出半径和没有位置的菜品被过滤
+	mock.ExpectQuery(`FROM dishes d\s+JOIN restaurants r ON d.restaurant_id = r.id\s+WHERE r.latitude BETWEEN`).
+		WillReturnRows(mockDishWithRestaurantRows())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/nearby?lat=30&lng=120&radius=3", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.Bytes()
+	assert.True(t, bytes.Index(body, []byte("清蒸鲈鱼")) < bytes.Index(body, []byte("鱼香肉丝")), w.Body.String())
+	assert.NotContains(t, w.Body.String(), "番茄蛋汤")
+
+	// 3. 半径较小时只剩最近的一道
+	mock.ExpectQuery(`FROM dishes d`).WillReturnRows(mockDishWithRestaurantRows())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/nearby?lat=30&lng=120&radius=1", nil)
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), "清蒸鲈鱼")
+	assert.NotContains(t, w.Body.String(), "鱼香肉丝")
+
+	// 4. 180° 经线附近，经线另一侧的餐厅也能查到
+	mock.ExpectQuery(`WHERE r.latitude BETWEEN \? AND \? AND \(r.longitude >= \? OR r.longitude <= \?\)`).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "name", "price", "description", "taste", "score", "image_url", "created_at",
+			"r_id", "r_name", "r_address", "latitude", "longitude",
+		}).AddRow(4, "椰子鸡", 68.0, "", "清淡", 4.5, "", "2024-01-01", 3, "岛上餐厅", "", -16.5, -179.95))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/nearby?lat=-16.5&lng=179.9&radius=20", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "椰子鸡")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoadCustomDishes_WithLocation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`LEFT JOIN restaurants r ON d.restaurant_id = r.id WHERE d.price <= \?`).
+		WithArgs(60).
+		WillReturnRows(mockDishWithRestaurantRows())
+
+	lat, lng := 30.0, 120.0
+	dishes, err := loadCustomDishes(db, CustomRequest{Budget: 60, Distance: "1.5公里", Latitude: &lat, Longitude: &lng})
+	assert.NoError(t, err)
+	if assert.Len(t, dishes, 1) {
+		assert.Equal(t, 2, dishes[0].ID)
+		assert.True(t, math.Abs(*dishes[0].DistanceKm-0.556) < 0.01)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
+
+	"backend/auth"
+	"backend/profile"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,17 +21,23 @@ type LikeRequest struct {
 func LikeDish(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req LikeRequest
-		if err := c.ShouldBindJSON(&req); err != nil || req.UserID == 0 || req.DishID == 0 {
+		if err := c.ShouldBindJSON(&req); err != nil || req.DishID == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数错误"})
 			return
 		}
+		userID, ok := auth.CurrentUser(c, req.UserID)
+		if !ok {
+			return
+		}
 
-		_, err := db.Exec("INSERT IGNORE INTO `like`(user_id, dish_id) VALUES (?, ?)", req.UserID, req.DishID)
+		_, err := db.Exec("INSERT IGNORE INTO `like`(user_id, dish_id) VALUES (?, ?)", userID, req.DishID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库写入失败"})
 			return
 		}
 
+		profile.RefreshAsync(db, userID)
+
 		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "点赞成功"})
 	}
 }
@@ -36,17 +46,23 @@ func LikeDish(db *sql.DB) gin.HandlerFunc {
 func UnlikeDish(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req LikeRequest
-		if err := c.ShouldBindJSON(&req); err != nil || req.UserID == 0 || req.DishID == 0 {
+		if err := c.ShouldBindJSON(&req); err != nil || req.DishID == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数错误"})
 			return
 		}
+		userID, ok := auth.CurrentUser(c, req.UserID)
+		if !ok {
+			return
+		}
 
-		_, err := db.Exec("DELETE FROM `like` WHERE user_id = ? AND dish_id = ?", req.UserID, req.DishID)
+		_, err := db.Exec("DELETE FROM `like` WHERE user_id = ? AND dish_id = ?", userID, req.DishID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "取消点赞失败"})
 			return
 		}
 
+		profile.RefreshAsync(db, userID)
+
 		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "已取消点赞"})
 	}
 }
@@ -54,14 +70,18 @@ func UnlikeDish(db *sql.DB) gin.HandlerFunc {
 // 获取用户收藏的菜品
 func GetUserLikes(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userId := c.Param("user_id")
+		claimed, _ := strconv.Atoi(c.Param("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
 
 		rows, err := db.Query(`
 			SELECT d.id, d.name, d.price, d.description, d.taste, d.score, d.image_url
 			FROM `+"`like`"+` l
 			JOIN dishes d ON l.dish_id = d.id
 			WHERE l.user_id = ?
-		`, userId)
+		`, userID)
 
 		if err != nil {
 			fmt.Println("查询失败:", err)
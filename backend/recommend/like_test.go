@@ -39,7 +39,7 @@ func TestLikeDish(t *testing.T) {
 
 	// 2. 参数错误
 	w = httptest.NewRecorder()
-	body = bytes.NewBufferString(`{"user_id":0,"dish_id":2}`)
+	body = bytes.NewBufferString(`{"user_id":1,"dish_id":0}`)
 	req, _ = http.NewRequest("POST", "/like", body)
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(w, req)
@@ -47,6 +47,16 @@ func TestLikeDish(t *testing.T) {
 		t.Errorf("参数错误校验失败，返回: %s", w.Body.String())
 	}
 
+	// 未登录也没有 user_id
+	w = httptest.NewRecorder()
+	body = bytes.NewBufferString(`{"dish_id":2}`)
+	req, _ = http.NewRequest("POST", "/like", body)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("未登录应返回 401，返回: %s", w.Body.String())
+	}
+
 	// 3. 数据库写入失败
 	mock.ExpectExec(`INSERT IGNORE INTO `+"`like`"+`\(user_id, dish_id\) VALUES \(\?, \?\)`).
 		WithArgs(3, 4).
@@ -89,7 +99,7 @@ func TestUnlikeDish(t *testing.T) {
 
 	// 2. 参数错误
 	w = httptest.NewRecorder()
-	body = bytes.NewBufferString(`{"user_id":0,"dish_id":2}`)
+	body = bytes.NewBufferString(`{"user_id":1,"dish_id":0}`)
 	req, _ = http.NewRequest("POST", "/unlike", body)
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(w, req)
@@ -97,6 +107,16 @@ func TestUnlikeDish(t *testing.T) {
 		t.Errorf("参数错误校验失败，返回: %s", w.Body.String())
 	}
 
+	// 未登录也没有 user_id
+	w = httptest.NewRecorder()
+	body = bytes.NewBufferString(`{"dish_id":2}`)
+	req, _ = http.NewRequest("POST", "/unlike", body)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("未登录应返回 401，返回: %s", w.Body.String())
+	}
+
 	// 3. 数据库操作失败
 	mock.ExpectExec(`DELETE FROM `+"`like`"+` WHERE user_id = \? AND dish_id = \?`).
 		WithArgs(3, 4).
@@ -130,7 +150,7 @@ func TestGetUserLikes(t *testing.T) {
 		AddRow(2, "宫保鸡丁", 32.0, "招牌菜", "微辣", 4.8, "http://img.com/2.jpg")
 
 	mock.ExpectQuery("SELECT d.id, d.name, d.price, d.description, d.taste, d.score, d.image_url").
-		WithArgs("123").
+		WithArgs(123).
 		WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
@@ -142,7 +162,7 @@ func TestGetUserLikes(t *testing.T) {
 
 	// 2. 查询失败
 	mock.ExpectQuery("SELECT d.id, d.name, d.price, d.description, d.taste, d.score, d.image_url").
-		WithArgs("999").
+		WithArgs(999).
 		WillReturnError(sql.ErrConnDone)
 
 	w = httptest.NewRecorder()
@@ -158,7 +178,7 @@ func TestGetUserLikes(t *testing.T) {
 	}).AddRow(1, "鱼香肉丝", "not-a-float", "经典川菜", "咸鲜微辣", 4.7, "http://img.com/1.jpg")
 
 	mock.ExpectQuery("SELECT d.id, d.name, d.price, d.description, d.taste, d.score, d.image_url").
-		WithArgs("888").
+		WithArgs(888).
 		WillReturnRows(badRows)
 
 	w = httptest.NewRecorder()
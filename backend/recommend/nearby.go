@@ -0,0 +1,124 @@
+package recommend
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 附近菜品接口的默认值和上限
+const (
+	defaultNearbyRadiusKm = 3.0
+	maxNearbyRadiusKm     = 50.0
+	defaultNearbyLimit    = 20
+	maxNearbyLimit        = 100
+)
+
+// dishWithRestaurantColumns 菜品连同所属餐厅一起查询时的字段，配合 scanDishWithRestaurant 使用
+const dishWithRestaurantColumns = `d.id, d.name, d.price, d.description, d.taste, d.score, d.image_url, d.created_at,
+	r.id, r.name, r.address, r.latitude, r.longitude`
+
+// scanDishWithRestaurant 读取一行菜品和餐厅信息，菜品没有关联餐厅时 Restaurant 为 nil
+func scanDishWithRestaurant(rows *sql.Rows) (Dish, error) {
+	var d Dish
+	var rID sql.NullInt64
+	var rName, rAddress sql.NullString
+	var rLat, rLng sql.NullFloat64
+	err := rows.Scan(&d.ID, &d.Name, &d.Price, &d.Description, &d.Taste, &d.Score, &d.ImageURL, &d.CreatedAt,
+		&rID, &rName, &rAddress, &rLat, &rLng)
+	if err != nil {
+		return d, err
+	}
+	if rID.Valid && rLat.Valid && rLng.Valid {
+		d.Restaurant = &Restaurant{
+			ID:        int(rID.Int64),
+			Name:      rName.String,
+			Address:   rAddress.String,
+			Latitude:  rLat.Float64,
+			Longitude: rLng.Float64,
+		}
+	}
+	return d, nil
+}
+
+// withDistance 计算每道菜与用户的距离；radiusKm > 0 时去掉超出范围或没有位置的菜品
+func withDistance(dishes []Dish, lat, lng, radiusKm float64) []Dish {
+	var out []Dish
+	for _, d := range dishes {
+		if d.Restaurant != nil {
+			km := haversineKm(lat, lng, d.Restaurant.Latitude, d.Restaurant.Longitude)
+			d.DistanceKm = &km
+		}
+		if radiusKm > 0 && (d.DistanceKm == nil || *d.DistanceKm > radiusKm) {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// GetNearbyDishesHandler 获取用户附近的菜品，按距离从近到远排序
+func GetNearbyDishesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lat, err1 := strconv.ParseFloat(c.Query("lat"), 64)
+		lng, err2 := strconv.ParseFloat(c.Query("lng"), 64)
+		if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "经纬度参数无效"})
+			return
+		}
+
+		radius := defaultNearbyRadiusKm
+		if s := c.Query("radius"); s != "" {
+			if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 {
+				radius = min(v, maxNearbyRadiusKm)
+			}
+		}
+		limit := defaultNearbyLimit
+		if s := c.Query("limit"); s != "" {
+			if v, err := strconv.Atoi(s); err == nil && v > 0 {
+				limit = min(v, maxNearbyLimit)
+			}
+		}
+
+		// 先用经纬度范围粗筛，再精确计算距离
+		minLat, maxLat, minLng, maxLng := boundingBox(lat, lng, radius)
+		lngCond, lngArgs := lngCondition("r.longitude", minLng, maxLng)
+		rows, err := db.Query(`
+			SELECT `+dishWithRestaurantColumns+`
+			FROM dishes d
+			JOIN restaurants r ON d.restaurant_id = r.id
+			WHERE r.latitude BETWEEN ? AND ? AND `+lngCond,
+			append([]interface{}{minLat, maxLat}, lngArgs...)...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		defer rows.Close()
+
+		var dishes []Dish
+		for rows.Next() {
+			d, err := scanDishWithRestaurant(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": "数据转换失败"})
+				return
+			}
+			dishes = append(dishes, d)
+		}
+
+		dishes = withDistance(dishes, lat, lng, radius)
+		sort.SliceStable(dishes, func(i, j int) bool {
+			return *dishes[i].DistanceKm < *dishes[j].DistanceKm
+		})
+		if len(dishes) > limit {
+			dishes = dishes[:limit]
+		}
+		if dishes == nil {
+			dishes = []Dish{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "radius_km": radius, "dishes": dishes})
+	}
+}
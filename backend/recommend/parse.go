@@ -0,0 +1,199 @@
+package recommend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// aiPick AI 给出的推荐结果，要求模型按这个结构输出 JSON
+type aiPick struct {
+	DishID     int
+	Name       string
+	Reason     string
+	Confidence float64
+}
+
+// 推荐理由的最大长度（字），超出部分截断
+const maxReasonRunes = 80
+
+// 模糊匹配菜名时允许的最低相似度
+const minNameSimilarity = 0.6
+
+// parsePick 解析 AI 回复并匹配到候选菜品。
+// 优先按 JSON 解析，失败时兼容旧的 "推荐：/理由：" 文本格式；菜品先按 ID 匹配，再按菜名模糊匹配
+func parsePick(reply string, candidates []Dish) (Dish, aiPick, error) {
+	pick, err := parseJSONPick(reply)
+	if err != nil {
+		var textErr error
+		pick, textErr = parseTextPick(reply)
+		if textErr != nil {
+			return Dish{}, aiPick{}, err
+		}
+	}
+
+	dish, ok := matchDish(pick, candidates)
+	if !ok {
+		if pick.Name != "" {
+			return Dish{}, pick, fmt.Errorf("未在候选菜品中找到推荐菜品：%s", pick.Name)
+		}
+		return Dish{}, pick, fmt.Errorf("未在候选菜品中找到推荐菜品 ID：%d", pick.DishID)
+	}
+	return dish, pick, nil
+}
+
+// parseJSONPick 从回复中提取 JSON 对象并校验字段
+func parseJSONPick(reply string) (aiPick, error) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end <= start {
+		return aiPick{}, errors.New("回复中没有 JSON 对象")
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &raw); err != nil {
+		return aiPick{}, fmt.Errorf("JSON 格式错误: %v", err)
+	}
+
+	var pick aiPick
+	var problems []string
+
+	switch v := raw["dish_id"].(type) {
+	case float64:
+		pick.DishID = int(v)
+	case string:
+		pick.DishID, _ = strconv.Atoi(strings.TrimSpace(v))
+	}
+	pick.Name, _ = raw["name"].(string)
+	pick.Name = strings.TrimSpace(pick.Name)
+	if pick.DishID <= 0 && pick.Name == "" {
+		problems = append(problems, "dish_id 和 name 至少提供一个")
+	}
+
+	pick.Reason, _ = raw["reason"].(string)
+	pick.Reason = strings.TrimSpace(pick.Reason)
+	if pick.Reason == "" {
+		problems = append(problems, "缺少 reason")
+	} else if utf8.RuneCountInString(pick.Reason) > maxReasonRunes {
+		pick.Reason = string([]rune(pick.Reason)[:maxReasonRunes])
+	}
+
+	switch v := raw["confidence"].(type) {
+	case float64:
+		pick.Confidence = v
+	case string:
+		pick.Confidence, _ = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "%"), 64)
+	}
+	if pick.Confidence > 1 && pick.Confidence <= 100 {
+		pick.Confidence /= 100 // 按百分比给出
+	}
+	if pick.Confidence < 0 || pick.Confidence > 1 {
+		problems = append(problems, "confidence 必须在 0 到 1 之间")
+	}
+
+	if len(problems) > 0 {
+		return aiPick{}, errors.New(strings.Join(problems, "; "))
+	}
+	return pick, nil
+}
+
+// parseTextPick 兼容旧的文本格式：推荐：<菜名> / 理由：<理由>
+func parseTextPick(reply string) (aiPick, error) {
+	var pick aiPick
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		for _, sep := range []string{"：", ":"} {
+			if strings.HasPrefix(line, "推荐"+sep) {
+				pick.Name = strings.TrimSpace(strings.TrimPrefix(line, "推荐"+sep))
+			} else if strings.HasPrefix(line, "理由"+sep) {
+				pick.Reason = strings.TrimSpace(strings.TrimPrefix(line, "理由"+sep))
+			}
+		}
+	}
+	if pick.Name == "" {
+		return aiPick{}, errors.New("AI输出中未提取到推荐菜名")
+	}
+	return pick, nil
+}
+
+// matchDish 在候选菜品中查找 AI 推荐的菜品。
+// ID 命中且菜名不冲突时直接采用；否则按菜名匹配：完全一致 > 互相包含 > 编辑距离相似度
+func matchDish(pick aiPick, candidates []Dish) (Dish, bool) {
+	name := normalizeName(pick.Name)
+	for _, d := range candidates {
+		if pick.DishID > 0 && d.ID == pick.DishID {
+			if name == "" || nameSimilarity(name, normalizeName(d.Name)) >= minNameSimilarity {
+				return d, true
+			}
+		}
+	}
+	if name == "" {
+		return Dish{}, false
+	}
+
+	var best Dish
+	bestScore := 0.0
+	for _, d := range candidates {
+		candidate := normalizeName(d.Name)
+		var score float64
+		switch {
+		case candidate == name:
+			return d, true
+		case utf8.RuneCountInString(candidate) >= 2 && utf8.RuneCountInString(name) >= 2 &&
+			(strings.Contains(name, candidate) || strings.Contains(candidate, name)):
+			// 例如 "招牌宫保鸡丁" 与 "宫保鸡丁"，越接近完整越好
+			score = 0.9 + 0.1*float64(min(len(candidate), len(name)))/float64(max(len(candidate), len(name)))
+		default:
+			score = nameSimilarity(name, candidate)
+		}
+		if score > bestScore {
+			best, bestScore = d, score
+		}
+	}
+	return best, bestScore >= minNameSimilarity
+}
+
+// normalizeName 去掉空白、标点和书名号等符号，统一大小写，便于比较菜名
+func normalizeName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// nameSimilarity 基于编辑距离的相似度，1 表示完全相同
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,46 @@
+package recommend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePick(t *testing.T) {
+	candidates := []Dish{
+		{ID: 1, Name: "宫保鸡丁", Price: 32},
+		{ID: 2, Name: "清蒸鲈鱼", Price: 58},
+		{ID: 3, Name: "麻婆豆腐", Price: 18},
+	}
+
+	cases := []struct {
+		name    string
+		reply   string
+		wantID  int
+		wantErr bool
+	}{
+		{"标准JSON", `{"dish_id":2,"name":"清蒸鲈鱼","reason":"清淡","confidence":0.8}`, 2, false},
+		{"代码块包裹", "```json\n{\"dish_id\":\"3\",\"name\":\"麻婆豆腐\",\"reason\":\"下饭\",\"confidence\":\"90%\"}\n```", 3, false},
+		{"ID与菜名冲突以菜名为准", `{"dish_id":1,"name":"麻婆豆腐","reason":"下饭","confidence":0.5}`, 3, false},
+		{"错别字模糊匹配", `{"name":"宫爆鸡丁","reason":"香辣","confidence":0.7}`, 1, false},
+		{"菜名带修饰", `{"name":"招牌宫保鸡丁","reason":"香辣","confidence":0.7}`, 1, false},
+		{"旧文本格式", "推荐：清蒸鲈鱼\n理由：天气热", 2, false},
+		{"缺少理由", `{"dish_id":2,"confidence":0.8}`, 0, true},
+		{"不存在的菜", `{"name":"佛跳墙","reason":"好吃","confidence":0.8}`, 0, true},
+		{"无法解析", "随便吃点吧", 0, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dish, pick, err := parsePick(tc.reply, candidates)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantID, dish.ID)
+			assert.NotEmpty(t, pick.Reason)
+			assert.True(t, pick.Confidence >= 0 && pick.Confidence <= 1)
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package recommend
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"time"
+	"unicode"
+)
+
+// 随机推荐的参数
+const (
+	randomCount      = 5   // 每次推荐的菜品数
+	exploreSlots     = 1   // 其中留给"换换口味"的名额，从和用户偏好不相近的菜品中均匀抽取
+	recentDays       = 7   // 最近多少天推荐过的菜品会被降权
+	recentPenalty    = 0.3 // 最近每推荐过一次，权重乘以该系数
+	affinityWeight   = 2.0 // 与喜欢的菜品口味相近时的加权
+	dislikedPenalty  = 0.2 // 用户打过低分的菜品权重乘以该系数
+	goodRating       = 4.0 // 评分不低于该值视为喜欢
+	badRating        = 2.0 // 评分不高于该值视为不喜欢
+	exploreAffinity  = 0.3 // 口味相似度低于该值的菜品才算探索
+	minDishWeight    = 0.01
+	maxRandomHistory = 200 // 读取最近推荐记录的上限
+)
+
+// newRand 每次请求创建一个随机数来源，测试中可以替换为固定种子
+var newRand = func() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// userSignals 用户的点赞、评分和最近推荐记录
+type userSignals struct {
+	liked   map[int]bool
+	ratings map[int]float64
+	recent  map[int]int // 菜品 ID -> 最近被推荐的次数
+}
+
+// loadUserSignals 读取用户偏好，某一项查询失败时只记录日志，按没有该项数据处理
+func loadUserSignals(db *sql.DB, userID int) userSignals {
+	s := userSignals{liked: map[int]bool{}, ratings: map[int]float64{}, recent: map[int]int{}}
+
+	if rows, err := db.Query("SELECT dish_id FROM `like` WHERE user_id = ?", userID); err != nil {
+		fmt.Println("查询点赞记录失败:", err)
+	} else {
+		for rows.Next() {
+			var id int
+			if rows.Scan(&id) == nil {
+				s.liked[id] = true
+			}
+		}
+		rows.Close()
+	}
+
+	if rows, err := db.Query("SELECT dish_id, score FROM dish_ratings WHERE user_id = ?", userID); err != nil {
+		fmt.Println("查询评分记录失败:", err)
+	} else {
+		for rows.Next() {
+			var id int
+			var score float64
+			if rows.Scan(&id, &score) == nil {
+				s.ratings[id] = score
+			}
+		}
+		rows.Close()
+	}
+
+	since := time.Now().AddDate(0, 0, -recentDays)
+	if rows, err := db.Query(`
+		SELECT dish_id FROM recommend_history
+		WHERE user_id = ? AND recommended_at >= ?
+		ORDER BY recommended_at DESC
+		LIMIT ?
+	`, userID, since, maxRandomHistory); err != nil {
+		fmt.Println("查询推荐历史失败:", err)
+	} else {
+		for rows.Next() {
+			var id int
+			if rows.Scan(&id) == nil {
+				s.recent[id]++
+			}
+		}
+		rows.Close()
+	}
+	return s
+}
+
+// tasteRunes 口味中的每个字作为一个特征，如 "咸鲜微辣" -> {咸 鲜 微 辣}
+func tasteRunes(taste string) map[rune]bool {
+	set := map[rune]bool{}
+	for _, r := range taste {
+		if unicode.IsLetter(r) {
+			set[r] = true
+		}
+	}
+	return set
+}
+
+// tasteSimilarity 两道菜口味的 Jaccard 相似度
+func tasteSimilarity(a, b string) float64 {
+	ra, rb := tasteRunes(a), tasteRunes(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	inter := 0
+	for r := range ra {
+		if rb[r] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(ra)+len(rb)-inter)
+}
+
+// randomSampler 按用户偏好给菜品加权并抽样
+type randomSampler struct {
+	signals  userSignals
+	liked    []Dish // 点赞或打了高分的菜品
+	disliked []Dish // 打了低分的菜品
+}
+
+func newRandomSampler(dishes []Dish, signals userSignals) *randomSampler {
+	s := &randomSampler{signals: signals}
+	for _, d := range dishes {
+		rating, rated := signals.ratings[d.ID]
+		switch {
+		case rated && rating <= badRating:
+			s.disliked = append(s.disliked, d)
+		case signals.liked[d.ID] || (rated && rating >= goodRating):
+			s.liked = append(s.liked, d)
+		}
+	}
+	return s
+}
+
+// affinity 菜品与用户喜欢的菜品的口味相近程度，与不喜欢的菜品相近时减分
+func (s *randomSampler) affinity(d Dish) float64 {
+	var pos, neg float64
+	for _, p := range s.liked {
+		if p.ID != d.ID {
+			pos = max(pos, tasteSimilarity(d.Taste, p.Taste))
+		}
+	}
+	for _, n := range s.disliked {
+		if n.ID != d.ID {
+			neg = max(neg, tasteSimilarity(d.Taste, n.Taste))
+		}
+	}
+	return pos - neg/2
+}
+
+// weight 菜品的抽样权重：评分越高、与喜欢的菜越相近权重越大，最近推荐过或打过低分的降权
+func (s *randomSampler) weight(d Dish) float64 {
+	w := (0.5 + d.Score/5) * (1 + affinityWeight*s.affinity(d))
+	for i := 0; i < s.signals.recent[d.ID]; i++ {
+		w *= recentPenalty
+	}
+	if rating, ok := s.signals.ratings[d.ID]; ok && rating <= badRating {
+		w *= dislikedPenalty
+	}
+	return max(w, minDishWeight)
+}
+
+// sample 抽取 n 道不重复的菜品：先按权重抽取，再留 exploreSlots 个名额给口味不同、最近没推荐过的菜品
+func (s *randomSampler) sample(rng *rand.Rand, dishes []Dish, n int) []Dish {
+	if n > len(dishes) {
+		n = len(dishes)
+	}
+	explore := min(exploreSlots, n)
+	if len(s.liked) == 0 {
+		explore = 0 // 没有偏好数据时按权重抽取本身就是探索
+	}
+
+	weights := make([]float64, len(dishes))
+	for i, d := range dishes {
+		weights[i] = s.weight(d)
+	}
+	picked := weightedSample(rng, weights, n-explore)
+
+	used := map[int]bool{}
+	for _, i := range picked {
+		used[i] = true
+	}
+	var pool, fallback []int
+	for i, d := range dishes {
+		if used[i] {
+			continue
+		}
+		fallback = append(fallback, i)
+		if s.signals.recent[d.ID] == 0 && s.affinity(d) < exploreAffinity {
+			pool = append(pool, i)
+		}
+	}
+	if len(pool) < explore {
+		pool = fallback
+	}
+	rng.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	picked = append(picked, pool[:explore]...)
+
+	result := make([]Dish, len(picked))
+	for i, idx := range picked {
+		result[i] = dishes[idx]
+	}
+	return result
+}
+
+// weightedSample 按权重不放回地抽取 n 个下标
+func weightedSample(rng *rand.Rand, weights []float64, n int) []int {
+	remaining := make([]float64, len(weights))
+	copy(remaining, weights)
+	var total float64
+	for _, w := range remaining {
+		total += w
+	}
+
+	var picked []int
+	for len(picked) < n && total > 0 {
+		r := rng.Float64() * total
+		idx := -1
+		for i, w := range remaining {
+			if w <= 0 {
+				continue
+			}
+			idx = i
+			if r < w {
+				break
+			}
+			r -= w
+		}
+		if idx < 0 {
+			break
+		}
+		picked = append(picked, idx)
+		total -= remaining[idx]
+		remaining[idx] = 0
+	}
+	return picked
+}
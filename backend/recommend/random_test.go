@@ -0,0 +1,139 @@
+package recommend
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTasteSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, tasteSimilarity("麻辣", "麻辣"))
+	assert.Equal(t, 0.0, tasteSimilarity("麻辣", "清淡"))
+	assert.InDelta(t, 0.2, tasteSimilarity("咸鲜微辣", "麻辣"), 1e-9)
+	assert.Equal(t, 0.0, tasteSimilarity("", "麻辣"))
+}
+
+func TestWeightedSample(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	counts := make([]int, 3)
+	for i := 0; i < 3000; i++ {
+		counts[weightedSample(rng, []float64{1, 8, 1}, 1)[0]]++
+	}
+	assert.Greater(t, counts[1], counts[0]*4)
+	assert.Greater(t, counts[1], counts[2]*4)
+
+	// 不放回，且不会超过可抽取的数量
+	picked := weightedSample(rng, []float64{1, 1, 1}, 5)
+	assert.ElementsMatch(t, []int{0, 1, 2}, picked)
+}
+
+func sampleDishes() []Dish {
+	return []Dish{
+		{ID: 1, Name: "水煮鱼", Taste: "麻辣", Score: 4.5},
+		{ID: 2, Name: "麻婆豆腐", Taste: "麻辣", Score: 4.5},
+		{ID: 3, Name: "辣子鸡", Taste: "香辣", Score: 4.5},
+		{ID: 4, Name: "白切鸡", Taste: "清淡", Score: 4.5},
+		{ID: 5, Name: "清蒸鱼", Taste: "清淡", Score: 4.5},
+		{ID: 6, Name: "糖醋排骨", Taste: "酸甜", Score: 4.5},
+		{ID: 7, Name: "拔丝地瓜", Taste: "甜", Score: 4.5},
+	}
+}
+
+func TestRandomSampler_Weight(t *testing.T) {
+	dishes := sampleDishes()
+	s := newRandomSampler(dishes, userSignals{
+		liked:   map[int]bool{1: true},
+		ratings: map[int]float64{4: 1},
+		recent:  map[int]int{2: 1},
+	})
+
+	// 与点赞菜品口味相近的加权
+	assert.Greater(t, s.weight(dishes[2]), s.weight(dishes[5]))
+	// 最近推荐过的降权
+	assert.Less(t, s.weight(dishes[1]), s.weight(Dish{ID: 99, Taste: "麻辣", Score: 4.5}))
+	// 打过低分的降权，口味相近的也降权
+	assert.Less(t, s.weight(dishes[3]), s.weight(dishes[5]))
+	assert.Less(t, s.weight(dishes[4]), s.weight(dishes[5]))
+}
+
+func TestRandomSampler_Sample(t *testing.T) {
+	dishes := sampleDishes()
+	s := newRandomSampler(dishes, userSignals{
+		liked:   map[int]bool{1: true, 2: true},
+		ratings: map[int]float64{},
+		recent:  map[int]int{},
+	})
+
+	rng := rand.New(rand.NewSource(42))
+	for i := 0; i < 50; i++ {
+		picked := s.sample(rng, dishes, randomCount)
+		assert.Len(t, picked, randomCount)
+
+		ids := map[int]bool{}
+		explored := false
+		for _, d := range picked {
+			assert.False(t, ids[d.ID], "不应重复推荐")
+			ids[d.ID] = true
+			if s.affinity(d) < exploreAffinity {
+				explored = true
+			}
+		}
+		assert.True(t, explored, "至少包含一道口味不同的菜")
+	}
+
+	// 菜品不足时全部返回
+	assert.Len(t, s.sample(rng, dishes[:3], randomCount), 3)
+}
+
+func TestGetRandomDish(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	orig := newRand
+	newRand = func() *rand.Rand { return rand.New(rand.NewSource(7)) }
+	defer func() { newRand = orig }()
+
+	r := gin.New()
+	r.GET("/random", GetRandomDish(db))
+
+	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "taste", "score", "image_url", "created_at"})
+	for _, d := range sampleDishes() {
+		rows.AddRow(d.ID, d.Name, 30.0, "", d.Taste, d.Score, "", "2024-01-01")
+	}
+	mock.ExpectQuery(`SELECT id, name, price, description, taste, score, image_url, created_at FROM dishes`).
+		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT dish_id FROM `like` WHERE user_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id"}).AddRow(1).AddRow(2).AddRow(3).AddRow(4).AddRow(5).AddRow(6).AddRow(7))
+	mock.ExpectQuery(`SELECT dish_id, score FROM dish_ratings WHERE user_id = \?`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id", "score"}))
+	mock.ExpectQuery(`SELECT dish_id FROM recommend_history`).
+		WithArgs(1, sqlmock.AnyArg(), maxRandomHistory).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id"}).AddRow(3))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/random?user_id=1", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	// 所有菜品都已点赞，每一道都应返回 liked: true
+	assert.Equal(t, randomCount, strings.Count(w.Body.String(), `"liked":true`), w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// 未登录也没有 user_id
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/random", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
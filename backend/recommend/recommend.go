@@ -1,15 +1,14 @@
 package recommend
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
+	"backend/llm"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -21,10 +20,19 @@ type CustomRequest struct {
 	Budget   int    `json:"budget"`
 	Mood     string `json:"mood"`
 	Weather  string `json:"weather"`
+
+	// 用户位置，可选；提供后按 Distance 过滤并优先推荐近的菜品
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// hasLocation 请求是否带有用户位置
+func (r CustomRequest) hasLocation() bool {
+	return r.Latitude != nil && r.Longitude != nil
 }
 
-// CustomDishHandler 处理定制推荐请求
-func CustomDishHandler(apiKey string, db *sql.DB) gin.HandlerFunc {
+// CustomDishHandler 处理定制推荐请求，provider 为 nil 表示未启用 AI，直接使用规则推荐
+func CustomDishHandler(provider llm.Provider, db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CustomRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -33,56 +41,101 @@ func CustomDishHandler(apiKey string, db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Step 1: 查询所有菜品
-		rows, err := db.Query(`
-			SELECT id, name, price, description, taste, score, image_url, created_at
-			FROM dishes
-		`)
+		// Step 1: 查询预算内的菜品，带位置时计算距离并按距离偏好过滤
+		dishes, err := loadCustomDishes(db, req)
 		if err != nil {
 			fmt.Println("❌ 数据库查询失败:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
 			return
 		}
-		defer rows.Close()
 
-		var dishes []Dish
-		for rows.Next() {
-			var d Dish
-			if err := rows.Scan(&d.ID, &d.Name, &d.Price, &d.Description, &d.Taste, &d.Score, &d.ImageURL, &d.CreatedAt); err == nil {
-				dishes = append(dishes, d)
+		// Step 2: 按口味和相关度预选候选菜品，控制 prompt 长度
+		dishes = selectCandidates(req, dishes, maxCandidates)
+		if len(dishes) == 0 {
+			c.JSON(http.StatusOK, gin.H{"code": 4, "message": "预算内没有可推荐的菜品"})
+			return
+		}
+
+		// Step 3: 调用 AI，AI 未启用或失败时使用本地规则推荐
+		var selectedDish Dish
+		var pick aiPick
+		engine := EngineRule
+		if provider != nil {
+			prompt := buildPrompt(req, dishes)
+			fmt.Println("📨 Prompt 提交给 AI:", prompt)
+
+			selectedDish, pick, err = askAI(c.Request.Context(), provider, prompt, req, dishes)
+			if err == nil {
+				engine = EngineAI
 			} else {
-				fmt.Println("❌ 读取菜品失败:", err)
+				fmt.Println("❌ AI推荐失败，改用规则推荐:", err)
 			}
 		}
-
-		// Step 2: 构造 prompt
-		prompt := buildPrompt(req, dishes)
-		fmt.Println("📨 Prompt 提交给 AI:", prompt)
-
-		// Step 3: 调用 DeepSeek（假设已封装好）
-		selectedDish, reason, err := callDeepSeek(apiKey, prompt, dishes)
-		if err != nil {
-			fmt.Println("❌ AI推荐失败:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": err.Error()})
-			return
+		if engine == EngineRule {
+			selectedDish, pick, _ = ruleRecommend(req, dishes)
 		}
 
 		// Step 4: 构造响应
 		c.JSON(http.StatusOK, gin.H{
-			"code": 0,
+			"code":   0,
+			"engine": engine,
 			"dish": gin.H{
-				"id":       selectedDish.ID,
-				"name":     selectedDish.Name,
-				"image":    selectedDish.ImageURL,
-				"reason":   reason,
-				"priceMin": int(selectedDish.Price * 0.9),
-				"priceMax": int(selectedDish.Price * 1.2),
-				"liked":    false, // TODO: 可查 like 表
+				"id":         selectedDish.ID,
+				"name":       selectedDish.Name,
+				"image":      selectedDish.ImageURL,
+				"reason":     pick.Reason,
+				"confidence": pick.Confidence,
+				"priceMin":   int(selectedDish.Price * 0.9),
+				"priceMax":   int(selectedDish.Price * 1.2),
+				"restaurant": selectedDish.Restaurant,
+				"distance":   selectedDish.DistanceKm,
+				"liked":      false, // TODO: 可查 like 表
 			},
 		})
 	}
 }
 
+// loadCustomDishes 查询定制推荐的菜品：按预算过滤，带位置时连同餐厅一起查询并计算距离
+func loadCustomDishes(db *sql.DB, req CustomRequest) ([]Dish, error) {
+	query := "SELECT id, name, price, description, taste, score, image_url, created_at FROM dishes"
+	priceColumn := "price"
+	if req.hasLocation() {
+		query = "SELECT " + dishWithRestaurantColumns + " FROM dishes d LEFT JOIN restaurants r ON d.restaurant_id = r.id"
+		priceColumn = "d.price"
+	}
+	var args []interface{}
+	if req.Budget > 0 {
+		query += " WHERE " + priceColumn + " <= ?"
+		args = append(args, req.Budget)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dishes []Dish
+	for rows.Next() {
+		var d Dish
+		var err error
+		if req.hasLocation() {
+			d, err = scanDishWithRestaurant(rows)
+		} else {
+			err = rows.Scan(&d.ID, &d.Name, &d.Price, &d.Description, &d.Taste, &d.Score, &d.ImageURL, &d.CreatedAt)
+		}
+		if err != nil {
+			fmt.Println("❌ 读取菜品失败:", err)
+			continue
+		}
+		dishes = append(dishes, d)
+	}
+
+	if req.hasLocation() {
+		dishes = withDistance(dishes, *req.Latitude, *req.Longitude, parseDistance(req.Distance))
+	}
+	return dishes, nil
+}
+
 // 构建 prompt
 func buildPrompt(req CustomRequest, dishes []Dish) string {
 	prompt := fmt.Sprintf(`你是一个美食推荐助手，用户的需求如下：
@@ -90,108 +143,62 @@ func buildPrompt(req CustomRequest, dishes []Dish) string {
 - 心情: %s
 - 天气: %s
 - 预算: %d 元以内
+- 距离: %s
 
 以下是候选菜品，请选择一个。
-请按照以下格式输出：
-推荐：<菜名>
-理由：<推荐理由（不超过50字）>
+请只输出一个 JSON 对象，格式如下：
+{"dish_id": <候选菜品的ID>, "name": "<菜名>", "reason": "<推荐理由（不超过50字）>", "confidence": <0到1之间的把握程度>}
 
-`, req.Taste, req.Mood, req.Weather, req.Budget)
+`, req.Taste, req.Mood, req.Weather, req.Budget, req.Distance)
 
 	for _, d := range dishes {
-		prompt += fmt.Sprintf("菜品: %s｜价格: %.1f｜口味: %s｜描述: %s\n",
-			d.Name, d.Price, d.Taste, d.Description)
+		line := fmt.Sprintf("ID: %d｜菜品: %s｜价格: %.1f｜口味: %s｜描述: %s",
+			d.ID, d.Name, d.Price, d.Taste, d.Description)
+		if d.DistanceKm != nil {
+			line += fmt.Sprintf("｜距离: %.1f公里", *d.DistanceKm)
+		}
+		prompt += line + "\n"
 	}
 	return prompt
 }
 
-// 调用 DeepSeek API 推荐菜品
-func callDeepSeek(apiKey string, prompt string, dishes []Dish) (Dish, string, error) {
-	type DeepSeekRequest struct {
-		Model    string `json:"model"`
-		Messages []struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"messages"`
-	}
-
-	type DeepSeekResponse struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	reqBody := DeepSeekRequest{
-		Model: "deepseek-chat",
-		Messages: []struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		}{
-			{Role: "user", Content: prompt},
-		},
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return Dish{}, "", err
-	}
-
-	client := &http.Client{Timeout: 20 * time.Second}
-	req, err := http.NewRequest("POST", "https://api.deepseek.com/v1/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return Dish{}, "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("❌ 请求 DeepSeek 失败:", err)
-		return Dish{}, "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(resp.Body)
-		fmt.Println("❌ DeepSeek 响应状态码:", resp.StatusCode)
-		fmt.Println("❌ DeepSeek 响应内容:", buf.String())
-		return Dish{}, "", fmt.Errorf("DeepSeek 接口返回错误状态 %d", resp.StatusCode)
-	}
+// aiTimeout 定制推荐等待 AI 回复的最长时间
+const aiTimeout = 20 * time.Second
 
-	var dsResp DeepSeekResponse
-	if err := json.NewDecoder(resp.Body).Decode(&dsResp); err != nil {
-		fmt.Println("❌ DeepSeek 响应解析失败:", err)
-		return Dish{}, "", err
-	}
-	if len(dsResp.Choices) == 0 {
-		return Dish{}, "", errors.New("无推荐结果")
-	}
+// correctivePrompt AI 输出无法使用时，带上原因让它重新回答一次
+const correctivePrompt = `你的上一次回答无法使用：%s。
+请重新回答，只输出一个 JSON 对象，不要输出其它内容：
+{"dish_id": <候选菜品的ID>, "name": "<菜名>", "reason": "<推荐理由>", "confidence": <0到1之间的数字>}
+dish_id 和 name 必须来自上面的候选菜品列表。`
 
-	reply := dsResp.Choices[0].Message.Content
-	fmt.Println("🤖 AI 回复:", reply)
+// 调用 AI 推荐菜品，输出无法解析、找不到菜品或超出预算时自动纠正重试一次
+func askAI(ctx context.Context, provider llm.Provider, prompt string, req CustomRequest, dishes []Dish) (Dish, aiPick, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiTimeout)
+	defer cancel()
 
-	lines := strings.Split(reply, "\n")
-	var name, reason string
-	for _, line := range lines {
-		if strings.HasPrefix(line, "推荐：") {
-			name = strings.TrimSpace(strings.TrimPrefix(line, "推荐："))
-		} else if strings.HasPrefix(line, "理由：") {
-			reason = strings.TrimSpace(strings.TrimPrefix(line, "理由："))
+	msgs := []llm.Message{{Role: llm.RoleUser, Content: prompt}}
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		resp, err := provider.Complete(ctx, llm.Request{Messages: msgs, JSONMode: true})
+		if err != nil {
+			fmt.Println("❌ 请求 AI 失败:", err)
+			return Dish{}, aiPick{}, err
 		}
-	}
-
-	if name == "" {
-		return Dish{}, "", errors.New("AI输出中未提取到推荐菜名")
-	}
+		fmt.Println("🤖 AI 回复:", resp.Content)
 
-	for _, d := range dishes {
-		if d.Name == name {
-			return d, reason, nil
+		dish, pick, err := parsePick(resp.Content, dishes)
+		if err == nil && overBudget(req, dish) {
+			err = fmt.Errorf("%s 的价格 %.1f 元超出了 %d 元的预算", dish.Name, dish.Price, req.Budget)
+		}
+		if err == nil {
+			return dish, pick, nil
 		}
+		fmt.Println("⚠️ AI 输出无法使用:", err)
+		lastErr = err
+		msgs = append(msgs,
+			llm.Message{Role: llm.RoleAssistant, Content: resp.Content},
+			llm.Message{Role: llm.RoleUser, Content: fmt.Sprintf(correctivePrompt, err)},
+		)
 	}
-
-	return Dish{}, "", fmt.Errorf("未在数据库中找到推荐菜品：%s", name)
+	return Dish{}, aiPick{}, lastErr
 }
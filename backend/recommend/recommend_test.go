@@ -0,0 +1,99 @@
+package recommend
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/llm"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func mockDishRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "name", "price", "description", "taste", "score", "image_url", "created_at",
+	}).AddRow(1, "鱼香肉丝", 28.0, "经典川菜", "咸鲜微辣", 4.7, "http://img.com/1.jpg", "2024-01-01").
+		AddRow(2, "清蒸鲈鱼", 58.0, "清淡鲜美", "清淡", 4.8, "http://img.com/2.jpg", "2024-01-01")
+}
+
+func TestCustomDishHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	provider := &llm.Fake{Replies: []string{"推荐：清蒸鲈鱼\n理由：天气热，吃点清淡的"}}
+	r := gin.New()
+	r.POST("/custom", CustomDishHandler(provider, db))
+
+	// 1. 正常推荐，按预算过滤菜品
+	mock.ExpectQuery(`SELECT id, name, price, description, taste, score, image_url, created_at FROM dishes WHERE price <= \?`).
+		WithArgs(60).
+		WillReturnRows(mockDishRows())
+
+	w := httptest.NewRecorder()
+	body := bytes.NewBufferString(`{"user_id":1,"taste":"清淡","budget":60,"mood":"开心","weather":"晴"}`)
+	req, _ := http.NewRequest("POST", "/custom", body)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("清蒸鲈鱼")) ||
+		!bytes.Contains(w.Body.Bytes(), []byte(`"engine":"ai"`)) {
+		t.Errorf("正常推荐失败，返回: %s", w.Body.String())
+	}
+
+	// 2. AI 调用失败，改用规则推荐
+	provider.Err = errors.New("timeout")
+	mock.ExpectQuery("SELECT id, name, price, description, taste, score, image_url, created_at").
+		WillReturnRows(mockDishRows())
+
+	w = httptest.NewRecorder()
+	body = bytes.NewBufferString(`{"user_id":1,"taste":"清淡"}`)
+	req, _ = http.NewRequest("POST", "/custom", body)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"engine":"rule"`)) ||
+		!bytes.Contains(w.Body.Bytes(), []byte("清蒸鲈鱼")) {
+		t.Errorf("AI 调用失败未改用规则推荐，返回: %s", w.Body.String())
+	}
+}
+
+func TestCustomDishHandler_RetryWithCorrection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	provider := &llm.Fake{Replies: []string{
+		"我觉得吃点鱼不错",
+		`{"dish_id":2,"name":"清蒸鲈鱼","reason":"清淡鲜美","confidence":0.9}`,
+	}}
+	r := gin.New()
+	r.POST("/custom", CustomDishHandler(provider, db))
+
+	mock.ExpectQuery("SELECT id, name, price, description, taste, score, image_url, created_at").
+		WillReturnRows(mockDishRows())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/custom", bytes.NewBufferString(`{"user_id":1,"taste":"清淡"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("清蒸鲈鱼")) {
+		t.Errorf("纠正重试失败，返回: %s", w.Body.String())
+	}
+
+	// 第二次请求带上了上一次的回答和纠正提示
+	if len(provider.Requests) != 2 || len(provider.Requests[1].Messages) != 3 {
+		t.Fatalf("期望纠正重试一次，实际请求: %+v", provider.Requests)
+	}
+	if !provider.Requests[0].JSONMode {
+		t.Errorf("期望以 JSON 模式请求 AI")
+	}
+}
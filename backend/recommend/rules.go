@@ -0,0 +1,174 @@
+package recommend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 推荐结果来源
+const (
+	EngineAI   = "ai"   // 大模型推荐
+	EngineRule = "rule" // 本地规则打分推荐，AI 不可用或未启用时使用
+)
+
+// 心情对应的偏好关键词，按子串匹配用户填写的心情
+var moodKeywords = map[string][]string{
+	"开心": {"甜", "烤", "火锅", "香"},
+	"高兴": {"甜", "烤", "火锅", "香"},
+	"难过": {"甜", "汤", "暖", "软糯"},
+	"伤心": {"甜", "汤", "暖", "软糯"},
+	"低落": {"甜", "汤", "暖", "软糯"},
+	"累":  {"汤", "清淡", "补", "炖"},
+	"疲惫": {"汤", "清淡", "补", "炖"},
+	"压力": {"辣", "麻辣", "爽", "香"},
+	"烦躁": {"辣", "麻辣", "爽", "酸"},
+	"平静": {"清淡", "鲜", "蒸"},
+}
+
+// 天气对应的偏好关键词
+var weatherKeywords = map[string][]string{
+	"晴": {"凉", "爽", "酸", "冰"},
+	"热": {"凉", "爽", "酸", "冰"},
+	"雨": {"汤", "热", "辣", "暖"},
+	"冷": {"火锅", "汤", "辣", "炖"},
+	"雪": {"火锅", "汤", "辣", "炖"},
+	"阴": {"鲜", "香", "汤"},
+}
+
+// 各项打分的权重
+const (
+	tasteWeight   = 3.0
+	budgetWeight  = 2.0
+	overBudgetCut = 5.0 // 超出预算的惩罚，按超出比例计算
+	moodWeight    = 1.0
+	weatherWeight = 1.0
+	scoreWeight   = 2.0 // 菜品评分（满分 5 分）
+	distWeight    = 2.0 // 距离越近得分越高
+)
+
+// defaultDistanceKm 用户没有填写距离偏好时，用于换算距离得分的参考距离
+const defaultDistanceKm = 5.0
+
+// splitTags 把 "辣,清淡 / 酸甜" 之类的输入拆成关键词
+func splitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(" ,，、/|；;", r)
+	})
+}
+
+// keywordHits 统计关键词在菜品口味和描述中命中的个数
+func keywordHits(d Dish, keywords []string) int {
+	text := d.Taste + " " + d.Name + " " + d.Description
+	hits := 0
+	for _, k := range keywords {
+		if k != "" && strings.Contains(text, k) {
+			hits++
+		}
+	}
+	return hits
+}
+
+// lookupKeywords 收集 key 命中的所有关键词表
+func lookupKeywords(table map[string][]string, key string) []string {
+	var keywords []string
+	for k, words := range table {
+		if key != "" && strings.Contains(key, k) {
+			keywords = append(keywords, words...)
+		}
+	}
+	return keywords
+}
+
+// scoreDish 按定制推荐条件给菜品打分，同时返回可以展示给用户的理由
+func scoreDish(req CustomRequest, d Dish) (float64, []string) {
+	var score float64
+	var reasons []string
+
+	// 口味匹配
+	if tags := splitTags(req.Taste); len(tags) > 0 {
+		hits := keywordHits(d, tags)
+		score += tasteWeight * float64(hits) / float64(len(tags))
+		if hits > 0 {
+			reasons = append(reasons, fmt.Sprintf("符合你想吃%s的口味", req.Taste))
+		}
+	}
+
+	// 预算：预算内加分，超出按比例扣分
+	if req.Budget > 0 {
+		budget := float64(req.Budget)
+		if d.Price <= budget {
+			score += budgetWeight
+			reasons = append(reasons, "价格在预算内")
+		} else {
+			score -= overBudgetCut * (d.Price - budget) / budget
+		}
+	}
+
+	// 心情、天气
+	if hits := keywordHits(d, lookupKeywords(moodKeywords, req.Mood)); hits > 0 {
+		score += moodWeight * float64(min(hits, 2))
+		reasons = append(reasons, fmt.Sprintf("适合%s的心情", req.Mood))
+	}
+	if hits := keywordHits(d, lookupKeywords(weatherKeywords, req.Weather)); hits > 0 {
+		score += weatherWeight * float64(min(hits, 2))
+		reasons = append(reasons, fmt.Sprintf("适合%s天", strings.TrimSuffix(req.Weather, "天")))
+	}
+
+	// 距离
+	if d.DistanceKm != nil {
+		scale := parseDistance(req.Distance)
+		if scale <= 0 {
+			scale = defaultDistanceKm
+		}
+		score += distWeight * max(0, 1-*d.DistanceKm/scale)
+		reasons = append(reasons, fmt.Sprintf("距离你约%.1f公里", *d.DistanceKm))
+	}
+
+	// 菜品本身的评分
+	score += scoreWeight * d.Score / 5
+	return score, reasons
+}
+
+// rankDishes 按规则得分从高到低排序，得分相同时按菜品评分、ID 排序，保证结果确定
+func rankDishes(req CustomRequest, dishes []Dish) []Dish {
+	type scored struct {
+		dish  Dish
+		score float64
+	}
+	list := make([]scored, 0, len(dishes))
+	for _, d := range dishes {
+		s, _ := scoreDish(req, d)
+		list = append(list, scored{d, s})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].score != list[j].score {
+			return list[i].score > list[j].score
+		}
+		if list[i].dish.Score != list[j].dish.Score {
+			return list[i].dish.Score > list[j].dish.Score
+		}
+		return list[i].dish.ID < list[j].dish.ID
+	})
+
+	ranked := make([]Dish, len(list))
+	for i, s := range list {
+		ranked[i] = s.dish
+	}
+	return ranked
+}
+
+// ruleRecommend 用本地规则选出得分最高的菜品，dishes 为空时返回 false
+func ruleRecommend(req CustomRequest, dishes []Dish) (Dish, aiPick, bool) {
+	if len(dishes) == 0 {
+		return Dish{}, aiPick{}, false
+	}
+	best := rankDishes(req, dishes)[0]
+	_, reasons := scoreDish(req, best)
+
+	reason := "评分高，口碑不错"
+	if len(reasons) > 0 {
+		reason = strings.Join(reasons, "，")
+	}
+	return best, aiPick{DishID: best.ID, Name: best.Name, Reason: reason}, true
+}
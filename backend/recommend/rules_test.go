@@ -0,0 +1,34 @@
+package recommend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleRecommend(t *testing.T) {
+	dishes := []Dish{
+		{ID: 1, Name: "水煮鱼", Price: 68, Taste: "麻辣", Description: "麻辣鲜香", Score: 4.9},
+		{ID: 2, Name: "番茄蛋汤", Price: 15, Taste: "清淡", Description: "酸甜暖胃的家常汤", Score: 4.2},
+		{ID: 3, Name: "凉拌黄瓜", Price: 12, Taste: "清淡", Description: "爽口凉菜", Score: 4.0},
+	}
+
+	// 预算和口味优先：水煮鱼评分最高但超预算
+	dish, pick, ok := ruleRecommend(CustomRequest{Taste: "清淡", Budget: 30, Weather: "下雨"}, dishes)
+	assert.True(t, ok)
+	assert.Equal(t, 2, dish.ID)
+	assert.Contains(t, pick.Reason, "预算内")
+	assert.Contains(t, pick.Reason, "下雨")
+
+	// 天气炎热时偏向凉菜
+	dish, _, _ = ruleRecommend(CustomRequest{Taste: "清淡", Budget: 30, Weather: "炎热"}, dishes)
+	assert.Equal(t, 3, dish.ID)
+
+	// 没有条件时按评分
+	dish, pick, _ = ruleRecommend(CustomRequest{}, dishes)
+	assert.Equal(t, 1, dish.ID)
+	assert.NotEmpty(t, pick.Reason)
+
+	_, _, ok = ruleRecommend(CustomRequest{}, nil)
+	assert.False(t, ok)
+}
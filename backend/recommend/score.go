@@ -0,0 +1,205 @@
+package recommend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// 贝叶斯平均参数：score = (priorWeight * prior + 评分总和) / (priorWeight + 评分人数)。
+// 先验相当于 priorWeight 个虚拟评分，评分人数少时个别用户无法大幅拉高或拉低新菜品的评分
+const (
+	priorWeight       = 5.0
+	defaultPriorScore = 3.5 // 没有 base_score 且还没有任何评分时使用的先验
+)
+
+// bayesianScore 计算贝叶斯平均评分，保留两位小数
+func bayesianScore(prior float64, count int, sum float64) float64 {
+	score := (priorWeight*prior + sum) / (priorWeight + float64(count))
+	return math.Round(score*100) / 100
+}
+
+// globalMeanRating 所有评分的平均值，作为没有 base_score 的菜品的先验
+func globalMeanRating(ctx context.Context, db *sql.DB) (float64, error) {
+	var mean sql.NullFloat64
+	if err := db.QueryRowContext(ctx, "SELECT AVG(score) FROM dish_ratings").Scan(&mean); err != nil {
+		return 0, err
+	}
+	if !mean.Valid {
+		return defaultPriorScore, nil
+	}
+	return mean.Float64, nil
+}
+
+// UpdateDishScore 根据用户评分重新计算一道菜的评分和评分人数，在评分新增或修改后调用
+func UpdateDishScore(ctx context.Context, db *sql.DB, dishID int) error {
+	var base sql.NullFloat64
+	if err := db.QueryRowContext(ctx, "SELECT base_score FROM dishes WHERE id = ?", dishID).Scan(&base); err != nil {
+		return err
+	}
+	prior := base.Float64
+	if !base.Valid {
+		mean, err := globalMeanRating(ctx, db)
+		if err != nil {
+			return err
+		}
+		prior = mean
+	}
+
+	var count int
+	var sum float64
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(SUM(score), 0) FROM dish_ratings WHERE dish_id = ?", dishID).Scan(&count, &sum)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "UPDATE dishes SET score = ?, rating_count = ? WHERE id = ?",
+		bayesianScore(prior, count, sum), count, dishID)
+	return err
+}
+
+// RecomputeDishScores 批量重新计算所有菜品的评分，返回更新的菜品数
+func RecomputeDishScores(ctx context.Context, db *sql.DB) (int, error) {
+	mean, err := globalMeanRating(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	type aggregate struct {
+		count int
+		sum   float64
+	}
+	stats := map[int]aggregate{}
+	rows, err := db.QueryContext(ctx, "SELECT dish_id, COUNT(*), SUM(score) FROM dish_ratings GROUP BY dish_id")
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var id int
+		var a aggregate
+		if err := rows.Scan(&id, &a.count, &a.sum); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		stats[id] = a
+	}
+	rows.Close()
+
+	type update struct {
+		id    int
+		score float64
+		count int
+	}
+	var updates []update
+	rows, err = db.QueryContext(ctx, "SELECT id, base_score FROM dishes")
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var id int
+		var base sql.NullFloat64
+		if err := rows.Scan(&id, &base); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		prior := mean
+		if base.Valid {
+			prior = base.Float64
+		}
+		a := stats[id]
+		updates = append(updates, update{id, bayesianScore(prior, a.count, a.sum), a.count})
+	}
+	rows.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, "UPDATE dishes SET score = ?, rating_count = ? WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, u := range updates {
+		if _, err := stmt.ExecContext(ctx, u.score, u.count, u.id); err != nil {
+			return 0, fmt.Errorf("更新菜品 %d 评分失败: %w", u.id, err)
+		}
+	}
+	return len(updates), tx.Commit()
+}
+
+// ScoreUpdater 定期批量重新计算所有菜品的评分。评分在写入时已经实时更新，
+// 这里兜底修正实时更新失败或直接改库造成的偏差
+type ScoreUpdater struct {
+	db       *sql.DB
+	interval time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewScoreUpdater 创建评分计算任务，interval 为重新计算的间隔
+func NewScoreUpdater(db *sql.DB, interval time.Duration) *ScoreUpdater {
+	return &ScoreUpdater{db: db, interval: interval, stop: make(chan struct{})}
+}
+
+// Start 在后台立即计算一次，之后每隔 interval 重新计算
+func (u *ScoreUpdater) Start() {
+	go func() {
+		ticker := time.NewTicker(u.interval)
+		defer ticker.Stop()
+		for {
+			u.run()
+			select {
+			case <-ticker.C:
+			case <-u.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定期计算
+func (u *ScoreUpdater) Stop() {
+	u.stopOnce.Do(func() { close(u.stop) })
+}
+
+func (u *ScoreUpdater) run() {
+	n, err := RecomputeDishScores(context.Background(), u.db)
+	if err != nil {
+		log.Printf("⚠️ 批量计算菜品评分失败: %v", err)
+		return
+	}
+	log.Printf("菜品评分计算完成，更新 %d 道菜", n)
+}
+
+// ratingDistribution 菜品各星级的评分人数，下标 0 为 1 星；半星向上取整
+func ratingDistribution(db *sql.DB, dishID int) ([5]int, error) {
+	var dist [5]int
+	rows, err := db.Query(`
+		SELECT CEIL(score) AS star, COUNT(*)
+		FROM dish_ratings
+		WHERE dish_id = ?
+		GROUP BY star
+	`, dishID)
+	if err != nil {
+		return dist, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var star, n int
+		if err := rows.Scan(&star, &n); err != nil {
+			return dist, err
+		}
+		if star >= 1 && star <= 5 {
+			dist[star-1] += n
+		}
+	}
+	return dist, rows.Err()
+}
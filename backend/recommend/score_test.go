@@ -0,0 +1,75 @@
+package recommend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBayesianScore(t *testing.T) {
+	// 没有评分时等于先验
+	assert.Equal(t, 4.2, bayesianScore(4.2, 0, 0))
+	// 单个用户打 1 分不会让新菜品评分骤降
+	assert.Equal(t, 3.67, bayesianScore(4.2, 1, 1))
+	// 评分人数多时接近真实平均分
+	assert.InDelta(t, 1.0, bayesianScore(4.2, 1000, 1000), 0.05)
+}
+
+func TestUpdateDishScore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	// 1. 有 base_score 时作为先验
+	mock.ExpectQuery(`SELECT base_score FROM dishes WHERE id = \?`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"base_score"}).AddRow(4.0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\), COALESCE\(SUM\(score\), 0\) FROM dish_ratings WHERE dish_id = \?`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(5, 15.0))
+	mock.ExpectExec(`UPDATE dishes SET score = \?, rating_count = \? WHERE id = \?`).
+		WithArgs(3.5, 5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, UpdateDishScore(context.Background(), db, 1))
+
+	// 2. 没有 base_score 时使用全部评分的平均值
+	mock.ExpectQuery(`SELECT base_score FROM dishes WHERE id = \?`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"base_score"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT AVG\(score\) FROM dish_ratings`).
+		WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(4.0))
+	mock.ExpectQuery(`FROM dish_ratings WHERE dish_id = \?`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(1, 5.0))
+	mock.ExpectExec(`UPDATE dishes SET score = \?, rating_count = \? WHERE id = \?`).
+		WithArgs(4.17, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, UpdateDishScore(context.Background(), db, 2))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecomputeDishScores(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT AVG\(score\) FROM dish_ratings`).
+		WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT dish_id, COUNT\(\*\), SUM\(score\) FROM dish_ratings GROUP BY dish_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id", "count", "sum"}).AddRow(1, 5, 25.0))
+	mock.ExpectQuery(`SELECT id, base_score FROM dishes`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "base_score"}).AddRow(1, 4.0).AddRow(2, nil))
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(`UPDATE dishes SET score = \?, rating_count = \? WHERE id = \?`)
+	prep.ExpectExec().WithArgs(4.5, 5, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WithArgs(defaultPriorScore, 0, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := RecomputeDishScores(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
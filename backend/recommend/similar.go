@@ -0,0 +1,271 @@
+package recommend
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 相似菜品各项指标的权重，合计为 1
+const (
+	simTasteWeight  = 0.35 // 口味
+	simPriceWeight  = 0.2  // 价位
+	simTextWeight   = 0.25 // 菜名和描述
+	simCoLikeWeight = 0.2  // 被同一批用户喜欢
+)
+
+// 相似菜品接口的默认值和上限
+const (
+	defaultSimilarLimit  = 6
+	maxSimilarLimit      = 20
+	minSimilarity        = 0.05 // 低于该相似度的菜品不返回
+	maxSimilarCandidates = 200  // 每次参与计算的候选菜品上限
+)
+
+// priceBands 价位分档的上界（元），超出最后一档的归为最高档
+var priceBands = []float64{20, 40, 80, 150}
+
+// SimilarDish 相似菜品及相似原因
+type SimilarDish struct {
+	Dish
+	Similarity float64  `json:"similarity"`
+	Reasons    []string `json:"reasons"`
+}
+
+// priceBand 价格所在的档位
+func priceBand(price float64) int {
+	for i, upper := range priceBands {
+		if price < upper {
+			return i
+		}
+	}
+	return len(priceBands)
+}
+
+// priceSimilarity 同一价位为 1，相邻价位为 0.5
+func priceSimilarity(a, b float64) float64 {
+	switch d := priceBand(a) - priceBand(b); {
+	case d == 0:
+		return 1
+	case d == 1 || d == -1:
+		return 0.5
+	}
+	return 0
+}
+
+// bigrams 按相邻两个字切分文本，忽略空白和标点；只有一个字时以单字作为特征
+func bigrams(s string) map[string]int {
+	var runes []rune
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, unicode.ToLower(r))
+		}
+	}
+	grams := map[string]int{}
+	if len(runes) == 1 {
+		grams[string(runes)]++
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		grams[string(runes[i:i+2])]++
+	}
+	return grams
+}
+
+// cosine 两个词频向量的余弦相似度
+func cosine(a, b map[string]int) float64 {
+	var dot, na, nb float64
+	for k, v := range a {
+		na += float64(v * v)
+		dot += float64(v * b[k])
+	}
+	for _, v := range b {
+		nb += float64(v * v)
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// dishText 参与文本相似度计算的内容
+func dishText(d Dish) string {
+	return d.Name + " " + d.Description
+}
+
+// adjacentPriceRange 与 price 相同或相邻价位的价格区间 [lo, hi)，hi 为 0 表示没有上限
+func adjacentPriceRange(price float64) (lo, hi float64) {
+	band := priceBand(price)
+	if band >= 2 {
+		lo = priceBands[band-2]
+	}
+	if band+1 < len(priceBands) {
+		hi = priceBands[band+1]
+	}
+	return lo, hi
+}
+
+// scoreSimilar 计算 d 与 target 的综合相似度，同时返回主要的相似原因；
+// co 为协同过滤训练得到的两道菜的相似度，没有时为 0
+func scoreSimilar(target, d Dish, co float64) (float64, []string) {
+	taste := tasteSimilarity(target.Taste, d.Taste)
+	price := priceSimilarity(target.Price, d.Price)
+	text := cosine(bigrams(dishText(target)), bigrams(dishText(d)))
+
+	var reasons []string
+	if taste >= 0.5 {
+		reasons = append(reasons, "口味相近")
+	}
+	if price == 1 {
+		reasons = append(reasons, "价位相近")
+	}
+	if text >= 0.2 {
+		reasons = append(reasons, "做法或食材相似")
+	}
+	if co > 0 {
+		reasons = append(reasons, "喜欢它的人也喜欢")
+	}
+	return simTasteWeight*taste + simPriceWeight*price + simTextWeight*text + simCoLikeWeight*co, reasons
+}
+
+// loadSimilarCandidates 查询可能与 target 相似的菜品：口味有相同的字、价位相同或相邻，
+// 或者在协同过滤结果中与它相似。其余菜品最多只有菜名和描述相似，不参与计算
+func loadSimilarCandidates(db *sql.DB, target Dish, neighborIDs []int) ([]Dish, error) {
+	var conds []string
+	args := []interface{}{target.ID}
+
+	lo, hi := adjacentPriceRange(target.Price)
+	if hi > 0 {
+		conds = append(conds, "(price >= ? AND price < ?)")
+		args = append(args, lo, hi)
+	} else {
+		conds = append(conds, "price >= ?")
+		args = append(args, lo)
+	}
+
+	var runes []rune
+	for r := range tasteRunes(target.Taste) {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	for _, r := range runes {
+		conds = append(conds, "taste LIKE ?")
+		args = append(args, "%"+string(r)+"%")
+	}
+
+	if len(neighborIDs) > 0 {
+		conds = append(conds, "id IN ("+placeholders(len(neighborIDs))+")")
+		for _, id := range neighborIDs {
+			args = append(args, id)
+		}
+	}
+	args = append(args, maxSimilarCandidates)
+
+	rows, err := db.Query(
+		"SELECT id, name, price, description, taste, score, image_url, created_at FROM dishes WHERE id <> ? AND ("+
+			strings.Join(conds, " OR ")+") ORDER BY score DESC LIMIT ?",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dishes []Dish
+	for rows.Next() {
+		var d Dish
+		if err := rows.Scan(&d.ID, &d.Name, &d.Price, &d.Description, &d.Taste, &d.Score, &d.ImageURL, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		dishes = append(dishes, d)
+	}
+	return dishes, rows.Err()
+}
+
+// findSimilarDishes 查询与 dishID 最相似的菜品，菜品不存在时返回 sql.ErrNoRows
+func findSimilarDishes(db *sql.DB, dishID, limit int) ([]SimilarDish, error) {
+	var target Dish
+	err := db.QueryRow(
+		"SELECT id, name, price, description, taste, score, image_url, created_at FROM dishes WHERE id = ?", dishID,
+	).Scan(&target.ID, &target.Name, &target.Price, &target.Description, &target.Taste, &target.Score, &target.ImageURL, &target.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return similarDishes(db, target, limit)
+}
+
+// similarDishes 查询与 target 最相似的菜品
+func similarDishes(db *sql.DB, target Dish, limit int) ([]SimilarDish, error) {
+	// 被同一批用户喜欢的程度直接使用协同过滤的训练结果，查询失败时按没有数据计算
+	co := map[int]float64{}
+	sims, err := loadNeighbors(db, []int{target.ID})
+	if err != nil {
+		fmt.Println("查询相似菜品失败:", err)
+	}
+	neighborIDs := make([]int, 0, len(sims[target.ID]))
+	for _, n := range sims[target.ID] {
+		co[n.DishID] = n.Score
+		neighborIDs = append(neighborIDs, n.DishID)
+	}
+
+	candidates, err := loadSimilarCandidates(db, target, neighborIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []SimilarDish{}
+	for _, d := range candidates {
+		score, reasons := scoreSimilar(target, d, co[d.ID])
+		if score < minSimilarity {
+			continue
+		}
+		result = append(result, SimilarDish{Dish: d, Similarity: score, Reasons: reasons})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Similarity != result[j].Similarity {
+			return result[i].Similarity > result[j].Similarity
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// GetSimilarDishesHandler 获取相似菜品，用于菜品详情页的"相似推荐"
+func GetSimilarDishesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dishIDStr := c.Query("id")
+		if dishIDStr == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "缺少菜品 ID"})
+			return
+		}
+		dishID, err := strconv.Atoi(dishIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 2, "message": "菜品 ID 无效"})
+			return
+		}
+		limit := defaultSimilarLimit
+		if s := c.Query("limit"); s != "" {
+			if v, err := strconv.Atoi(s); err == nil && v > 0 {
+				limit = min(v, maxSimilarLimit)
+			}
+		}
+
+		similar, err := findSimilarDishes(db, dishID, limit)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"code": 3, "message": "菜品不存在"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 4, "message": "数据库查询失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "dishes": similar})
+	}
+}
@@ -0,0 +1,139 @@
+package recommend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceSimilarity(t *testing.T) {
+	assert.Equal(t, 0, priceBand(15))
+	assert.Equal(t, 2, priceBand(58))
+	assert.Equal(t, len(priceBands), priceBand(300))
+	assert.Equal(t, 1.0, priceSimilarity(45, 60))
+	assert.Equal(t, 0.5, priceSimilarity(35, 45))
+	assert.Equal(t, 0.0, priceSimilarity(10, 200))
+}
+
+func TestTextSimilarity(t *testing.T) {
+	assert.InDelta(t, 1, cosine(bigrams("红烧肉"), bigrams("红烧 肉！")), 1e-9)
+	assert.Greater(t, cosine(bigrams("红烧排骨"), bigrams("红烧肉")), 0.0)
+	assert.Equal(t, 0.0, cosine(bigrams("清蒸鲈鱼"), bigrams("麻婆豆腐")))
+	assert.Equal(t, 0.0, cosine(bigrams(""), bigrams("麻婆豆腐")))
+	assert.Equal(t, map[string]int{"鱼": 1}, bigrams("鱼"))
+}
+
+// mockSimilarQueries 菜品 1（水煮鱼，48 元，麻辣）的相似菜品查询：协同过滤结果中与菜品 4 相似，
+// 候选菜品只查询价位相同或相邻、口味有相同的字或在协同过滤结果中的菜品
+func mockSimilarQueries(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT dish_id, similar_dish_id, score, co_count FROM dish_similarity`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"dish_id", "similar_dish_id", "score", "co_count"}).AddRow(1, 4, 0.8, 2))
+	mock.ExpectQuery(`FROM dishes WHERE id <> \? AND \(\(price >= \? AND price < \?\) OR taste LIKE \? OR taste LIKE \? OR id IN \(\?\)\) ORDER BY score DESC LIMIT \?`).
+		WithArgs(1, 20.0, 150.0, "%辣%", "%麻%", 4, maxSimilarCandidates).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "description", "taste", "score", "image_url", "created_at"}).
+			AddRow(2, "水煮牛肉", 52.0, "麻辣鲜香的水煮牛肉片", "麻辣", 4.5, "", "2024-01-01").
+			AddRow(4, "酸菜鱼", 45.0, "酸辣开胃", "酸辣", 4.4, "", "2024-01-01").
+			AddRow(5, "凉拌木耳", 22.0, "爽口", "酸", 4.0, "", "2024-01-01"))
+}
+
+func TestAdjacentPriceRange(t *testing.T) {
+	lo, hi := adjacentPriceRange(15)
+	assert.Equal(t, []float64{0, 40}, []float64{lo, hi})
+	lo, hi = adjacentPriceRange(58)
+	assert.Equal(t, []float64{20, 150}, []float64{lo, hi})
+	lo, hi = adjacentPriceRange(300)
+	assert.Equal(t, []float64{80, 0}, []float64{lo, hi})
+}
+
+func TestGetSimilarDishesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/similar", GetSimilarDishesHandler(db))
+
+	// 1. 正常查询
+	mock.ExpectQuery(`FROM dishes WHERE id = \?`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "description", "taste", "score", "image_url", "created_at"}).
+			AddRow(1, "水煮鱼", 48.0, "麻辣鲜香的水煮鱼片", "麻辣", 4.6, "", "2024-01-01"))
+	mockSimilarQueries(mock)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/similar?id=1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Dishes []SimilarDish `json:"dishes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Dishes, 3) {
+		assert.Equal(t, 2, resp.Dishes[0].ID)
+		assert.Contains(t, resp.Dishes[0].Reasons, "口味相近")
+		assert.Equal(t, 4, resp.Dishes[1].ID)
+		assert.Contains(t, resp.Dishes[1].Reasons, "喜欢它的人也喜欢")
+		assert.Equal(t, 5, resp.Dishes[2].ID, "相邻价位的菜品排在最后")
+	}
+
+	// 2. 菜品不存在
+	mock.ExpectQuery(`FROM dishes WHERE id = \?`).
+		WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "description", "taste", "score", "image_url", "created_at"}))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/similar?id=99", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 3. 参数错误
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/similar?id=abc", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDishDetailHandler_WithSimilar(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/detail", GetDishDetailHandler(db))
+
+	mock.ExpectQuery(`FROM dishes WHERE id = \?`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "description", "taste", "score", "image_url", "created_at"}).
+			AddRow(1, "水煮鱼", 48.0, "麻辣鲜香的水煮鱼片", "麻辣", 4.6, "", "2024-01-01"))
+	mock.ExpectQuery(`SELECT CEIL\(score\) AS star, COUNT\(\*\)`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"star", "count"}).AddRow(4, 2).AddRow(5, 3))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM reviews WHERE dish_id = \? AND status = \?`).
+		WithArgs(1, "approved").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mockSimilarQueries(mock)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/detail?id=1&with_similar=1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"similar":[`)
+	assert.Contains(t, w.Body.String(), `"rating_distribution":[0,0,0,2,3]`)
+	assert.Contains(t, w.Body.String(), `"rating_count":5`)
+	assert.Contains(t, w.Body.String(), `"reviews":[]`)
+	assert.Contains(t, w.Body.String(), "水煮牛肉")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,129 @@
+package review
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader 管理接口携带访问令牌的请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// RequireAdmin 校验管理令牌的中间件；配置中没有设置令牌时拒绝所有请求
+func RequireAdmin(cfg config.Snapshot) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := cfg().Admin.Token
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": 403, "message": "管理接口未启用"})
+			return
+		}
+		given := c.GetHeader(AdminTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "管理令牌无效"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ListForModerationHandler 按状态分页获取评论，默认返回待审核评论，按提交时间从早到晚
+func ListForModerationHandler(db *sql.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", StatusPending)
+		if status != StatusPending && status != StatusApproved && status != StatusRejected {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "status 无效"})
+			return
+		}
+		page, size, offset := pagination(c)
+
+		var total int
+		if err := db.QueryRow("SELECT COUNT(*) FROM reviews WHERE status = ?", status).Scan(&total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		rows, err := db.Query(`
+			SELECT `+reviewColumns+`
+			FROM reviews r
+			LEFT JOIN users u ON r.user_id = u.id
+			WHERE r.status = ?
+			ORDER BY r.created_at ASC
+			LIMIT ? OFFSET ?
+		`, status, size, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		reviews, err := scanReviews(db, rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": "数据转换失败"})
+			return
+		}
+		signPhotos(store, reviews)
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "total": total, "page": page, "page_size": size, "reviews": reviews})
+	}
+}
+
+// ModerateReviewHandler 审核评论：{"action":"approve"} 或 {"action":"reject","reason":"..."}。
+// 审核未通过的评论同时删除图片
+func ModerateReviewHandler(db *sql.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviewID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || reviewID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "评论 ID 无效"})
+			return
+		}
+		var req struct {
+			Action string `json:"action"`
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		var status, reason string
+		switch req.Action {
+		case "approve":
+			status = StatusApproved
+		case "reject":
+			status = StatusRejected
+			reason = strings.TrimSpace(req.Reason)
+			if reason == "" {
+				reason = "内容不符合社区规范"
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "action 只能是 approve 或 reject"})
+			return
+		}
+
+		res, err := db.Exec("UPDATE reviews SET status = ?, reject_reason = ?, reviewed_at = ? WHERE id = ?",
+			status, reason, time.Now(), reviewID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库操作失败"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"code": 3, "message": "评论不存在"})
+			return
+		}
+		if status == StatusRejected {
+			if err := deletePhotos(c.Request.Context(), db, store, reviewID); err != nil {
+				fmt.Println("删除评论图片失败:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库操作失败"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "审核完成", "status": status})
+	}
+}
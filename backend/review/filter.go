@@ -0,0 +1,87 @@
+package review
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// defaultSensitiveWords 内置敏感词，可以用词库文件替换
+var defaultSensitiveWords = []string{
+	"赌博", "博彩", "六合彩", "毒品", "大麻", "冰毒", "枪支", "代开发票", "办证",
+	"色情", "约炮", "裸聊", "加微信", "加我微信", "刷单", "返现", "兼职日结",
+	"傻逼", "煞笔", "去死", "垃圾店", "骗子店",
+}
+
+// Filter 基于关键词的敏感词过滤器。
+// 匹配前去掉空白、标点和符号并统一大小写，避免用 "赌 博"、"赌*博" 之类的写法绕过
+type Filter struct {
+	words []string
+}
+
+// NewFilter 用给定词表创建过滤器，空词会被忽略
+func NewFilter(words []string) *Filter {
+	f := &Filter{}
+	seen := map[string]bool{}
+	for _, w := range words {
+		w = normalize(w)
+		if w != "" && !seen[w] {
+			seen[w] = true
+			f.words = append(f.words, w)
+		}
+	}
+	return f
+}
+
+// DefaultFilter 使用内置词表的过滤器
+func DefaultFilter() *Filter {
+	return NewFilter(defaultSensitiveWords)
+}
+
+// LoadFilter 从词库文件加载过滤器，每行一个词，# 开头为注释；文件不存在时使用内置词表
+func LoadFilter(path string) (*Filter, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return DefaultFilter(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewFilter(words), nil
+}
+
+// Check 返回文本中命中的敏感词，没有命中时返回 nil
+func (f *Filter) Check(text string) []string {
+	text = normalize(text)
+	var hits []string
+	for _, w := range f.words {
+		if strings.Contains(text, w) {
+			hits = append(hits, w)
+		}
+	}
+	return hits
+}
+
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package review
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_Check(t *testing.T) {
+	f := NewFilter([]string{"赌博", "加微信", "", "赌博", "SPAM"})
+	assert.Empty(t, f.Check("这家的水煮鱼很好吃"))
+	assert.Equal(t, []string{"赌博"}, f.Check("楼下有人赌博"))
+	// 用空格、标点隔开也能识别
+	assert.Equal(t, []string{"赌博"}, f.Check("赌 * 博"))
+	assert.Equal(t, []string{"加微信"}, f.Check("想吃的加 微 信！"))
+	// 忽略大小写
+	assert.Equal(t, []string{"spam"}, f.Check("this is Spam"))
+}
+
+func TestLoadFilter(t *testing.T) {
+	dir := t.TempDir()
+
+	// 文件不存在时使用内置词表
+	f, err := LoadFilter(filepath.Join(dir, "missing.txt"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, f.Check("办证找我"))
+
+	path := filepath.Join(dir, "words.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("# 注释\n难吃\n\n 太贵 \n"), 0o644))
+	f, err = LoadFilter(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"难吃", "太贵"}, f.Check("又难吃又太贵"))
+	assert.Empty(t, f.Check("办证找我"))
+}
@@ -0,0 +1,476 @@
+// Package review 菜品评论：文字和图片、敏感词过滤以及审核
+package review
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"backend/auth"
+	"backend/media"
+	"backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 评论审核状态
+const (
+	StatusPending  = "pending"  // 待审核
+	StatusApproved = "approved" // 审核通过，公开展示
+	StatusRejected = "rejected" // 审核未通过
+)
+
+// 评论限制
+const (
+	maxContentRunes = 500  // 评论最多字数
+	maxPhotos       = 6    // 每条评论最多图片数
+	maxPhotoSide    = 1600 // 图片保存时的最大边长（像素），超过时等比缩小
+)
+
+// photoPrefix 评论图片在存储中的目录
+const photoPrefix = "review/"
+
+// photoURLTTL 未审核通过的评论图片只通过带签名的地址访问，签名的有效期
+const photoURLTTL = time.Hour
+
+// 列表分页参数
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Review 一条评论
+type Review struct {
+	ID           int64    `json:"id"`
+	UserID       int      `json:"user_id"`
+	DishID       int      `json:"dish_id"`
+	Content      string   `json:"content"`
+	Photos       []string `json:"photos"`
+	Status       string   `json:"status"`
+	RejectReason string   `json:"reject_reason,omitempty"`
+	CreatedAt    string   `json:"created_at"`
+	Nickname     string   `json:"nickname,omitempty"`
+	AvatarURL    string   `json:"avatar_url,omitempty"`
+}
+
+// reviewColumns 查询评论时的字段，配合 scanReviews 使用
+const reviewColumns = `r.id, r.user_id, r.dish_id, r.content, r.status, r.reject_reason, r.created_at,
+	COALESCE(u.nickname, ''), COALESCE(u.avatar_url, '')`
+
+// scanReviews 读取评论列表并补上图片
+func scanReviews(db *sql.DB, rows *sql.Rows) ([]Review, error) {
+	defer rows.Close()
+	reviews := []Review{}
+	for rows.Next() {
+		var r Review
+		if err := rows.Scan(&r.ID, &r.UserID, &r.DishID, &r.Content, &r.Status, &r.RejectReason, &r.CreatedAt,
+			&r.Nickname, &r.AvatarURL); err != nil {
+			return nil, err
+		}
+		r.Photos = []string{}
+		reviews = append(reviews, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(reviews) == 0 {
+		return reviews, nil
+	}
+
+	ids := make([]interface{}, len(reviews))
+	index := map[int64]int{}
+	for i, r := range reviews {
+		ids[i] = r.ID
+		index[r.ID] = i
+	}
+	photoRows, err := db.Query(
+		"SELECT review_id, url FROM review_photos WHERE review_id IN ("+placeholders(len(ids))+") ORDER BY review_id, sort_order",
+		ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer photoRows.Close()
+	for photoRows.Next() {
+		var id int64
+		var url string
+		if err := photoRows.Scan(&id, &url); err != nil {
+			return nil, err
+		}
+		if i, ok := index[id]; ok {
+			reviews[i].Photos = append(reviews[i].Photos, url)
+		}
+	}
+	return reviews, photoRows.Err()
+}
+
+// PhotoKey 评论图片 URL 对应的存储路径，用于导出和注销账号时清理
+func PhotoKey(url string) string {
+	return photoPrefix + path.Base(url)
+}
+
+// newPhotoKey 生成随机的图片存储路径，文件名无法猜测，未审核的图片不会被遍历到
+func newPhotoKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return photoPrefix + hex.EncodeToString(buf) + ".jpg", nil
+}
+
+// shrinkPhoto 把边长超过 maxPhotoSide 的图片等比缩小，避免按原尺寸保存大图
+func shrinkPhoto(img image.Image) image.Image {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxPhotoSide && h <= maxPhotoSide {
+		return img
+	}
+	if w >= h {
+		return media.Resize(img, maxPhotoSide, max(h*maxPhotoSide/w, 1))
+	}
+	return media.Resize(img, max(w*maxPhotoSide/h, 1), maxPhotoSide)
+}
+
+// signPhotos 把未审核通过的评论的图片换成带签名的地址，生成失败的图片不返回
+func signPhotos(store storage.Storage, reviews []Review) {
+	for i, r := range reviews {
+		if r.Status == StatusApproved {
+			continue
+		}
+		signed := []string{}
+		for _, url := range r.Photos {
+			u, err := store.SignedURL(PhotoKey(url), photoURLTTL)
+			if err != nil {
+				fmt.Println("生成评论图片地址失败:", err)
+				continue
+			}
+			signed = append(signed, u)
+		}
+		reviews[i].Photos = signed
+	}
+}
+
+// deletePhotos 删除评论的图片记录和文件，用于审核未通过的评论。
+// 文件删除失败只记录日志，记录已经删除，不会再返回给客户端
+func deletePhotos(ctx context.Context, db *sql.DB, store storage.Storage, reviewID int64) error {
+	rows, err := db.QueryContext(ctx, "SELECT url FROM review_photos WHERE review_id = ?", reviewID)
+	if err != nil {
+		return err
+	}
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			rows.Close()
+			return err
+		}
+		urls = append(urls, url)
+	}
+	rows.Close()
+	if len(urls) == 0 {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM review_photos WHERE review_id = ?", reviewID); err != nil {
+		return err
+	}
+	for _, url := range urls {
+		if err := store.Delete(ctx, PhotoKey(url)); err != nil {
+			fmt.Println("删除评论图片失败:", err)
+		}
+	}
+	return nil
+}
+
+// placeholders 生成 n 个以逗号分隔的 ?，用于 IN 查询
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// pagination 读取 page、page_size 参数，返回页码、每页数量和偏移量
+func pagination(c *gin.Context) (int, int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	size, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || size < 1 {
+		size = defaultPageSize
+	}
+	size = min(size, maxPageSize)
+	return page, size, (page - 1) * size
+}
+
+// ApprovedReviews 菜品最新的已审核评论及已审核评论总数，用于菜品详情
+func ApprovedReviews(db *sql.DB, dishID, limit int) ([]Review, int, error) {
+	var total int
+	err := db.QueryRow("SELECT COUNT(*) FROM reviews WHERE dish_id = ? AND status = ?", dishID, StatusApproved).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []Review{}, 0, nil
+	}
+	rows, err := db.Query(`
+		SELECT `+reviewColumns+`
+		FROM reviews r
+		LEFT JOIN users u ON r.user_id = u.id
+		WHERE r.dish_id = ? AND r.status = ?
+		ORDER BY r.created_at DESC
+		LIMIT ?
+	`, dishID, StatusApproved, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	reviews, err := scanReviews(db, rows)
+	return reviews, total, err
+}
+
+// CreateReviewHandler 发表评论（multipart 表单：dish_id、content，以及最多 6 张 photos；评论者为当前登录用户）。
+// 含敏感词的评论直接拒绝，其余进入待审核状态；审核通过前返回的图片地址都带签名
+func CreateReviewHandler(db *sql.DB, store storage.Storage, filter *Filter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dishID, err := strconv.Atoi(c.PostForm("dish_id"))
+		if err != nil || dishID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "dish_id 无效"})
+			return
+		}
+		claimed, _ := strconv.Atoi(c.PostForm("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
+
+		content := strings.TrimSpace(c.PostForm("content"))
+		if content == "" || utf8.RuneCountInString(content) > maxContentRunes {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 2, "message": fmt.Sprintf("评论内容不能为空且不超过 %d 字", maxContentRunes)})
+			return
+		}
+		if hits := filter.Check(content); len(hits) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 3, "message": "评论包含敏感词，请修改后再提交"})
+			return
+		}
+
+		// 先解码全部图片，有一张不合格就不保存
+		var photos []image.Image
+		if form, err := c.MultipartForm(); err == nil {
+			files := form.File["photos"]
+			if len(files) > maxPhotos {
+				c.JSON(http.StatusBadRequest, gin.H{"code": 4, "message": fmt.Sprintf("最多上传 %d 张图片", maxPhotos)})
+				return
+			}
+			for _, fh := range files {
+				img, err := media.DecodeUpload(fh)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"code": 4, "message": err.Error()})
+					return
+				}
+				photos = append(photos, img)
+			}
+		}
+
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库查询失败"})
+			return
+		} else if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"code": 6, "message": "用户不存在"})
+			return
+		}
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM dishes WHERE id = ?)", dishID).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库查询失败"})
+			return
+		} else if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"code": 7, "message": "菜品不存在"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库操作失败"})
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec("INSERT INTO reviews (user_id, dish_id, content, status, created_at) VALUES (?, ?, ?, ?, ?)",
+			userID, dishID, content, StatusPending, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库操作失败"})
+			return
+		}
+		reviewID, err := res.LastInsertId()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库操作失败"})
+			return
+		}
+
+		// 提交失败时删除已保存的图片
+		var saved []string
+		committed := false
+		defer func() {
+			if !committed {
+				for _, key := range saved {
+					store.Delete(context.Background(), key)
+				}
+			}
+		}()
+
+		urls := []string{}
+		for i, img := range photos {
+			key, err := newPhotoKey()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 8, "message": "保存图片失败"})
+				return
+			}
+			data, err := media.EncodeJPEG(shrinkPhoto(img))
+			if err == nil {
+				err = store.Put(c.Request.Context(), key, data, "image/jpeg")
+			}
+			if err != nil {
+				fmt.Println("保存评论图片失败:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 8, "message": "保存图片失败"})
+				return
+			}
+			saved = append(saved, key)
+			url := store.URL(key)
+			if _, err := tx.Exec("INSERT INTO review_photos (review_id, url, sort_order) VALUES (?, ?, ?)", reviewID, url, i); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库操作失败"})
+				return
+			}
+			urls = append(urls, url)
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库操作失败"})
+			return
+		}
+		committed = true
+
+		created := []Review{{Status: StatusPending, Photos: urls}}
+		signPhotos(store, created)
+		c.JSON(http.StatusOK, gin.H{
+			"code":      0,
+			"message":   "评论已提交，审核通过后展示",
+			"review_id": reviewID,
+			"status":    StatusPending,
+			"photos":    created[0].Photos,
+		})
+	}
+}
+
+// ListDishReviewsHandler 分页获取菜品已审核通过的评论
+func ListDishReviewsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dishID, err := strconv.Atoi(c.Query("dish_id"))
+		if err != nil || dishID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "dish_id 无效"})
+			return
+		}
+		page, size, offset := pagination(c)
+
+		var total int
+		err = db.QueryRow("SELECT COUNT(*) FROM reviews WHERE dish_id = ? AND status = ?", dishID, StatusApproved).Scan(&total)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		rows, err := db.Query(`
+			SELECT `+reviewColumns+`
+			FROM reviews r
+			LEFT JOIN users u ON r.user_id = u.id
+			WHERE r.dish_id = ? AND r.status = ?
+			ORDER BY r.created_at DESC
+			LIMIT ? OFFSET ?
+		`, dishID, StatusApproved, size, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		reviews, err := scanReviews(db, rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": "数据转换失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "total": total, "page": page, "page_size": size, "reviews": reviews})
+	}
+}
+
+// ListMyReviewsHandler 获取当前用户自己的评论，包含待审核和未通过的评论
+func ListMyReviewsHandler(db *sql.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
+		page, size, offset := pagination(c)
+
+		rows, err := db.Query(`
+			SELECT `+reviewColumns+`
+			FROM reviews r
+			LEFT JOIN users u ON r.user_id = u.id
+			WHERE r.user_id = ?
+			ORDER BY r.created_at DESC
+			LIMIT ? OFFSET ?
+		`, userID, size, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		reviews, err := scanReviews(db, rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": "数据转换失败"})
+			return
+		}
+		signPhotos(store, reviews)
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "page": page, "page_size": size, "reviews": reviews})
+	}
+}
+
+// LocalPhotoHandler 本地存储时提供评论图片（路由 /review/:name）。审核通过的评论的图片公开访问，
+// 其余图片需要 SignedURL 生成的有效签名，待审核和未通过的图片不会因为地址外泄被其他人看到
+func LocalPhotoHandler(db *sql.DB, local *storage.Local) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		key := photoPrefix + name
+		signed := local.VerifySignature(key, c.Request.URL.Query())
+		if !signed {
+			var n int
+			err := db.QueryRow(`
+				SELECT COUNT(*) FROM review_photos p JOIN reviews r ON p.review_id = r.id
+				WHERE SUBSTRING_INDEX(p.url, '/', -1) = ? AND r.status = ?
+			`, name, StatusApproved).Scan(&n)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库查询失败"})
+				return
+			}
+			if n == 0 {
+				c.Status(http.StatusNotFound)
+				return
+			}
+		}
+
+		data, err := local.Get(c.Request.Context(), key)
+		if err == storage.ErrNotFound || err == storage.ErrInvalidKey {
+			c.Status(http.StatusNotFound)
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 8, "message": "读取图片失败"})
+			return
+		}
+		if signed {
+			// 签名地址只发给作者和管理员，不允许共享缓存
+			c.Header("Cache-Control", "private, max-age=3600")
+		}
+		c.Data(http.StatusOK, http.DetectContentType(data), data)
+	}
+}
@@ -0,0 +1,318 @@
+package review
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/config"
+	"backend/storage"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// reviewForm 构造发表评论的 multipart 表单
+func reviewForm(t *testing.T, fields map[string]string, photos int) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+	for i := 0; i < photos; i++ {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Fatalf("图片编码失败: %v", err)
+		}
+		part, _ := w.CreateFormFile("photos", "photo.png")
+		part.Write(buf.Bytes())
+	}
+	w.Close()
+	return body, w.FormDataContentType()
+}
+
+func TestCreateReviewHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	store := storage.NewLocal(t.TempDir(), func() string { return "http://test.com" })
+	r := gin.New()
+	r.POST("/review", CreateReviewHandler(db, store, DefaultFilter()))
+
+	post := func(fields map[string]string, photos int) *httptest.ResponseRecorder {
+		body, contentType := reviewForm(t, fields, photos)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/review", body)
+		req.Header.Set("Content-Type", contentType)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 1. 正常发表，带两张图片，进入待审核
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM users WHERE id = \?\)`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM dishes WHERE id = \?\)`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO reviews`).
+		WithArgs(1, 2, "鱼很嫩，辣度刚好", StatusPending, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectExec(`INSERT INTO review_photos`).WithArgs(int64(10), sqlmock.AnyArg(), 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO review_photos`).WithArgs(int64(10), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	w := post(map[string]string{"user_id": "1", "dish_id": "2", "content": " 鱼很嫩，辣度刚好 "}, 2)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"pending"`)
+
+	// 图片文件名随机，无法按评论 ID 猜到
+	keys, err := store.List(context.Background(), photoPrefix)
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		for _, key := range keys {
+			assert.Regexp(t, `^review/[0-9a-f]{32}\.jpg$`, key)
+			data, err := store.Get(context.Background(), key)
+			assert.NoError(t, err)
+			_, err = jpeg.Decode(bytes.NewReader(data))
+			assert.NoError(t, err)
+		}
+		assert.NotEqual(t, keys[0], keys[1])
+	}
+
+	// 2. 含敏感词
+	w = post(map[string]string{"user_id": "1", "dish_id": "2", "content": "好吃，想要优惠加 微信"}, 0)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "敏感词")
+
+	// 3. 图片太多
+	w = post(map[string]string{"user_id": "1", "dish_id": "2", "content": "好吃"}, maxPhotos+1)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 4. 内容为空或太长
+	w = post(map[string]string{"user_id": "1", "dish_id": "2", "content": "  "}, 0)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = post(map[string]string{"user_id": "1", "dish_id": "2", "content": strings.Repeat("好", maxContentRunes+1)}, 0)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 5. 菜品不存在
+	mock.ExpectQuery(`FROM users WHERE id = \?`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`FROM dishes WHERE id = \?`).WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	w = post(map[string]string{"user_id": "1", "dish_id": "99", "content": "好吃"}, 0)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListDishReviewsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/reviews", ListDishReviewsHandler(db))
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM reviews WHERE dish_id = \? AND status = \?`).WithArgs(2, StatusApproved).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`FROM reviews r\s+LEFT JOIN users u ON r.user_id = u.id\s+WHERE r.dish_id = \? AND r.status = \?`).
+		WithArgs(2, StatusApproved, defaultPageSize, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "dish_id", "content", "status", "reject_reason", "created_at", "nickname", "avatar_url"}).
+			AddRow(11, 1, 2, "好吃", StatusApproved, "", "2024-01-02", "小明", "").
+			AddRow(10, 3, 2, "一般", StatusApproved, "", "2024-01-01", "小红", ""))
+	mock.ExpectQuery(`SELECT review_id, url FROM review_photos WHERE review_id IN \(\?, \?\)`).WithArgs(int64(11), int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"review_id", "url"}).
+			AddRow(10, "http://test.com/review/10_0.png"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/reviews?dish_id=2", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `"total":2`)
+	assert.Contains(t, body, `"photos":[]`)
+	assert.Contains(t, body, `"photos":["http://test.com/review/10_0.png"]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// newFakeS3 使用模拟 S3 服务的存储，公开地址以 https://cdn.test.com 开头
+func newFakeS3(t *testing.T) storage.Storage {
+	server := httptest.NewServer(storage.NewFakeS3("ak", "sk"))
+	t.Cleanup(server.Close)
+	store, err := storage.NewS3(config.StorageConfig{
+		S3Endpoint: server.URL, S3Region: "us-east-1", S3Bucket: "media", S3AccessKey: "ak", S3SecretKey: "sk",
+		S3PublicURL: "https://cdn.test.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestShrinkPhoto(t *testing.T) {
+	img := shrinkPhoto(image.NewRGBA(image.Rect(0, 0, 4000, 3000)))
+	assert.Equal(t, image.Rect(0, 0, maxPhotoSide, 1200), img.Bounds())
+	img = shrinkPhoto(image.NewRGBA(image.Rect(0, 0, 100, 6400)))
+	assert.Equal(t, image.Rect(0, 0, 25, maxPhotoSide), img.Bounds())
+	// 小图保持原尺寸
+	img = shrinkPhoto(image.NewRGBA(image.Rect(0, 0, 800, 600)))
+	assert.Equal(t, image.Rect(0, 0, 800, 600), img.Bounds())
+}
+
+func TestSignPhotos(t *testing.T) {
+	store := newFakeS3(t)
+	reviews := []Review{
+		{Status: StatusApproved, Photos: []string{"https://cdn.test.com/review/a.png"}},
+		{Status: StatusPending, Photos: []string{"https://cdn.test.com/review/b.png"}},
+	}
+	signPhotos(store, reviews)
+
+	// 审核通过的图片使用公开地址，其余只给带签名的地址
+	assert.Equal(t, []string{"https://cdn.test.com/review/a.png"}, reviews[0].Photos)
+	if assert.Len(t, reviews[1].Photos, 1) {
+		assert.NotContains(t, reviews[1].Photos[0], "cdn.test.com")
+		assert.Contains(t, reviews[1].Photos[0], "/media/review/b.png?")
+		assert.Contains(t, reviews[1].Photos[0], "X-Amz-Signature=")
+	}
+}
+
+func TestLocalPhotoHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	local := storage.NewLocal(t.TempDir(), func() string { return "http://test.com" })
+	local.Put(context.Background(), "review/pending.jpg", []byte("pending"), "image/jpeg")
+	local.Put(context.Background(), "review/approved.jpg", []byte("approved"), "image/jpeg")
+	r := gin.New()
+	r.GET("/review/:name", LocalPhotoHandler(db, local))
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectApproved := func(name string, n int) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM review_photos p JOIN reviews r`).WithArgs(name, StatusApproved).
+			WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(n))
+	}
+
+	// 未审核通过的图片不带签名时无法访问
+	expectApproved("pending.jpg", 0)
+	assert.Equal(t, http.StatusNotFound, get("/review/pending.jpg").Code)
+
+	// 带签名可以访问，不查询审核状态
+	signed, _ := local.SignedURL("review/pending.jpg", time.Minute)
+	w := get(strings.TrimPrefix(signed, "http://test.com"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "pending", w.Body.String())
+	assert.Equal(t, "private, max-age=3600", w.Header().Get("Cache-Control"))
+
+	// 签名不能用于其他图片
+	expectApproved("other.jpg", 0)
+	assert.Equal(t, http.StatusNotFound, get(strings.Replace(strings.TrimPrefix(signed, "http://test.com"), "pending", "other", 1)).Code)
+
+	// 审核通过的图片公开访问
+	expectApproved("approved.jpg", 1)
+	w = get("/review/approved.jpg")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "approved", w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModeration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	store := newFakeS3(t)
+	store.Put(ctx, "review/0123456789abcdef0123456789abcdef.png", []byte("png"), "image/png")
+
+	cfg := &config.AppConfig{Admin: config.AdminConfig{Token: "secret"}}
+	r := gin.New()
+	admin := r.Group("/admin", RequireAdmin(func() *config.AppConfig { return cfg }))
+	admin.GET("/reviews", ListForModerationHandler(db, store))
+	admin.POST("/reviews/:id/moderate", ModerateReviewHandler(db, store))
+
+	do := func(method, url, token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set(AdminTokenHeader, token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 1. 令牌错误
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/admin/reviews", "wrong", "").Code)
+
+	// 2. 待审核列表
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM reviews WHERE status = \?`).WithArgs(StatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`WHERE r.status = \?\s+ORDER BY r.created_at ASC`).WithArgs(StatusPending, defaultPageSize, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "dish_id", "content", "status", "reject_reason", "created_at", "nickname", "avatar_url"}).
+			AddRow(10, 1, 2, "好吃", StatusPending, "", "2024-01-01", "小明", ""))
+	mock.ExpectQuery(`FROM review_photos`).WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"review_id", "url"}).
+			AddRow(10, "https://cdn.test.com/review/0123456789abcdef0123456789abcdef.png"))
+	w := do("GET", "/admin/reviews", "secret", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"pending"`)
+	assert.Contains(t, w.Body.String(), "X-Amz-Signature=", "待审核的图片只给带签名的地址")
+
+	// 3. 拒绝，未填写原因时使用默认原因，同时删除图片
+	mock.ExpectExec(`UPDATE reviews SET status = \?, reject_reason = \?, reviewed_at = \? WHERE id = \?`).
+		WithArgs(StatusRejected, "内容不符合社区规范", sqlmock.AnyArg(), int64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT url FROM review_photos WHERE review_id = \?`).WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).
+			AddRow("https://cdn.test.com/review/0123456789abcdef0123456789abcdef.png"))
+	mock.ExpectExec(`DELETE FROM review_photos WHERE review_id = \?`).WithArgs(int64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w = do("POST", "/admin/reviews/10/moderate", "secret", `{"action":"reject"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = store.Get(ctx, "review/0123456789abcdef0123456789abcdef.png")
+	assert.Equal(t, storage.ErrNotFound, err)
+
+	// 4. 评论不存在
+	mock.ExpectExec(`UPDATE reviews SET status = \?`).
+		WithArgs(StatusApproved, "", sqlmock.AnyArg(), int64(99)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Equal(t, http.StatusNotFound, do("POST", "/admin/reviews/99/moderate", "secret", `{"action":"approve"}`).Code)
+
+	// 5. action 无效
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/admin/reviews/10/moderate", "secret", `{"action":"delete"}`).Code)
+
+	// 6. 未配置令牌时禁用
+	cfg = &config.AppConfig{}
+	assert.Equal(t, http.StatusForbidden, do("GET", "/admin/reviews", "secret", "").Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeMaxKeys 模拟服务每页最多返回的文件数
+const fakeMaxKeys = 1000
+
+// FakeS3 在内存中模拟 S3 的对象读写、删除和 ListObjectsV2，并校验请求头签名和预签名地址，
+// 用于测试和没有对象存储时的本地开发（storage.s3_endpoint 为 fake）。
+// 和允许公开读的存储桶一样，读取对象不需要签名，写入、删除和列表需要签名
+type FakeS3 struct {
+	accessKey string
+	secretKey string
+	maxKeys   int // 每页最多返回的文件数
+	now       func() time.Time
+
+	mu      sync.Mutex
+	objects map[string]fakeObject // bucket/key -> 内容
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+// NewFakeS3 创建模拟服务，只接受用 accessKey、secretKey 签名的请求
+func NewFakeS3(accessKey, secretKey string) *FakeS3 {
+	return &FakeS3{accessKey: accessKey, secretKey: secretKey, maxKeys: fakeMaxKeys, now: time.Now, objects: map[string]fakeObject{}}
+}
+
+// Len 保存的对象数
+func (f *FakeS3) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.objects)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: message})
+}
+
+func (f *FakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	anonymousRead := (r.Method == http.MethodGet || r.Method == http.MethodHead) && key != "" &&
+		r.Header.Get("Authorization") == "" && !r.URL.Query().Has("X-Amz-Signature")
+	if !anonymousRead {
+		if code, msg := f.verify(r, body); code != "" {
+			writeS3Error(w, http.StatusForbidden, code, msg)
+			return
+		}
+	}
+
+	if bucket == "" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidBucketName", "缺少存储桶")
+		return
+	}
+	if key == "" {
+		if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+			f.list(w, r, bucket)
+			return
+		}
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "不支持的操作")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := bucket + "/" + key
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[name] = fakeObject{data: body, contentType: r.Header.Get("Content-Type")}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[name]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", "对象不存在")
+			return
+		}
+		if obj.contentType != "" {
+			w.Header().Set("Content-Type", obj.contentType)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "不支持的操作")
+	}
+}
+
+// list ListObjectsV2，continuation-token 为上一页最后一个 key
+func (f *FakeS3) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	prefix, after := q.Get("prefix"), q.Get("continuation-token")
+	maxKeys := f.maxKeys
+	if n, err := strconv.Atoi(q.Get("max-keys")); err == nil && n > 0 && n < maxKeys {
+		maxKeys = n
+	}
+
+	f.mu.Lock()
+	var keys []string
+	for name := range f.objects {
+		if b, key, _ := strings.Cut(name, "/"); b == bucket && strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	f.mu.Unlock()
+	sort.Strings(keys)
+
+	type content struct {
+		Key string `xml:"Key"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Name                  string    `xml:"Name"`
+		Prefix                string    `xml:"Prefix"`
+		KeyCount              int       `xml:"KeyCount"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+		Contents              []content `xml:"Contents"`
+	}{Name: bucket, Prefix: prefix}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, k := range keys {
+		result.Contents = append(result.Contents, content{k})
+	}
+	result.KeyCount = len(keys)
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// verify 校验签名，失败时返回 S3 的错误码和说明
+func (f *FakeS3) verify(r *http.Request, body []byte) (string, string) {
+	q := r.URL.Query()
+	if sig := q.Get("X-Amz-Signature"); sig != "" {
+		return f.verifyPresigned(r, sig)
+	}
+
+	// Authorization: AWS4-HMAC-SHA256 Credential=ak/date/region/s3/aws4_request, SignedHeaders=a;b, Signature=xx
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), sigAlgorithm+" ")
+	if !ok {
+		return "AccessDenied", "缺少签名"
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(auth, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			fields[k] = v
+		}
+	}
+	region, code, msg := f.checkCredential(fields["Credential"])
+	if code != "" {
+		return code, msg
+	}
+	t, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return "AccessDenied", "X-Amz-Date 无效"
+	}
+	if d := f.now().Sub(t); d > 15*time.Minute || d < -15*time.Minute {
+		return "RequestTimeTooSkewed", "请求时间与服务器相差太多"
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != unsignedPayload && payloadHash != hexSHA256(body) {
+		return "XAmzContentSHA256Mismatch", "请求体与签名不一致"
+	}
+
+	headers := map[string]string{}
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		if name == "host" {
+			headers[name] = r.Host
+		} else {
+			headers[name] = r.Header.Get(name)
+		}
+	}
+	canonical, _ := canonicalRequest(r.Method, r.URL.Path, q, headers, payloadHash)
+	if !hmac.Equal([]byte(signature(f.secretKey, region, t, canonical)), []byte(fields["Signature"])) {
+		return "SignatureDoesNotMatch", "签名不匹配"
+	}
+	return "", ""
+}
+
+// verifyPresigned 校验预签名地址
+func (f *FakeS3) verifyPresigned(r *http.Request, sig string) (string, string) {
+	q := r.URL.Query()
+	region, code, msg := f.checkCredential(q.Get("X-Amz-Credential"))
+	if code != "" {
+		return code, msg
+	}
+	t, err := time.Parse(amzDateFormat, q.Get("X-Amz-Date"))
+	if err != nil {
+		return "AccessDenied", "X-Amz-Date 无效"
+	}
+	expires, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil || f.now().After(t.Add(time.Duration(expires)*time.Second)) {
+		return "AccessDenied", "签名已过期"
+	}
+	q.Del("X-Amz-Signature")
+	canonical, _ := canonicalRequest(r.Method, r.URL.Path, q, map[string]string{"host": r.Host}, unsignedPayload)
+	if !hmac.Equal([]byte(signature(f.secretKey, region, t, canonical)), []byte(sig)) {
+		return "SignatureDoesNotMatch", "签名不匹配"
+	}
+	return "", ""
+}
+
+// checkCredential 检查 ak/date/region/s3/aws4_request 中的 Access Key，返回区域
+func (f *FakeS3) checkCredential(credential string) (string, string, string) {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return "", "AuthorizationQueryParametersError", "Credential 格式错误"
+	}
+	if parts[0] != f.accessKey {
+		return "", "InvalidAccessKeyId", "Access Key 不存在"
+	}
+	return parts[2], "", ""
+}
@@ -0,0 +1,15 @@
+//go:build dev
+
+package storage
+
+import (
+	"net/http/httptest"
+
+	"backend/config"
+)
+
+// startFake 在进程内启动 FakeS3，返回它的地址和关闭函数
+func startFake(cfg config.StorageConfig) (string, func(), error) {
+	server := httptest.NewServer(NewFakeS3(cfg.S3AccessKey, cfg.S3SecretKey))
+	return server.URL, server.Close, nil
+}
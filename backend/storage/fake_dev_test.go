@@ -0,0 +1,26 @@
+//go:build dev
+
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"backend/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Fake(t *testing.T) {
+	s, stop, err := New(config.StorageConfig{
+		Driver: DriverS3, S3Endpoint: FakeEndpoint, S3Region: "us-east-1", S3Bucket: "media", S3AccessKey: "ak", S3SecretKey: "sk",
+	}, func() string { return "" })
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stop()
+	assert.NoError(t, s.Put(context.Background(), "a.txt", []byte("a"), "text/plain"))
+	data, err := s.Get(context.Background(), "a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+}
@@ -0,0 +1,14 @@
+//go:build !dev
+
+package storage
+
+import (
+	"errors"
+
+	"backend/config"
+)
+
+// startFake 正式版本不包含模拟服务：FakeS3 的数据只保存在内存中，重启后全部丢失
+func startFake(config.StorageConfig) (string, func(), error) {
+	return "", nil, errors.New("storage.s3_endpoint 为 fake 时需要使用 go build -tags dev 编译的开发版本")
+}
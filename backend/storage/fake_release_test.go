@@ -0,0 +1,16 @@
+//go:build !dev
+
+package storage
+
+import (
+	"testing"
+
+	"backend/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_FakeRequiresDevBuild(t *testing.T) {
+	_, _, err := New(config.StorageConfig{Driver: DriverS3, S3Endpoint: FakeEndpoint, S3Bucket: "media"}, func() string { return "" })
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local 把文件保存在本地目录，通过服务的路由对外访问。多个实例之间不共享，只适合单实例部署
+type Local struct {
+	dir     string
+	baseURL func() string
+
+	signKey []byte           // SignedURL 的签名密钥，每次启动随机生成，重启后之前的签名地址失效
+	now     func() time.Time // 测试时替换
+}
+
+// NewLocal 创建本地存储，baseURL 返回访问地址的前缀（服务器域名）
+func NewLocal(dir string, baseURL func() string) *Local {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return &Local{dir: dir, baseURL: baseURL, signKey: key, now: time.Now}
+}
+
+// Dir 某个前缀对应的本地目录，用于注册静态路由
+func (l *Local) Dir(prefix string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(prefix))
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put 先写临时文件再改名，读取方不会看到写了一半的文件
+func (l *Local) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (l *Local) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List 只遍历 prefix 所在的目录，跳过写入中的临时文件
+func (l *Local) List(ctx context.Context, prefix string) ([]string, error) {
+	root := prefix
+	if !strings.HasSuffix(root, "/") {
+		root = path.Dir(root)
+	}
+	keys := []string{}
+	err := filepath.WalkDir(l.Dir(root), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (l *Local) URL(key string) string {
+	return strings.TrimSuffix(l.baseURL(), "/") + "/" + key
+}
+
+// SignedURL 在公开地址后加上过期时间和 HMAC 签名，由提供文件的路由用 VerifySignature 校验
+func (l *Local) SignedURL(key string, ttl time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	expires := strconv.FormatInt(l.now().Add(ttl).Unix(), 10)
+	q := url.Values{"expires": {expires}, "signature": {l.sign(key, expires)}}
+	return l.URL(key) + "?" + q.Encode(), nil
+}
+
+// VerifySignature 检查请求参数中 SignedURL 生成的签名是否有效且未过期
+func (l *Local) VerifySignature(key string, query url.Values) bool {
+	expires := query.Get("expires")
+	t, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || l.now().Unix() > t {
+		return false
+	}
+	return hmac.Equal([]byte(l.sign(key, expires)), []byte(query.Get("signature")))
+}
+
+func (l *Local) sign(key, expires string) string {
+	return hex.EncodeToString(hmacSHA256(l.signKey, key+"\n"+expires))
+}
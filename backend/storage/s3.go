@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend/config"
+)
+
+// s3Timeout 单次请求的超时时间
+const s3Timeout = 10 * time.Second
+
+// S3 兼容 S3 接口的对象存储，使用路径风格的地址 endpoint/bucket/key，MinIO 等自建服务也可以使用
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	publicURL string
+
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3 按配置创建 S3 存储
+func NewS3(cfg config.StorageConfig) (*S3, error) {
+	u, err := url.Parse(strings.TrimSuffix(cfg.S3Endpoint, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("storage.s3_endpoint 无效: %q", cfg.S3Endpoint)
+	}
+	return &S3{
+		endpoint:  u,
+		region:    cfg.S3Region,
+		bucket:    cfg.S3Bucket,
+		accessKey: cfg.S3AccessKey,
+		secretKey: cfg.S3SecretKey,
+		publicURL: strings.TrimSuffix(cfg.S3PublicURL, "/"),
+		client:    &http.Client{Timeout: s3Timeout},
+		now:       time.Now,
+	}, nil
+}
+
+// objectPath 对象的请求路径（未编码）
+func (s *S3) objectPath(key string) string {
+	return s.endpoint.Path + "/" + s.bucket + "/" + key
+}
+
+// do 发送带签名的请求
+func (s *S3) do(ctx context.Context, method, path string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	now := s.now().UTC()
+	payloadHash := hexSHA256(body)
+	u := *s.endpoint
+	u.Path, u.RawPath, u.RawQuery = path, uriEncode(path, false), canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           now.Format(amzDateFormat),
+	}
+	canonical, signed := canonicalRequest(method, path, query, headers, payloadHash)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", headers["x-amz-date"])
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigAlgorithm, s.accessKey, credentialScope(now, s.region), signed, signature(s.secretKey, s.region, now, canonical)))
+	return s.client.Do(req)
+}
+
+// s3Error S3 返回的错误
+type s3Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("S3 返回错误 %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// readError 读取错误响应
+func readError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	e := &s3Error{Status: resp.StatusCode}
+	xml.Unmarshal(body, e)
+	return e
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.objectPath(key), nil, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectPath(key), nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	}
+	return nil, readError(resp)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.objectPath(key), nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return readError(resp)
+}
+
+// listResult ListObjectsV2 的响应
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List 使用 ListObjectsV2，结果超过一页时继续请求
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, s.endpoint.Path+"/"+s.bucket, query, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := readError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析 S3 列表失败: %w", err)
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// URL 配置了 s3_public_url（如 CDN）时使用该地址，否则直接访问存储桶，需要存储桶允许公开读
+func (s *S3) URL(key string) string {
+	if s.publicURL != "" {
+		return s.publicURL + "/" + uriEncode(key, false)
+	}
+	u := *s.endpoint
+	u.RawQuery = ""
+	return u.String() + "/" + s.bucket + "/" + uriEncode(key, false)
+}
+
+// SignedURL 预签名的下载地址，ttl 最长 7 天
+func (s *S3) SignedURL(key string, ttl time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	if ttl <= 0 || ttl > 7*24*time.Hour {
+		return "", fmt.Errorf("签名有效期必须在 7 天以内: %v", ttl)
+	}
+	p := s.objectPath(key)
+	q := presignQuery(s.accessKey, s.secretKey, s.region, http.MethodGet, s.endpoint.Host, p, s.now(), ttl)
+	u := *s.endpoint
+	u.Path, u.RawPath, u.RawQuery = p, uriEncode(p, false), canonicalQuery(q)
+	return u.String(), nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4，S3 兼容服务（MinIO、COS 等）都支持
+const (
+	sigAlgorithm    = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode 按 SigV4 的规则编码：只保留 A-Z a-z 0-9 - _ . ~，路径中的 / 可以保留
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery 按参数名排序并编码的查询串，也用作实际请求的查询串，保证两者一致
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// canonicalRequest 规范请求，headers 的名称为小写；同时返回参与签名的请求头列表
+func canonicalRequest(method, path string, query url.Values, headers map[string]string, payloadHash string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, name := range names {
+		canonHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+	return strings.Join([]string{
+		method,
+		uriEncode(path, false),
+		canonicalQuery(query),
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n"), signed
+}
+
+// credentialScope 签名的有效范围：日期/区域/服务
+func credentialScope(t time.Time, region string) string {
+	return t.UTC().Format("20060102") + "/" + region + "/s3/aws4_request"
+}
+
+// signature 用派生密钥对规范请求签名
+func signature(secretKey, region string, t time.Time, canonical string) string {
+	t = t.UTC()
+	stringToSign := strings.Join([]string{
+		sigAlgorithm,
+		t.Format(amzDateFormat),
+		credentialScope(t, region),
+		hexSHA256([]byte(canonical)),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+secretKey), t.Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// presignQuery 生成预签名地址的查询参数，只签名 host 请求头
+func presignQuery(accessKey, secretKey, region, method, host, path string, t time.Time, ttl time.Duration) url.Values {
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", sigAlgorithm)
+	q.Set("X-Amz-Credential", accessKey+"/"+credentialScope(t, region))
+	q.Set("X-Amz-Date", t.UTC().Format(amzDateFormat))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl/time.Second)))
+	q.Set("X-Amz-SignedHeaders", "host")
+	canonical, _ := canonicalRequest(method, path, q, map[string]string{"host": host}, unsignedPayload)
+	q.Set("X-Amz-Signature", signature(secretKey, region, t, canonical))
+	return q
+}
@@ -0,0 +1,81 @@
+// Package storage 上传文件（头像、评论图片）的存储：本地目录或兼容 S3 接口的对象存储
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/config"
+)
+
+// 存储类型，对应配置项 storage.driver
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// FakeEndpoint storage.s3_endpoint 为该值时在进程内启动模拟的 S3 服务，用于没有对象存储时的本地开发；
+// 只有使用 -tags dev 编译的开发版本才允许启用
+const FakeEndpoint = "fake"
+
+var (
+	ErrNotFound   = errors.New("文件不存在")
+	ErrInvalidKey = errors.New("文件路径无效")
+)
+
+// Storage 文件存储。key 是以 / 分隔的相对路径，如 avatar/1-abc-64.jpg
+type Storage interface {
+	// Put 保存文件，已存在时覆盖
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get 读取文件，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// List 列出以 prefix 开头的所有文件，按 key 排序
+	List(ctx context.Context, prefix string) ([]string, error)
+	// URL 文件的公开访问地址
+	URL(key string) string
+	// SignedURL 在 ttl 内有效的带签名访问地址，用于不公开的文件
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// validKey 检查 key 是不含 . 和 .. 的相对路径，防止访问存储目录之外的文件
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// New 按配置创建存储，baseURL 返回当前的服务器域名，用于拼接本地文件的访问地址；
+// storage.s3_endpoint 为 fake 时启动模拟服务，不是开发版本时返回错误。
+// 返回的函数用于关闭模拟服务，使用真实存储时为空操作
+func New(cfg config.StorageConfig, baseURL func() string) (Storage, func(), error) {
+	switch cfg.Driver {
+	case DriverLocal:
+		return NewLocal(cfg.LocalDir, baseURL), func() {}, nil
+	case DriverS3:
+		stop := func() {}
+		if cfg.S3Endpoint == FakeEndpoint {
+			var err error
+			if cfg.S3Endpoint, stop, err = startFake(cfg); err != nil {
+				return nil, nil, err
+			}
+		}
+		s, err := NewS3(cfg)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		return s, stop, nil
+	}
+	return nil, nil, fmt.Errorf("storage.driver 不支持: %q", cfg.Driver)
+}
@@ -1,89 +1,178 @@
 package user
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"image"
-	"image/png"
-	"log"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 
-	"backend/config"
-
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
+	"backend/auth"
+	"backend/media"
+	"backend/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UploadAvatarHandler(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userIDStr := c.PostForm("user_id")
-		userID, err := strconv.Atoi(userIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "用户ID错误"})
-			return
+// avatarPrefix 头像在存储中的目录
+const avatarPrefix = "avatar/"
+
+// AvatarSizes 生成的头像尺寸（像素，正方形），最后一个保存到 users.avatar_url
+var AvatarSizes = []int{64, 128, 256}
+
+// avatarHashLen 文件名中内容哈希的长度
+const avatarHashLen = 16
+
+// avatarKey 头像的存储路径 avatar/<用户ID>-<内容哈希>-<尺寸>.jpg。内容变化时地址随之变化，客户端和 CDN 不会拿到旧图
+func avatarKey(userID int, hash string, size int) string {
+	return fmt.Sprintf("%s%d-%s-%d.jpg", avatarPrefix, userID, hash, size)
+}
+
+// AvatarKeys 用户在存储中的所有头像，用于导出和注销账号时清理；包含旧版本保存的 <用户ID>.png
+func AvatarKeys(ctx context.Context, store storage.Storage, userID int) ([]string, error) {
+	id := strconv.Itoa(userID)
+	keys, err := store.List(ctx, avatarPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, key := range keys {
+		// 前缀 avatar/12 也会列出 avatar/123-...，需要再按文件名过滤
+		name := strings.TrimPrefix(key, avatarPrefix)
+		if name == id+".png" || strings.HasPrefix(name, id+"-") {
+			result = append(result, key)
 		}
+	}
+	return result, nil
+}
 
-		file, fileHeader, err := c.Request.FormFile("avatar")
+// renderAvatars 把图片居中裁剪为正方形并生成各尺寸的 JPEG，返回尺寸到内容的映射和内容哈希
+func renderAvatars(img image.Image) (map[int][]byte, string, error) {
+	square := media.CropSquare(img)
+	out := make(map[int][]byte, len(AvatarSizes))
+	h := sha256.New()
+	for _, size := range AvatarSizes {
+		data, err := media.EncodeJPEG(media.Resize(square, size, size))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 2, "message": "上传失败"})
-			return
+			return nil, "", err
 		}
-		defer file.Close()
+		out[size] = data
+		h.Write(data)
+	}
+	return out, hex.EncodeToString(h.Sum(nil))[:avatarHashLen], nil
+}
 
-		if fileHeader.Size > 2*1024*1024 {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 9, "message": "图片太大，请上传小于2MB的图片"})
+// UploadAvatarHandler 上传头像，保存到 store。图片按 EXIF 方向转正、居中裁剪后生成 AvatarSizes 中的各个尺寸，
+// 文件名带内容哈希，上传成功后删除旧头像
+func UploadAvatarHandler(db *sql.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimed, _ := strconv.Atoi(c.PostForm("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
 			return
 		}
 
-		// 解码图片为 image.Image
-		img, _, err := image.Decode(file)
+		_, fileHeader, err := c.Request.FormFile("avatar")
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 3, "message": "图片解码失败（请上传 JPG/PNG 等标准图片）"})
+			c.JSON(http.StatusBadRequest, gin.H{"code": 2, "message": "上传失败"})
 			return
 		}
 
-		// 确保目录存在
-		err = os.MkdirAll("data/avatar", os.ModePerm)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 7, "message": "创建目录失败"})
+		// 解码图片为 image.Image
+		img, err := media.DecodeUpload(fileHeader)
+		if err == media.ErrTooLarge || err == media.ErrDimensions {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 9, "message": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 3, "message": media.ErrDecode.Error()})
 			return
 		}
 
-		// 保存为 PNG 格式
-		savePath := fmt.Sprintf("data/avatar/%d.png", userID)
-		out, err := os.Create(savePath)
+		rendered, hash, err := renderAvatars(img)
 		if err != nil {
+			fmt.Println("生成头像失败:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 4, "message": "保存头像失败"})
 			return
 		}
-		defer out.Close()
 
-		err = png.Encode(out, img) //图片写入文件夹
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "PNG 编码失败"})
+		// 先记下当前头像地址，更新时以它为条件，同一用户同时上传时只有一个请求生效
+		var oldURL sql.NullString
+		err = db.QueryRow("SELECT avatar_url FROM users WHERE id = ?", userID).Scan(&oldURL)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"code": 7, "message": "用户不存在"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 6, "message": "数据库查询失败"})
 			return
 		}
 
-		Cfg, err := config.LoadServerConfig("config/server_config.json")
+		ctx := c.Request.Context()
+		existing, err := AvatarKeys(ctx, store, userID)
 		if err != nil {
-			log.Fatalf("加载微信配置失败: %v", err)
+			fmt.Println("查询旧头像失败:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 4, "message": "保存头像失败"})
+			return
+		}
+		current := map[string]bool{}
+		for _, key := range existing {
+			current[key] = true
 		}
 
-		// 构造头像访问 URL（替换为你的公网 IP 或域名）
-		avatarURL := fmt.Sprintf("%s/avatar/%d.png", Cfg.Domain, userID)
+		// 保存各尺寸；同一张图片已经上传过时不再保存，失败时只删除本次新保存的文件
+		urls := make(map[string]string, len(AvatarSizes))
+		var saved []string
+		for _, size := range AvatarSizes {
+			key := avatarKey(userID, hash, size)
+			urls[strconv.Itoa(size)] = store.URL(key)
+			if current[key] {
+				continue
+			}
+			if err := store.Put(ctx, key, rendered[size], "image/jpeg"); err != nil {
+				fmt.Println("保存头像失败:", err)
+				deleteKeys(store, saved)
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 4, "message": "保存头像失败"})
+				return
+			}
+			saved = append(saved, key)
+		}
+		avatarURL := urls[strconv.Itoa(AvatarSizes[len(AvatarSizes)-1])]
 
 		// 更新数据库头像地址
-		_, err = db.Exec("UPDATE users SET avatar_url = ? WHERE id = ?", avatarURL, userID)
+		res, err := db.Exec("UPDATE users SET avatar_url = ? WHERE id = ? AND avatar_url <=> ?", avatarURL, userID, oldURL)
 		if err != nil {
+			deleteKeys(store, saved)
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 6, "message": "数据库更新失败"})
 			return
 		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			// 头像已被同时进行的另一次上传更新。本次保存的文件可能和那次相同（同一张图片），不在这里删除，
+			// 下次上传成功时会作为旧头像一起清理
+			c.JSON(http.StatusConflict, gin.H{"code": 10, "message": "头像已被更新，请重试"})
+			return
+		}
+
+		// 删除旧头像
+		var stale []string
+		for _, key := range existing {
+			if !strings.Contains(key, "-"+hash+"-") {
+				stale = append(stale, key)
+			}
+		}
+		deleteKeys(store, stale)
 
-		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "上传成功", "avatar_url": avatarURL})
+		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "上传成功", "avatar_url": avatarURL, "avatar_urls": urls})
+	}
+}
+
+// deleteKeys 删除文件，失败时只记录日志
+func deleteKeys(store storage.Storage, keys []string) {
+	for _, key := range keys {
+		if err := store.Delete(context.Background(), key); err != nil {
+			fmt.Println("删除头像失败:", err)
+		}
 	}
 }
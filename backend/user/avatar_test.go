@@ -2,28 +2,45 @@ package user
 
 import (
 	"backend/config"
+	"backend/storage"
 	"bytes"
+	"context"
+	"encoding/json"
 	"image"
+	"image/jpeg"
 	"image/png"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
+	"path"
+	"regexp"
+	"strconv"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
 )
 
-// mock config.LoadServerConfig
-var oldLoadServerConfig = config.LoadServerConfig
+// avatarForm 构造上传一张内存 PNG 图片的 multipart 表单
+func avatarForm(t *testing.T) (*bytes.Buffer, string) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	var imgBuf bytes.Buffer
+	if err := png.Encode(&imgBuf, img); err != nil {
+		t.Fatalf("图片编码失败: %v", err)
+	}
 
-func mockLoadServerConfig(path string) (*config.ServerConfig, error) {
-	return &config.ServerConfig{Domain: "http://test.com"}, nil
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("user_id", "123")
+	part, _ := writer.CreateFormFile("avatar", "avatar.png")
+	part.Write(imgBuf.Bytes())
+	writer.Close()
+	return body, writer.FormDataContentType()
 }
 
-func TestUploadAvatarHandler(t *testing.T) {
+// testUploadAvatar 上传头像后各尺寸都已保存、旧头像已删除；urlPrefix 为头像地址的前缀
+func testUploadAvatar(t *testing.T, store storage.Storage, urlPrefix string) {
 	gin.SetMode(gin.TestMode)
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -31,44 +48,109 @@ func TestUploadAvatarHandler(t *testing.T) {
 	}
 	defer db.Close()
 
-	// 创建测试用的 config/server_config.json
-	_ = os.MkdirAll("config", 0755)
-	configContent := `{"domain":"http://test.com"}`
-	configPath := filepath.Join("config", "server_config.json")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		t.Fatalf("写入测试配置文件失败: %v", err)
-	}
-	defer os.Remove(configPath) // 测试结束后删除
-
 	r := gin.New()
-	r.POST("/upload", UploadAvatarHandler(db))
+	r.POST("/upload", UploadAvatarHandler(db, store))
 
-	// 构造一张内存PNG图片
-	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
-	var imgBuf bytes.Buffer
-	if err := png.Encode(&imgBuf, img); err != nil {
-		t.Fatalf("图片编码失败: %v", err)
+	// 预先放入旧版本的头像
+	ctx := context.Background()
+	for _, key := range []string{"avatar/123.png", "avatar/123-0000000000000000-64.jpg", "avatar/1234.png"} {
+		store.Put(ctx, key, []byte("old"), "")
 	}
 
-	// 构造multipart表单
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	_ = writer.WriteField("user_id", "123")
-	part, _ := writer.CreateFormFile("avatar", "avatar.png")
-	part.Write(imgBuf.Bytes())
-	writer.Close()
+	body, contentType := avatarForm(t)
 
 	// mock数据库
-	mock.ExpectExec("UPDATE users SET avatar_url = \\? WHERE id = \\?").
-		WithArgs("http://test.com/avatar/123.png", 123).
+	mock.ExpectQuery("SELECT avatar_url FROM users WHERE id = \\?").WithArgs(123).
+		WillReturnRows(sqlmock.NewRows([]string{"avatar_url"}).AddRow(urlPrefix + "/avatar/123-0000000000000000-256.jpg"))
+	mock.ExpectExec("UPDATE users SET avatar_url = \\? WHERE id = \\? AND avatar_url <=> \\?").
+		WithArgs(sqlmock.AnyArg(), 123, urlPrefix+"/avatar/123-0000000000000000-256.jpg").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/upload", body)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Content-Type", contentType)
 	r.ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("上传成功")) {
 		t.Errorf("上传成功用例失败，返回: %s", w.Body.String())
 	}
+
+	var resp struct {
+		AvatarURL  string            `json:"avatar_url"`
+		AvatarURLs map[string]string `json:"avatar_urls"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Regexp(t, "^"+regexp.QuoteMeta(urlPrefix)+`/avatar/123-[0-9a-f]{16}-256\.jpg$`, resp.AvatarURL)
+	assert.Len(t, resp.AvatarURLs, len(AvatarSizes))
+	assert.Equal(t, resp.AvatarURL, resp.AvatarURLs["256"])
+
+	// 各尺寸都已生成，旧头像已删除
+	for size, url := range resp.AvatarURLs {
+		data, err := store.Get(ctx, "avatar/"+path.Base(url))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, size, strconv.Itoa(cfg.Width))
+	}
+	_, err = store.Get(ctx, "avatar/123.png")
+	assert.Equal(t, storage.ErrNotFound, err)
+	_, err = store.Get(ctx, "avatar/123-0000000000000000-64.jpg")
+	assert.Equal(t, storage.ErrNotFound, err)
+	_, err = store.Get(ctx, "avatar/1234.png")
+	assert.NoError(t, err, "其他用户的头像不受影响")
+	keys, err := AvatarKeys(ctx, store, 123)
+	assert.NoError(t, err)
+	assert.Len(t, keys, len(AvatarSizes))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadAvatarHandler(t *testing.T) {
+	testUploadAvatar(t, storage.NewLocal(t.TempDir(), func() string { return "http://test.com" }), "http://test.com")
+}
+
+func TestUploadAvatarHandler_S3(t *testing.T) {
+	server := httptest.NewServer(storage.NewFakeS3("ak", "sk"))
+	defer server.Close()
+	store, err := storage.NewS3(config.StorageConfig{
+		S3Endpoint: server.URL, S3Region: "us-east-1", S3Bucket: "media", S3AccessKey: "ak", S3SecretKey: "sk",
+		S3PublicURL: "https://cdn.test.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testUploadAvatar(t, store, "https://cdn.test.com")
+}
+
+func TestUploadAvatarHandler_Conflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	store := storage.NewLocal(t.TempDir(), func() string { return "http://test.com" })
+	r := gin.New()
+	r.POST("/upload", UploadAvatarHandler(db, store))
+
+	// 另一次上传已经把头像换成了 other，它的文件不能被删除
+	ctx := context.Background()
+	store.Put(ctx, "avatar/123-1111111111111111-256.jpg", []byte("other"), "")
+	mock.ExpectQuery("SELECT avatar_url FROM users WHERE id = \\?").WithArgs(123).
+		WillReturnRows(sqlmock.NewRows([]string{"avatar_url"}).AddRow(nil))
+	mock.ExpectExec("UPDATE users SET avatar_url = \\? WHERE id = \\? AND avatar_url <=> \\?").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	body, contentType := avatarForm(t)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	_, err = store.Get(ctx, "avatar/123-1111111111111111-256.jpg")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
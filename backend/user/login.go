@@ -2,11 +2,14 @@ package user
 
 import (
 	"database/sql"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+
+	"backend/auth"
+	"backend/profile"
+	"backend/wechat"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -18,15 +21,10 @@ type WxLoginRequest struct {
 	AvatarURL string `json:"avatar_url"`
 }
 
-type WxSessionResponse struct {
-	OpenID     string `json:"openid"`
-	SessionKey string `json:"session_key"`
-	ErrCode    int    `json:"errcode"`
-	ErrMsg     string `json:"errmsg"`
-}
-
-// WxLoginHandler 微信登录处理函数
-func WxLoginHandler(db *sql.DB, appID, appSecret string) gin.HandlerFunc {
+// WxLoginHandler 微信登录处理函数。登录后写入 session，同时签发访问令牌和刷新令牌，
+// 小程序不方便使用 cookie 时在请求头 Authorization: Bearer 中携带访问令牌。
+// 微信返回的 session_key 加密保存，用于之后解密手机号等数据
+func WxLoginHandler(db *sql.DB, wx wechat.Client, tokens *auth.Tokens, keys *SessionKeys) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req WxLoginRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -34,22 +32,33 @@ func WxLoginHandler(db *sql.DB, appID, appSecret string) gin.HandlerFunc {
 			return
 		}
 
-		openid, err := getOpenID(req.Code, appID, appSecret)
+		wxSession, err := wx.Code2Session(c.Request.Context(), req.Code)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": err.Error()})
 			log.Printf("WxLoginHandler 出错: %v", err)
+			// code 无效或已使用时微信返回业务错误，其余是网络等问题
+			status := http.StatusInternalServerError
+			var apiErr *wechat.APIError
+			if errors.As(err, &apiErr) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{"code": 2, "message": err.Error()})
 			return
 		}
+		openid := wxSession.OpenID
 
 		var userID int
 		var exists bool
+		var storedAvatar sql.NullString
 
-		// 查询是否存在
-		err = db.QueryRow("SELECT id FROM users WHERE openid = ?", openid).Scan(&userID)
+		// 查询是否存在；同一开放平台下其他应用创建的账号通过 unionid 关联
+		err = db.QueryRow("SELECT id, avatar_url FROM users WHERE openid = ?", openid).Scan(&userID, &storedAvatar)
+		if err == sql.ErrNoRows && wxSession.UnionID != "" {
+			err = db.QueryRow("SELECT id, avatar_url FROM users WHERE unionid = ?", wxSession.UnionID).Scan(&userID, &storedAvatar)
+		}
 		if err == sql.ErrNoRows {
 			// 不存在，插入新用户
-			res, err := db.Exec("INSERT INTO users (openid, nickname, avatar_url) VALUES (?, ?, ?)",
-				openid, req.Nickname, req.AvatarURL)
+			res, err := db.Exec("INSERT INTO users (openid, unionid, nickname, avatar_url) VALUES (?, ?, ?, ?)",
+				openid, nullIfEmpty(wxSession.UnionID), req.Nickname, req.AvatarURL)
 			if err != nil {
 				log.Printf("插入用户失败: %v", err)
 				c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": "数据库写入失败"})
@@ -64,72 +73,76 @@ func WxLoginHandler(db *sql.DB, appID, appSecret string) gin.HandlerFunc {
 			return
 		}
 
-		// 已存在则更新头像昵称（支持修改）
+		// 已存在则更新昵称（支持修改），并补上之前没有的 unionid。头像通过 /api/user/avatar 上传，
+		// 登录时只在还没有头像时使用客户端传来的地址，不覆盖已上传的头像
+		avatar := req.AvatarURL
 		if exists {
-			_, err := db.Exec("UPDATE users SET nickname = ?, avatar_url = ? WHERE openid = ?",
-				req.Nickname, req.AvatarURL, openid)
+			if storedAvatar.String != "" {
+				avatar = storedAvatar.String
+			}
+			_, err := db.Exec(`UPDATE users SET nickname = ?, avatar_url = IF(COALESCE(avatar_url, '') = '', ?, avatar_url),
+				unionid = COALESCE(?, unionid) WHERE id = ?`,
+				req.Nickname, req.AvatarURL, nullIfEmpty(wxSession.UnionID), userID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "用户信息更新失败"})
 				return
 			}
 		}
 
-		// 获取用户统计信息
-		var mealCount int
-		var favoriteTaste, commonMood, moodFood sql.NullString
-
-		err = db.QueryRow(`
-			SELECT meal_count, favorite_taste, common_mood, mood_food 
-			FROM users WHERE id = ?
-		`, userID).Scan(&mealCount, &favoriteTaste, &commonMood, &moodFood)
+		// 登录时重新计算用户画像，顺便补上画像功能上线前的老用户；
+		// 画像不影响登录，计算失败时返回 users 表中上次保存的结果
+		stats, err := profile.Refresh(c.Request.Context(), db, userID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 6, "message": "用户统计信息查询失败"})
-			return
+			log.Printf("计算用户画像失败: %v", err)
+			if stats, err = storedProfile(db, userID); err != nil {
+				log.Printf("读取用户画像失败: %v", err)
+			}
+		}
+
+		// 保存失败不影响登录，之后解密数据时会提示重新登录
+		if err := keys.Save(c.Request.Context(), userID, wxSession.SessionKey); err != nil {
+			log.Printf("保存 session_key 失败: %v", err)
 		}
 
 		// 写入 session
 		session := sessions.Default(c)
-		session.Set("user_id", userID)
+		session.Set(auth.SessionUserKey, userID)
 		session.Save()
 
+		pair, err := tokens.Issue(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("签发令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 7, "message": "令牌签发失败"})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"code":           0,
 			"message":        "登录成功",
 			"user_id":        userID,
 			"openid":         openid,
 			"nickname":       req.Nickname,
-			"avatar":         req.AvatarURL,
-			"meal_count":     mealCount,
-			"favorite_taste": favoriteTaste.String,
-			"common_mood":    commonMood.String,
-			"mood_food":      moodFood.String,
+			"avatar":         avatar,
+			"meal_count":     stats.MealCount,
+			"favorite_taste": stats.FavoriteTaste,
+			"common_mood":    stats.CommonMood,
+			"mood_food":      stats.MoodFood,
+			"access_token":   pair.AccessToken,
+			"token_type":     pair.TokenType,
+			"expires_in":     pair.ExpiresIn,
+			"refresh_token":  pair.RefreshToken,
 		})
 	}
 }
 
-// 获取openid
-func getOpenID(code, appID, appSecret string) (string, error) {
-	url := fmt.Sprintf("https://api.weixin.qq.com/sns/jscode2session?appid=%s&secret=%s&js_code=%s&grant_type=authorization_code",
-		appID, appSecret, code)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
-	// log.Printf("微信返回: %s", string(body))
-	var wxResp WxSessionResponse
-	err = json.Unmarshal(body, &wxResp)
-	if err != nil {
-		return "", err
-	}
-
-	if wxResp.ErrCode != 0 {
-		return "", fmt.Errorf("微信返回错误: %s", wxResp.ErrMsg)
-	}
-
-	return wxResp.OpenID, nil
+// storedProfile 读取 users 表中上次保存的画像
+func storedProfile(db *sql.DB, userID int) (profile.Profile, error) {
+	var p profile.Profile
+	var favoriteTaste, commonMood, moodFood sql.NullString
+	err := db.QueryRow("SELECT meal_count, favorite_taste, common_mood, mood_food FROM users WHERE id = ?", userID).
+		Scan(&p.MealCount, &favoriteTaste, &commonMood, &moodFood)
+	p.FavoriteTaste, p.CommonMood, p.MoodFood = favoriteTaste.String, commonMood.String, moodFood.String
+	return p, err
 }
 
 // UpdateNicknameHandler 更新昵称
@@ -143,8 +156,12 @@ func UpdateNicknameHandler(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数错误"})
 			return
 		}
+		userID, ok := auth.CurrentUser(c, req.UserID)
+		if !ok {
+			return
+		}
 
-		_, err := db.Exec("UPDATE users SET nickname = ? WHERE id = ?", req.Nickname, req.UserID)
+		_, err := db.Exec("UPDATE users SET nickname = ? WHERE id = ?", req.Nickname, userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "更新失败"})
 			return
@@ -157,9 +174,9 @@ func UpdateNicknameHandler(db *sql.DB) gin.HandlerFunc {
 // GetUserInfoHandler 返回用户完整信息
 func GetUserInfoHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Query("user_id")
-		if userID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "缺少 user_id"})
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
 			return
 		}
 
@@ -7,7 +7,13 @@ import (
 	"strings"
 	"testing"
 
+	"backend/auth"
+	"backend/config"
+	"backend/wechat"
+
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,7 +23,7 @@ func TestWxLoginHandler_ParamError(t *testing.T) {
 	defer db.Close()
 
 	router := gin.Default()
-	router.POST("/api/user/wxlogin", WxLoginHandler(db, "appid", "appsecret"))
+	router.POST("/api/user/wxlogin", WxLoginHandler(db, nil, nil, nil))
 
 	body := `{"nickname":"小明"}`
 	req, _ := http.NewRequest("POST", "/api/user/wxlogin", strings.NewReader(body))
@@ -30,3 +36,108 @@ func TestWxLoginHandler_ParamError(t *testing.T) {
 	json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.Equal(t, float64(1), resp["code"])
 }
+
+func TestWxLoginHandler_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	server := httptest.NewServer(wechat.NewFakeServer("wxapp", "secret"))
+	defer server.Close()
+	wx := wechat.NewHTTPClient(server.URL, "wxapp", "secret")
+
+	cfg := config.Default()
+	cfg.Auth.TokenKeys = "k1:0123456789abcdef0123456789abcdef"
+	tokens := auth.NewTokens(db, config.Static(cfg))
+	keys, _ := NewSessionKeys(db, "0123456789abcdef0123456789abcdef")
+
+	r := gin.New()
+	r.Use(sessions.Sessions("test", cookie.NewStore([]byte("test-secret"))))
+	r.POST("/login", WxLoginHandler(db, wx, tokens, keys))
+
+	s := wechat.FakeSession("alice")
+	mock.ExpectQuery(`SELECT id, avatar_url FROM users WHERE openid = \?`).WithArgs(s.OpenID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "avatar_url"}))
+	mock.ExpectQuery(`SELECT id, avatar_url FROM users WHERE unionid = \?`).WithArgs(s.UnionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "avatar_url"}))
+	mock.ExpectExec(`INSERT INTO users \(openid, unionid, nickname, avatar_url\)`).
+		WithArgs(s.OpenID, s.UnionID, "小明", "").WillReturnResult(sqlmock.NewResult(5, 1))
+	// 新用户的画像为空
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM recommend_history`).
+		WillReturnRows(sqlmock.NewRows([]string{"r", "c", "l", "n", "avg"}).AddRow(0, 0, 0, 0, 0))
+	mock.ExpectQuery(`SELECT taste, SUM\(weight\)`).WillReturnRows(sqlmock.NewRows([]string{"taste", "n"}))
+	mock.ExpectQuery(`SELECT mood, COUNT\(\*\)`).WillReturnRows(sqlmock.NewRows([]string{"mood", "n"}))
+	mock.ExpectQuery(`SELECT c.mood, c.dish_id`).WillReturnRows(sqlmock.NewRows([]string{"mood", "dish_id", "name", "n"}))
+	mock.ExpectExec(`UPDATE users SET meal_count = \?`).WithArgs(0, "", "", "", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO wx_sessions`).WithArgs(5, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO refresh_tokens`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := serve(r, "POST", "/login", `{"code":"alice","nickname":"小明"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		UserID      int    `json:"user_id"`
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 5, resp.UserID)
+	userID, err := tokens.Verify(resp.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, userID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// code 无效
+	w = serve(r, "POST", "/login", `{"code":"invalid"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWxLoginHandler_ExistingUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	server := httptest.NewServer(wechat.NewFakeServer("wxapp", "secret"))
+	defer server.Close()
+	wx := wechat.NewHTTPClient(server.URL, "wxapp", "secret")
+
+	cfg := config.Default()
+	cfg.Auth.TokenKeys = "k1:0123456789abcdef0123456789abcdef"
+	tokens := auth.NewTokens(db, config.Static(cfg))
+	keys, _ := NewSessionKeys(db, "0123456789abcdef0123456789abcdef")
+
+	r := gin.New()
+	r.Use(sessions.Sessions("test", cookie.NewStore([]byte("test-secret"))))
+	r.POST("/login", WxLoginHandler(db, wx, tokens, keys))
+
+	s := wechat.FakeSession("alice")
+	mock.ExpectQuery(`SELECT id, avatar_url FROM users WHERE openid = \?`).WithArgs(s.OpenID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "avatar_url"}).AddRow(5, "http://test.com/avatar/5-abc-256.jpg"))
+	// 只在没有头像时使用客户端传来的头像地址
+	mock.ExpectExec(`UPDATE users SET nickname = \?, avatar_url = IF\(COALESCE\(avatar_url, ''\) = '', \?, avatar_url\)`).
+		WithArgs("小明", "https://wx.qlogo.cn/a.png", s.UnionID, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	// 重新计算画像失败时返回上次保存的画像，登录照常完成
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM recommend_history`).WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectQuery(`SELECT meal_count, favorite_taste, common_mood, mood_food FROM users WHERE id = \?`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"meal_count", "favorite_taste", "common_mood", "mood_food"}).
+			AddRow(4, "麻辣", "开心", nil))
+	mock.ExpectExec(`INSERT INTO wx_sessions`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO refresh_tokens`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := serve(r, "POST", "/login", `{"code":"alice","nickname":"小明","avatar_url":"https://wx.qlogo.cn/a.png"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"avatar":"http://test.com/avatar/5-abc-256.jpg"`)
+	assert.Contains(t, w.Body.String(), `"meal_count":4`)
+	assert.Contains(t, w.Body.String(), `"favorite_taste":"麻辣"`)
+	assert.Contains(t, w.Body.String(), `"mood_food":""`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,18 +1,109 @@
 package user
 
 import (
+	"backend/auth"
+	"backend/profile"
+	"backend/recommend"
 	"database/sql"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 评分范围：0.5 ~ 5 分，步长半星
+const (
+	minRatingScore = 0.5
+	maxRatingScore = 5.0
+	maxReviewRunes = 200 // 短评的最大长度（字）
+)
+
+// 评分列表分页参数
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
 // RatingRequest 定义评分请求结构体
 type RatingRequest struct {
 	UserID int     `json:"user_id"`
 	DishID int     `json:"dish_id"`
 	Score  float64 `json:"score"`
+	Review string  `json:"review"` // 可选的短评
+}
+
+// Rating 一条评分记录
+type Rating struct {
+	UserID  int     `json:"user_id"`
+	DishID  int     `json:"dish_id"`
+	Score   float64 `json:"score"`
+	Review  string  `json:"review"`
+	RatedAt string  `json:"rated_at"`
+
+	// 按菜品查询时返回评分用户，按用户查询时返回菜品
+	Nickname  string `json:"nickname,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	DishName  string `json:"dish_name,omitempty"`
+	DishImage string `json:"dish_image,omitempty"`
+}
+
+// validateRating 校验评分请求，返回给用户的错误信息；同时去掉短评首尾空白
+func validateRating(req *RatingRequest) string {
+	if req.UserID <= 0 || req.DishID <= 0 {
+		return "user_id 和 dish_id 必须为正整数"
+	}
+	if math.IsNaN(req.Score) || req.Score < minRatingScore || req.Score > maxRatingScore {
+		return fmt.Sprintf("评分必须在 %.1f 到 %.0f 之间", minRatingScore, maxRatingScore)
+	}
+	if req.Score*2 != math.Trunc(req.Score*2) {
+		return "评分只能是整星或半星"
+	}
+	req.Review = strings.TrimSpace(req.Review)
+	if utf8.RuneCountInString(req.Review) > maxReviewRunes {
+		return fmt.Sprintf("短评不能超过 %d 个字", maxReviewRunes)
+	}
+	return ""
+}
+
+// checkExists 检查用户和菜品是否存在，不存在时返回对应的错误码和信息
+func checkExists(db *sql.DB, userID, dishID int) (int, string, error) {
+	var exists bool
+	if userID > 0 {
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists); err != nil {
+			return 0, "", err
+		}
+		if !exists {
+			return 4, "用户不存在", nil
+		}
+	}
+	if dishID > 0 {
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM dishes WHERE id = ?)", dishID).Scan(&exists); err != nil {
+			return 0, "", err
+		}
+		if !exists {
+			return 5, "菜品不存在", nil
+		}
+	}
+	return 0, "", nil
+}
+
+// pagination 读取 page、page_size 参数，返回页码、每页数量和偏移量
+func pagination(c *gin.Context) (int, int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	size, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || size < 1 {
+		size = defaultPageSize
+	}
+	size = min(size, maxPageSize)
+	return page, size, (page - 1) * size
 }
 
 // RateDishHandler 用户对菜品评分
@@ -23,19 +114,227 @@ func RateDishHandler(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数绑定失败"})
 			return
 		}
+		userID, ok := auth.CurrentUser(c, req.UserID)
+		if !ok {
+			return
+		}
+		req.UserID = userID
+		if msg := validateRating(&req); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 3, "message": msg})
+			return
+		}
+
+		code, msg, err := checkExists(db, req.UserID, req.DishID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库操作失败"})
+			return
+		} else if code != 0 {
+			c.JSON(http.StatusNotFound, gin.H{"code": code, "message": msg})
+			return
+		}
 
 		// 插入或更新评分
 		stmt := `
-			INSERT INTO dish_ratings (user_id, dish_id, score, rated_at)
-			VALUES (?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE score = VALUES(score), rated_at = VALUES(rated_at)
+			INSERT INTO dish_ratings (user_id, dish_id, score, review, rated_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE score = VALUES(score), review = VALUES(review), rated_at = VALUES(rated_at)
 		`
-		_, err := db.Exec(stmt, req.UserID, req.DishID, req.Score, time.Now())
+		_, err = db.Exec(stmt, req.UserID, req.DishID, req.Score, req.Review, time.Now())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库操作失败"})
 			return
 		}
 
+		// 重新计算菜品评分；失败时评分已保存，由后台批量任务修正
+		if err := recommend.UpdateDishScore(c.Request.Context(), db, req.DishID); err != nil {
+			fmt.Println("更新菜品评分失败:", err)
+		}
+
+		profile.RefreshAsync(db, req.UserID)
+
 		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "评分成功"})
 	}
 }
+
+// GetMyRatingHandler 获取用户对某道菜的评分，没有评过分时 rating 为 null；用户或菜品不存在时返回 404
+func GetMyRatingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dishID, err := strconv.Atoi(c.Query("dish_id"))
+		if err != nil || dishID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "dish_id 无效"})
+			return
+		}
+		claimed, _ := strconv.Atoi(c.Query("user_id"))
+		userID, ok := auth.CurrentUser(c, claimed)
+		if !ok {
+			return
+		}
+		code, msg, err := checkExists(db, userID, dishID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		} else if code != 0 {
+			c.JSON(http.StatusNotFound, gin.H{"code": code, "message": msg})
+			return
+		}
+
+		var r Rating
+		err = db.QueryRow(`
+			SELECT user_id, dish_id, score, review, rated_at
+			FROM dish_ratings WHERE user_id = ? AND dish_id = ?
+		`, userID, dishID).Scan(&r.UserID, &r.DishID, &r.Score, &r.Review, &r.RatedAt)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusOK, gin.H{"code": 0, "rating": nil})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "rating": r})
+	}
+}
+
+// ListDishRatingsHandler 分页获取菜品的评分，按时间从新到旧
+func ListDishRatingsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dishID, err := strconv.Atoi(c.Query("dish_id"))
+		if err != nil || dishID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "dish_id 无效"})
+			return
+		}
+		code, msg, err := checkExists(db, 0, dishID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		} else if code != 0 {
+			c.JSON(http.StatusNotFound, gin.H{"code": code, "message": msg})
+			return
+		}
+
+		// 总数和分页使用相同的 FROM/JOIN，用户已删除的评分两边都不计入
+		const from = `
+			FROM dish_ratings r
+			JOIN users u ON r.user_id = u.id
+			WHERE r.dish_id = ?`
+		page, size, offset := pagination(c)
+		var total int
+		if err := db.QueryRow("SELECT COUNT(*)"+from, dishID).Scan(&total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT r.user_id, r.dish_id, r.score, r.review, r.rated_at, COALESCE(u.nickname, ''), COALESCE(u.avatar_url, '')`+from+`
+			ORDER BY r.rated_at DESC
+			LIMIT ? OFFSET ?
+		`, dishID, size, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		defer rows.Close()
+
+		ratings := []Rating{}
+		for rows.Next() {
+			var r Rating
+			if err := rows.Scan(&r.UserID, &r.DishID, &r.Score, &r.Review, &r.RatedAt, &r.Nickname, &r.AvatarURL); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": "数据转换失败"})
+				return
+			}
+			ratings = append(ratings, r)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "total": total, "page": page, "page_size": size, "ratings": ratings})
+	}
+}
+
+// ListUserRatingsHandler 分页获取用户的评分，按时间从新到旧
+func ListUserRatingsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.Atoi(c.Query("user_id"))
+		if err != nil || userID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "user_id 无效"})
+			return
+		}
+		code, msg, err := checkExists(db, userID, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		} else if code != 0 {
+			c.JSON(http.StatusNotFound, gin.H{"code": code, "message": msg})
+			return
+		}
+
+		// 总数和分页使用相同的 FROM/JOIN，菜品已删除的评分两边都不计入
+		const from = `
+			FROM dish_ratings r
+			JOIN dishes d ON r.dish_id = d.id
+			WHERE r.user_id = ?`
+		page, size, offset := pagination(c)
+		var total int
+		if err := db.QueryRow("SELECT COUNT(*)"+from, userID).Scan(&total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT r.user_id, r.dish_id, r.score, r.review, r.rated_at, d.name, d.image_url`+from+`
+			ORDER BY r.rated_at DESC
+			LIMIT ? OFFSET ?
+		`, userID, size, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库查询失败"})
+			return
+		}
+		defer rows.Close()
+
+		ratings := []Rating{}
+		for rows.Next() {
+			var r Rating
+			if err := rows.Scan(&r.UserID, &r.DishID, &r.Score, &r.Review, &r.RatedAt, &r.DishName, &r.DishImage); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 3, "message": "数据转换失败"})
+				return
+			}
+			ratings = append(ratings, r)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "total": total, "page": page, "page_size": size, "ratings": ratings})
+	}
+}
+
+// DeleteRatingHandler 删除用户对菜品的评分
+func DeleteRatingHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			UserID int `json:"user_id"`
+			DishID int `json:"dish_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.DishID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		userID, ok := auth.CurrentUser(c, req.UserID)
+		if !ok {
+			return
+		}
+
+		res, err := db.Exec("DELETE FROM dish_ratings WHERE user_id = ? AND dish_id = ?", userID, req.DishID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "数据库操作失败"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"code": 3, "message": "评分不存在"})
+			return
+		}
+
+		if err := recommend.UpdateDishScore(c.Request.Context(), db, req.DishID); err != nil {
+			fmt.Println("更新菜品评分失败:", err)
+		}
+
+		profile.RefreshAsync(db, userID)
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "删除成功"})
+	}
+}
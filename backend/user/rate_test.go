@@ -0,0 +1,254 @@
+package user
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidateRating(t *testing.T) {
+	cases := []struct {
+		req RatingRequest
+		ok  bool
+	}{
+		{RatingRequest{UserID: 1, DishID: 2, Score: 5}, true},
+		{RatingRequest{UserID: 1, DishID: 2, Score: 0.5}, true},
+		{RatingRequest{UserID: 1, DishID: 2, Score: 3.5, Review: "  好吃  "}, true},
+		{RatingRequest{UserID: 1, DishID: 2, Score: 0}, false},
+		{RatingRequest{UserID: 1, DishID: 2, Score: -1}, false},
+		{RatingRequest{UserID: 1, DishID: 2, Score: 1000}, false},
+		{RatingRequest{UserID: 1, DishID: 2, Score: 4.3}, false},
+		{RatingRequest{UserID: 0, DishID: 2, Score: 4}, false},
+		{RatingRequest{UserID: 1, DishID: -2, Score: 4}, false},
+		{RatingRequest{UserID: 1, DishID: 2, Score: 4, Review: strings.Repeat("好", maxReviewRunes+1)}, false},
+	}
+	for _, tc := range cases {
+		req := tc.req
+		if msg := validateRating(&req); (msg == "") != tc.ok {
+			t.Errorf("validateRating(%+v) = %q，期望通过: %v", tc.req, msg, tc.ok)
+		}
+	}
+
+	req := RatingRequest{UserID: 1, DishID: 2, Score: 4, Review: "  好吃  "}
+	validateRating(&req)
+	if req.Review != "好吃" {
+		t.Errorf("短评应去掉首尾空白，实际: %q", req.Review)
+	}
+}
+
+func serve(r *gin.Engine, method, url, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateDishHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.POST("/rating", RateDishHandler(db))
+
+	// 1. 评分后重新计算菜品评分
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM users WHERE id = \?\)`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM dishes WHERE id = \?\)`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`INSERT INTO dish_ratings`).
+		WithArgs(1, 2, 4.5, "很下饭", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT base_score FROM dishes WHERE id = \?`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"base_score"}).AddRow(4.0))
+	mock.ExpectQuery(`FROM dish_ratings WHERE dish_id = \?`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(1, 4.5))
+	mock.ExpectExec(`UPDATE dishes SET score = \?, rating_count = \? WHERE id = \?`).
+		WithArgs(4.08, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := serve(r, "POST", "/rating", `{"user_id":1,"dish_id":2,"score":4.5,"review":" 很下饭 "}`)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("评分成功")) {
+		t.Errorf("评分失败，返回: %s", w.Body.String())
+	}
+
+	// 2. 评分超出范围
+	w = serve(r, "POST", "/rating", `{"user_id":1,"dish_id":2,"score":1000}`)
+	if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"code":3`)) {
+		t.Errorf("评分超出范围应返回 400，返回: %s", w.Body.String())
+	}
+
+	// 3. 菜品不存在
+	mock.ExpectQuery(`FROM users WHERE id = \?`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`FROM dishes WHERE id = \?`).WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	w = serve(r, "POST", "/rating", `{"user_id":1,"dish_id":99,"score":4}`)
+	if w.Code != http.StatusNotFound || !bytes.Contains(w.Body.Bytes(), []byte("菜品不存在")) {
+		t.Errorf("菜品不存在应返回 404，返回: %s", w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("SQL 未按预期执行: %v", err)
+	}
+}
+
+func TestGetMyRatingHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/mine", GetMyRatingHandler(db))
+
+	mockExists := func(userID, dishID int, dishExists bool) {
+		mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM users WHERE id = \?\)`).WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM dishes WHERE id = \?\)`).WithArgs(dishID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(dishExists))
+	}
+
+	mockExists(1, 2, true)
+	mock.ExpectQuery(`FROM dish_ratings WHERE user_id = \? AND dish_id = \?`).WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "dish_id", "score", "review", "rated_at"}).
+			AddRow(1, 2, 4.5, "不错", "2024-01-01 12:00:00"))
+	w := serve(r, "GET", "/mine?user_id=1&dish_id=2", "")
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"score":4.5`)) {
+		t.Errorf("获取评分失败，返回: %s", w.Body.String())
+	}
+
+	mockExists(1, 3, true)
+	mock.ExpectQuery(`FROM dish_ratings WHERE user_id = \? AND dish_id = \?`).WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "dish_id", "score", "review", "rated_at"}))
+	w = serve(r, "GET", "/mine?user_id=1&dish_id=3", "")
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"rating":null`)) {
+		t.Errorf("没有评分时应返回 null，返回: %s", w.Body.String())
+	}
+
+	mockExists(1, 99, false)
+	w = serve(r, "GET", "/mine?user_id=1&dish_id=99", "")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "菜品不存在") {
+		t.Errorf("菜品不存在应返回 404，返回: %s", w.Body.String())
+	}
+
+	w = serve(r, "GET", "/mine?user_id=1", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("缺少 dish_id 应返回 400，返回: %s", w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("SQL 未按预期执行: %v", err)
+	}
+}
+
+func TestListDishRatingsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/dish", ListDishRatingsHandler(db))
+
+	mock.ExpectQuery(`FROM dishes WHERE id = \?`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM dish_ratings r\s+JOIN users u ON r.user_id = u.id\s+WHERE r.dish_id = \?`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(`JOIN users u ON r.user_id = u.id`).WithArgs(2, 5, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "dish_id", "score", "review", "rated_at", "nickname", "avatar_url"}).
+			AddRow(3, 2, 5.0, "", "2024-01-01 12:00:00", "小明", "http://a/3.png"))
+
+	w := serve(r, "GET", "/dish?dish_id=2&page=2&page_size=5", "")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `"total":12`) || !strings.Contains(body, "小明") {
+		t.Errorf("菜品评分列表失败，返回: %s", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("SQL 未按预期执行: %v", err)
+	}
+}
+
+func TestListUserRatingsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.GET("/user", ListUserRatingsHandler(db))
+
+	// 用户不存在
+	mock.ExpectQuery(`FROM users WHERE id = \?`).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	w := serve(r, "GET", "/user?user_id=9", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("用户不存在应返回 404，返回: %s", w.Body.String())
+	}
+
+	mock.ExpectQuery(`FROM users WHERE id = \?`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM dish_ratings r\s+JOIN dishes d ON r.dish_id = d.id\s+WHERE r.user_id = \?`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`JOIN dishes d ON r.dish_id = d.id`).WithArgs(1, defaultPageSize, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "dish_id", "score", "review", "rated_at", "name", "image_url"}).
+			AddRow(1, 2, 3.5, "一般", "2024-01-01 12:00:00", "宫保鸡丁", "http://img/2.jpg"))
+	w = serve(r, "GET", "/user?user_id=1", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "宫保鸡丁") {
+		t.Errorf("用户评分列表失败，返回: %s", w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("SQL 未按预期执行: %v", err)
+	}
+}
+
+func TestDeleteRatingHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.POST("/delete", DeleteRatingHandler(db))
+
+	mock.ExpectExec(`DELETE FROM dish_ratings WHERE user_id = \? AND dish_id = \?`).WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT base_score FROM dishes WHERE id = \?`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"base_score"}).AddRow(4.0))
+	mock.ExpectQuery(`FROM dish_ratings WHERE dish_id = \?`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(0, 0.0))
+	mock.ExpectExec(`UPDATE dishes SET score = \?, rating_count = \? WHERE id = \?`).
+		WithArgs(4.0, 0, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w := serve(r, "POST", "/delete", `{"user_id":1,"dish_id":2}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("删除评分失败，返回: %s", w.Body.String())
+	}
+
+	mock.ExpectExec(`DELETE FROM dish_ratings`).WithArgs(1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	w = serve(r, "POST", "/delete", `{"user_id":1,"dish_id":3}`)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("评分不存在应返回 404，返回: %s", w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("SQL 未按预期执行: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package user
+
+import (
+	"log"
+	"net/http"
+
+	"backend/auth"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshTokenHandler 用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效
+func RefreshTokenHandler(tokens *auth.Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "缺少 refresh_token"})
+			return
+		}
+
+		pair, err := tokens.Refresh(c.Request.Context(), req.RefreshToken)
+		switch err {
+		case nil:
+		case auth.ErrInvalidToken, auth.ErrTokenExpired:
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 2, "message": "刷新令牌无效或已过期，请重新登录"})
+			return
+		case auth.ErrTokenReused:
+			log.Printf("⚠️ 刷新令牌被重复使用，已吊销该登录的所有令牌")
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 3, "message": err.Error()})
+			return
+		default:
+			log.Printf("刷新令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 4, "message": "刷新令牌失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code":          0,
+			"access_token":  pair.AccessToken,
+			"token_type":    pair.TokenType,
+			"expires_in":    pair.ExpiresIn,
+			"refresh_token": pair.RefreshToken,
+		})
+	}
+}
+
+// LogoutHandler 退出登录：清除 session，吊销请求中的刷新令牌；
+// all 为 true 时吊销当前用户在所有设备上的刷新令牌
+func LogoutHandler(tokens *auth.Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+			All          bool   `json:"all"`
+		}
+		// 请求体可以为空
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "参数错误"})
+				return
+			}
+		}
+
+		if req.RefreshToken != "" {
+			if err := tokens.Revoke(c.Request.Context(), req.RefreshToken); err != nil {
+				log.Printf("吊销刷新令牌失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "退出登录失败"})
+				return
+			}
+		}
+		if req.All {
+			userID, ok := auth.UserID(c)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": auth.ErrUnauthenticated.Error()})
+				return
+			}
+			if err := tokens.RevokeUser(c.Request.Context(), userID); err != nil {
+				log.Printf("吊销刷新令牌失败: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 2, "message": "退出登录失败"})
+				return
+			}
+		}
+
+		session := sessions.Default(c)
+		session.Clear()
+		session.Options(sessions.Options{Path: "/", MaxAge: -1})
+		session.Save()
+
+		c.JSON(http.StatusOK, gin.H{"code": 0, "message": "已退出登录"})
+	}
+}
@@ -0,0 +1,72 @@
+package user
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"backend/auth"
+	"backend/config"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokenHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	cfg := config.Default()
+	cfg.Auth.TokenKeys = "k1:0123456789abcdef0123456789abcdef"
+	r := gin.New()
+	r.POST("/refresh", RefreshTokenHandler(auth.NewTokens(db, config.Static(cfg))))
+
+	// 缺少 refresh_token
+	w := serve(r, "POST", "/refresh", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 令牌不存在
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM refresh_tokens WHERE token_hash = \?`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "expires_at", "used", "revoked"}))
+	mock.ExpectRollback()
+	w = serve(r, "POST", "/refresh", `{"refresh_token":"nope"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":2`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogoutHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+
+	r := gin.New()
+	r.Use(sessions.Sessions("test", cookie.NewStore([]byte("test-secret"))))
+	r.POST("/logout", LogoutHandler(auth.NewTokens(db, config.Static(config.Default()))))
+
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = \?`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 2))
+	w := serve(r, "POST", "/logout", `{"refresh_token":"abc"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// 吊销所有设备需要登录
+	w = serve(r, "POST", "/logout", `{"all":true}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 没有请求体时只清除 session
+	w = serve(r, "POST", "/logout", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Header().Get("Set-Cookie"), "test="))
+}
@@ -0,0 +1,205 @@
+package user
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errWxDecrypt = errors.New("解密失败")
+
+// SessionKeys 加密保存用户的微信 session_key（AES-256-GCM，用户 ID 作为附加数据，防止记录被挪给其他用户）
+type SessionKeys struct {
+	db   *sql.DB
+	aead cipher.AEAD
+}
+
+// NewSessionKeys 创建 session_key 存储，secret 为配置项 wx.session_key_secret
+func NewSessionKeys(db *sql.DB, secret string) (*SessionKeys, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionKeys{db: db, aead: aead}, nil
+}
+
+// Save 保存用户最新的 session_key
+func (s *SessionKeys) Save(ctx context.Context, userID int, sessionKey string) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(sessionKey), []byte(strconv.Itoa(userID)))
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO wx_sessions (user_id, session_key, updated_at) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE session_key = VALUES(session_key), updated_at = VALUES(updated_at)
+	`, userID, sealed, time.Now())
+	return err
+}
+
+// Load 读取用户的 session_key，没有保存过时返回 sql.ErrNoRows
+func (s *SessionKeys) Load(ctx context.Context, userID int) (string, error) {
+	var sealed []byte
+	if err := s.db.QueryRowContext(ctx, "SELECT session_key FROM wx_sessions WHERE user_id = ?", userID).Scan(&sealed); err != nil {
+		return "", err
+	}
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return "", errors.New("session_key 记录损坏")
+	}
+	plain, err := s.aead.Open(nil, sealed[:n], sealed[n:], []byte(strconv.Itoa(userID)))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// WxUserData 微信 encryptedData 解密后的内容，手机号和用户信息的字段都在其中，按实际返回的类型取用
+type WxUserData struct {
+	PhoneNumber     string `json:"phoneNumber"`     // 带区号的手机号，境外手机号会有区号
+	PurePhoneNumber string `json:"purePhoneNumber"` // 不带区号的手机号
+	CountryCode     string `json:"countryCode"`
+
+	OpenID    string `json:"openId"`
+	UnionID   string `json:"unionId"`
+	NickName  string `json:"nickName"`
+	AvatarURL string `json:"avatarUrl"`
+
+	Watermark struct {
+		AppID     string `json:"appid"`
+		Timestamp int64  `json:"timestamp"`
+	} `json:"watermark"`
+}
+
+// decryptWxData 按微信开放数据的约定解密：AES-128-CBC，密钥为 session_key，PKCS#7 填充，三个参数都是 Base64
+func decryptWxData(sessionKey, encryptedData, iv string) (*WxUserData, error) {
+	key, err1 := base64.StdEncoding.DecodeString(sessionKey)
+	ivBytes, err2 := base64.StdEncoding.DecodeString(iv)
+	data, err3 := base64.StdEncoding.DecodeString(encryptedData)
+	if err1 != nil || err2 != nil || err3 != nil || len(key) != 16 || len(ivBytes) != aes.BlockSize ||
+		len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errWxDecrypt
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errWxDecrypt
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, ivBytes).CryptBlocks(plain, data)
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errWxDecrypt
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errWxDecrypt
+		}
+	}
+
+	var result WxUserData
+	if err := json.Unmarshal(plain[:len(plain)-pad], &result); err != nil {
+		return nil, errWxDecrypt
+	}
+	return &result, nil
+}
+
+// WxDecryptHandler 解密小程序 getPhoneNumber、getUserInfo 等接口返回的 encryptedData 和 iv，
+// 手机号和 unionid 保存到用户信息中
+func WxDecryptHandler(db *sql.DB, appID string, keys *SessionKeys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			UserID        int    `json:"user_id"`
+			EncryptedData string `json:"encrypted_data"`
+			IV            string `json:"iv"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.EncryptedData == "" || req.IV == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "缺少 encrypted_data 或 iv"})
+			return
+		}
+		userID, ok := auth.CurrentUser(c, req.UserID)
+		if !ok {
+			return
+		}
+
+		sessionKey, err := keys.Load(c.Request.Context(), userID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 2, "message": "登录状态已过期，请重新登录"})
+			return
+		} else if err != nil {
+			log.Printf("读取 session_key 失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库查询失败"})
+			return
+		}
+
+		data, err := decryptWxData(sessionKey, req.EncryptedData, req.IV)
+		if err != nil {
+			// session_key 在用户重新调用 wx.login 后会更新，旧数据无法再解密
+			c.JSON(http.StatusBadRequest, gin.H{"code": 3, "message": "解密失败，请重新登录后再试"})
+			return
+		}
+		if data.Watermark.AppID != appID {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4, "message": "数据不属于当前小程序"})
+			return
+		}
+
+		if data.PhoneNumber != "" {
+			if _, err := db.Exec("UPDATE users SET phone_number = ? WHERE id = ?", data.PhoneNumber, userID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库更新失败"})
+				return
+			}
+		}
+		if data.UnionID != "" {
+			var owner int
+			err := db.QueryRow("SELECT id FROM users WHERE unionid = ?", data.UnionID).Scan(&owner)
+			if err == nil && owner != userID {
+				c.JSON(http.StatusConflict, gin.H{"code": 6, "message": "该微信已关联其他账号"})
+				return
+			} else if err != nil && err != sql.ErrNoRows {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库查询失败"})
+				return
+			}
+			if _, err := db.Exec("UPDATE users SET unionid = ? WHERE id = ?", data.UnionID, userID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 5, "message": "数据库更新失败"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code": 0,
+			"data": gin.H{
+				"phone_number":      data.PhoneNumber,
+				"pure_phone_number": data.PurePhoneNumber,
+				"country_code":      data.CountryCode,
+				"unionid":           data.UnionID,
+				"nickname":          data.NickName,
+				"avatar_url":        data.AvatarURL,
+			},
+		})
+	}
+}
+
+// nullIfEmpty 空字符串写入数据库时存为 NULL，避免唯一索引冲突
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,149 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"database/sql/driver"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// encryptWxData 按微信的方式加密，生成测试数据
+func encryptWxData(t *testing.T, key, iv []byte, plain string) string {
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+var (
+	testSessionKey = []byte("0123456789abcdef")
+	testIV         = []byte("fedcba9876543210")
+)
+
+func TestDecryptWxData(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(testSessionKey)
+	iv := base64.StdEncoding.EncodeToString(testIV)
+	encrypted := encryptWxData(t, testSessionKey, testIV,
+		`{"phoneNumber":"13800138000","purePhoneNumber":"13800138000","countryCode":"86","watermark":{"appid":"wxapp","timestamp":1700000000}}`)
+
+	data, err := decryptWxData(key, encrypted, iv)
+	assert.NoError(t, err)
+	assert.Equal(t, "13800138000", data.PhoneNumber)
+	assert.Equal(t, "wxapp", data.Watermark.AppID)
+
+	// session_key 不对时填充校验或 JSON 解析失败
+	wrong := base64.StdEncoding.EncodeToString([]byte("aaaaaaaaaaaaaaaa"))
+	_, err = decryptWxData(wrong, encrypted, iv)
+	assert.Equal(t, errWxDecrypt, err)
+
+	for _, tc := range [][3]string{
+		{"not-base64", encrypted, iv},
+		{key, "YWJj", iv},        // 长度不是块大小的整数倍
+		{key, encrypted, "YWJj"}, // iv 长度不对
+	} {
+		_, err := decryptWxData(tc[0], tc[1], tc[2])
+		assert.Equal(t, errWxDecrypt, err)
+	}
+}
+
+// captureArg 记录写入数据库的参数
+type captureArg struct{ value *[]byte }
+
+func (c captureArg) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	*c.value = b
+	return ok
+}
+
+func TestSessionKeys_SaveLoad(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+	keys, err := NewSessionKeys(db, "0123456789abcdef0123456789abcdef")
+	assert.NoError(t, err)
+
+	var sealed []byte
+	mock.ExpectExec(`INSERT INTO wx_sessions`).WithArgs(7, captureArg{&sealed}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.NoError(t, keys.Save(context.Background(), 7, "secret-session-key"))
+	assert.NotContains(t, string(sealed), "secret-session-key")
+
+	mock.ExpectQuery(`SELECT session_key FROM wx_sessions WHERE user_id = \?`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"session_key"}).AddRow(sealed))
+	got, err := keys.Load(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-session-key", got)
+
+	// 记录被挪到其他用户名下时无法解密
+	mock.ExpectQuery(`SELECT session_key FROM wx_sessions WHERE user_id = \?`).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"session_key"}).AddRow(sealed))
+	_, err = keys.Load(context.Background(), 8)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWxDecryptHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db失败: %v", err)
+	}
+	defer db.Close()
+	keys, _ := NewSessionKeys(db, "0123456789abcdef0123456789abcdef")
+
+	var sealed []byte
+	mock.ExpectExec(`INSERT INTO wx_sessions`).WithArgs(1, captureArg{&sealed}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	keys.Save(context.Background(), 1, base64.StdEncoding.EncodeToString(testSessionKey))
+
+	r := gin.New()
+	r.POST("/decrypt", WxDecryptHandler(db, "wxapp", keys))
+	body := func(appID string) string {
+		encrypted := encryptWxData(t, testSessionKey, testIV,
+			`{"phoneNumber":"+85261234567","purePhoneNumber":"61234567","countryCode":"852","watermark":{"appid":"`+appID+`"}}`)
+		return `{"user_id":1,"encrypted_data":"` + encrypted + `","iv":"` + base64.StdEncoding.EncodeToString(testIV) + `"}`
+	}
+	expectKey := func() {
+		mock.ExpectQuery(`SELECT session_key FROM wx_sessions`).WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"session_key"}).AddRow(sealed))
+	}
+
+	// 解密手机号并保存
+	expectKey()
+	mock.ExpectExec(`UPDATE users SET phone_number = \? WHERE id = \?`).WithArgs("+85261234567", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w := serve(r, "POST", "/decrypt", body("wxapp"))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"pure_phone_number":"61234567"`)
+
+	// 其他小程序的数据
+	expectKey()
+	w = serve(r, "POST", "/decrypt", body("other"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":4`)
+
+	// 没有保存过 session_key
+	mock.ExpectQuery(`SELECT session_key FROM wx_sessions`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"session_key"}))
+	w = serve(r, "POST", "/decrypt", body("wxapp"))
+	assert.Contains(t, w.Body.String(), `"code":2`)
+
+	// 缺少参数
+	w = serve(r, "POST", "/decrypt", `{"user_id":1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
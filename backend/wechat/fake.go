@@ -0,0 +1,174 @@
+package wechat
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// FakeServer 本地模拟的微信接口，行为是确定性的，用于测试和离线开发：
+//
+//   - jscode2session：code 为 "invalid" 时返回 40029，否则返回由 code 推导的 openid、unionid 和 session_key，
+//     同一个 code 总是得到同一个用户；Sessions 中预设的 code 优先
+//   - access_token 有效期 2 小时，只有最新签发的一个有效，ExpireToken 可以让它立即失效
+//   - 内容安全检测命中 BlockedWords 时返回 risky
+//   - FailNext 让接下来的若干个请求返回 500，用于测试重试
+type FakeServer struct {
+	AppID        string
+	AppSecret    string
+	Sessions     map[string]Session // 预设的 code 对应的登录结果
+	BlockedWords []string
+
+	mu            sync.Mutex
+	token         string
+	tokenRequests int
+	failNext      int
+	sent          []SubscribeMessage
+}
+
+// NewFakeServer 创建 FakeServer，只接受给定的 appID 和 appSecret
+func NewFakeServer(appID, appSecret string) *FakeServer {
+	return &FakeServer{AppID: appID, AppSecret: appSecret, Sessions: map[string]Session{}}
+}
+
+// FakeSession 未预设时 code 对应的登录结果
+func FakeSession(code string) Session {
+	sum := sha256.Sum256([]byte(code))
+	return Session{
+		OpenID:     "fake-openid-" + code,
+		UnionID:    "fake-unionid-" + code,
+		SessionKey: base64.StdEncoding.EncodeToString(sum[:16]),
+	}
+}
+
+// Sent 已发送的订阅消息
+func (f *FakeServer) Sent() []SubscribeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SubscribeMessage(nil), f.sent...)
+}
+
+// TokenRequests 获取 access_token 的次数
+func (f *FakeServer) TokenRequests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tokenRequests
+}
+
+// ExpireToken 让当前的 access_token 失效
+func (f *FakeServer) ExpireToken() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.token = ""
+}
+
+// FailNext 接下来的 n 个请求返回 500
+func (f *FakeServer) FailNext(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext = n
+}
+
+// ServeHTTP 实现 http.Handler
+func (f *FakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failNext > 0 {
+		f.failNext--
+		http.Error(w, "fake failure", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/sns/jscode2session":
+		if q.Get("appid") != f.AppID || q.Get("secret") != f.AppSecret {
+			writeFakeError(w, 40125, "invalid appsecret")
+			return
+		}
+		code := q.Get("js_code")
+		if code == "" || code == "invalid" {
+			writeFakeError(w, 40029, "invalid code")
+			return
+		}
+		s, ok := f.Sessions[code]
+		if !ok {
+			s = FakeSession(code)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"openid": s.OpenID, "session_key": s.SessionKey, "unionid": s.UnionID})
+
+	case "/cgi-bin/token":
+		if q.Get("appid") != f.AppID || q.Get("secret") != f.AppSecret {
+			writeFakeError(w, 40125, "invalid appsecret")
+			return
+		}
+		f.tokenRequests++
+		f.token = fmt.Sprintf("fake-token-%d", f.tokenRequests)
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": f.token, "expires_in": 7200})
+
+	case "/cgi-bin/message/subscribe/send":
+		if !f.checkToken(w, q.Get("access_token")) {
+			return
+		}
+		var body struct {
+			ToUser     string `json:"touser"`
+			TemplateID string `json:"template_id"`
+			Page       string `json:"page"`
+			Data       map[string]struct {
+				Value string `json:"value"`
+			} `json:"data"`
+			State string `json:"miniprogram_state"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ToUser == "" || body.TemplateID == "" {
+			writeFakeError(w, 47003, "argument invalid")
+			return
+		}
+		msg := SubscribeMessage{ToUser: body.ToUser, TemplateID: body.TemplateID, Page: body.Page, State: body.State, Data: map[string]string{}}
+		for k, v := range body.Data {
+			msg.Data[k] = v.Value
+		}
+		f.sent = append(f.sent, msg)
+		writeFakeError(w, 0, "ok")
+
+	case "/wxa/msg_sec_check":
+		if !f.checkToken(w, q.Get("access_token")) {
+			return
+		}
+		var body struct {
+			Content string `json:"content"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		suggest, label := SuggestPass, 100
+		for _, word := range f.BlockedWords {
+			if word != "" && strings.Contains(body.Content, word) {
+				suggest, label = SuggestRisky, 20006
+				break
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errcode": 0, "errmsg": "ok",
+			"result": map[string]interface{}{"suggest": suggest, "label": label},
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// checkToken 校验 access_token，无效时写入 40001
+func (f *FakeServer) checkToken(w http.ResponseWriter, token string) bool {
+	if token == "" || token != f.token {
+		writeFakeError(w, 40001, "invalid credential, access_token is invalid or not latest")
+		return false
+	}
+	return true
+}
+
+func writeFakeError(w http.ResponseWriter, code int, msg string) {
+	json.NewEncoder(w).Encode(map[string]interface{}{"errcode": code, "errmsg": msg})
+}
@@ -0,0 +1,15 @@
+//go:build dev
+
+package wechat
+
+import (
+	"net/http/httptest"
+
+	"backend/config"
+)
+
+// startFake 在本地启动 FakeServer，返回它的地址和关闭函数
+func startFake(cfg config.WxConfig) (string, func(), error) {
+	server := httptest.NewServer(NewFakeServer(cfg.AppID, cfg.AppSecret))
+	return server.URL, server.Close, nil
+}
@@ -0,0 +1,23 @@
+//go:build dev
+
+package wechat
+
+import (
+	"context"
+	"testing"
+
+	"backend/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Fake(t *testing.T) {
+	c, stop, err := New(config.WxConfig{BaseURL: FakeBaseURL, AppID: "wxapp", AppSecret: "secret"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stop()
+	s, err := c.Code2Session(context.Background(), "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, FakeSession("alice"), *s)
+}
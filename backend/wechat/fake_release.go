@@ -0,0 +1,14 @@
+//go:build !dev
+
+package wechat
+
+import (
+	"errors"
+
+	"backend/config"
+)
+
+// startFake 正式版本不包含模拟服务：FakeServer 让任意 code 都能登录，线上启用等于允许冒充任何用户
+func startFake(config.WxConfig) (string, func(), error) {
+	return "", nil, errors.New("wx.base_url 为 fake 时需要使用 go build -tags dev 编译的开发版本")
+}
@@ -0,0 +1,16 @@
+//go:build !dev
+
+package wechat
+
+import (
+	"testing"
+
+	"backend/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_FakeRequiresDevBuild(t *testing.T) {
+	_, _, err := New(config.WxConfig{BaseURL: FakeBaseURL, AppID: "wxapp", AppSecret: "secret"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,293 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPClient 调用微信接口的 Client：请求带超时，幂等的接口在网络错误、5xx 和系统繁忙时重试，
+// access_token 缓存到过期前 tokenRefreshMargin，失效时自动刷新一次
+type HTTPClient struct {
+	BaseURL    string
+	AppID      string
+	AppSecret  string
+	HTTP       *http.Client
+	MaxRetries int           // 失败后的重试次数
+	RetryDelay time.Duration // 第一次重试前的等待时间，之后每次翻倍
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	now       func() time.Time
+}
+
+// 默认参数
+const (
+	defaultTimeout     = 5 * time.Second
+	defaultMaxRetries  = 2
+	defaultRetryDelay  = 200 * time.Millisecond
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// 需要特殊处理的微信错误码
+const (
+	errCodeBusy         = -1    // 系统繁忙，稍后重试
+	errCodeInvalidToken = 40001 // access_token 无效
+	errCodeTokenExpired = 42001 // access_token 过期
+)
+
+// NewHTTPClient 创建 Client，baseURL 为空时使用 DefaultBaseURL
+func NewHTTPClient(baseURL, appID, appSecret string) *HTTPClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &HTTPClient{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		AppID:      appID,
+		AppSecret:  appSecret,
+		HTTP:       &http.Client{Timeout: defaultTimeout},
+		MaxRetries: defaultMaxRetries,
+		RetryDelay: defaultRetryDelay,
+		now:        time.Now,
+	}
+}
+
+// apiResponse 微信接口通用的错误字段
+type apiResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (r apiResponse) err() error {
+	if r.ErrCode == 0 {
+		return nil
+	}
+	return &APIError{Code: r.ErrCode, Msg: r.ErrMsg}
+}
+
+// errorer 所有响应结构都内嵌 apiResponse
+type errorer interface {
+	err() error
+}
+
+// retryable 网络错误、5xx 和系统繁忙可以重试；其他业务错误重试也不会成功，
+// 响应解析失败说明微信已经处理了请求，也不重试
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == errCodeBusy
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= 500
+	}
+	var netErr *transportError
+	if errors.As(err, &netErr) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return false
+}
+
+// transportError 请求没有得到 HTTP 响应，如连接失败或超时
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return fmt.Sprintf("请求微信接口失败: %v", e.err)
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// statusError 非 200 的 HTTP 状态
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("微信接口返回错误状态 %d: %s", e.code, e.body)
+}
+
+// call 请求微信接口并解析响应，body 为 nil 时发送 GET。
+// idempotent 为 false 的接口（如发送订阅消息）不重试：超时的请求可能已经被微信处理，重试会重复发送
+func (c *HTTPClient) call(ctx context.Context, path string, query url.Values, body interface{}, out errorer, idempotent bool) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	delay := c.RetryDelay
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = c.do(ctx, path, query, payload, out)
+		if err == nil || !idempotent || attempt >= c.MaxRetries || !retryable(err) {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+func (c *HTTPClient) do(ctx context.Context, path string, query url.Values, payload []byte, out errorer) error {
+	method, reader := http.MethodGet, io.Reader(nil)
+	if payload != nil {
+		method, reader = http.MethodPost, bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path+"?"+query.Encode(), reader)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return &transportError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("微信响应解析失败: %v", err)
+	}
+	return out.err()
+}
+
+// callWithToken 调用需要 access_token 的接口，token 失效时刷新后再试一次（微信没有处理请求，可以安全重试）
+func (c *HTTPClient) callWithToken(ctx context.Context, path string, body interface{}, out errorer, idempotent bool) error {
+	for attempt := 0; ; attempt++ {
+		token, err := c.AccessToken(ctx)
+		if err != nil {
+			return err
+		}
+		err = c.call(ctx, path, url.Values{"access_token": {token}}, body, out, idempotent)
+		var apiErr *APIError
+		if attempt == 0 && errors.As(err, &apiErr) &&
+			(apiErr.Code == errCodeInvalidToken || apiErr.Code == errCodeTokenExpired) {
+			c.invalidateToken(token)
+			continue
+		}
+		return err
+	}
+}
+
+// Code2Session 实现 Client
+func (c *HTTPClient) Code2Session(ctx context.Context, code string) (*Session, error) {
+	var resp struct {
+		apiResponse
+		OpenID     string `json:"openid"`
+		SessionKey string `json:"session_key"`
+		UnionID    string `json:"unionid"`
+	}
+	err := c.call(ctx, "/sns/jscode2session", url.Values{
+		"appid":      {c.AppID},
+		"secret":     {c.AppSecret},
+		"js_code":    {code},
+		"grant_type": {"authorization_code"},
+	}, nil, &resp, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{OpenID: resp.OpenID, SessionKey: resp.SessionKey, UnionID: resp.UnionID}, nil
+}
+
+// AccessToken 实现 Client。同一时间只有一个请求去刷新，其余等待并复用结果
+func (c *HTTPClient) AccessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && c.now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	var resp struct {
+		apiResponse
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err := c.call(ctx, "/cgi-bin/token", url.Values{
+		"grant_type": {"client_credential"},
+		"appid":      {c.AppID},
+		"secret":     {c.AppSecret},
+	}, nil, &resp, true)
+	if err != nil {
+		return "", err
+	}
+	c.token = resp.AccessToken
+	c.expiresAt = c.now().Add(time.Duration(resp.ExpiresIn)*time.Second - tokenRefreshMargin)
+	return c.token, nil
+}
+
+// invalidateToken 丢弃失效的 token；缓存已经被其他请求刷新时不处理
+func (c *HTTPClient) invalidateToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// SendSubscribeMessage 实现 Client
+func (c *HTTPClient) SendSubscribeMessage(ctx context.Context, msg SubscribeMessage) error {
+	type value struct {
+		Value string `json:"value"`
+	}
+	data := make(map[string]value, len(msg.Data))
+	for k, v := range msg.Data {
+		data[k] = value{v}
+	}
+	body := struct {
+		ToUser     string           `json:"touser"`
+		TemplateID string           `json:"template_id"`
+		Page       string           `json:"page,omitempty"`
+		Data       map[string]value `json:"data"`
+		State      string           `json:"miniprogram_state,omitempty"`
+		Lang       string           `json:"lang"`
+	}{msg.ToUser, msg.TemplateID, msg.Page, data, msg.State, "zh_CN"}
+
+	var resp apiResponse
+	return c.callWithToken(ctx, "/cgi-bin/message/subscribe/send", body, &resp, false)
+}
+
+// CheckText 实现 Client
+func (c *HTTPClient) CheckText(ctx context.Context, openID, content string, scene int) (*SecurityResult, error) {
+	body := struct {
+		Content string `json:"content"`
+		Version int    `json:"version"`
+		Scene   int    `json:"scene"`
+		OpenID  string `json:"openid"`
+	}{content, 2, scene, openID}
+
+	var resp struct {
+		apiResponse
+		Result struct {
+			Suggest string `json:"suggest"`
+			Label   int    `json:"label"`
+		} `json:"result"`
+	}
+	if err := c.callWithToken(ctx, "/wxa/msg_sec_check", body, &resp, true); err != nil {
+		return nil, err
+	}
+	return &SecurityResult{Suggest: resp.Result.Suggest, Label: resp.Result.Label}, nil
+}
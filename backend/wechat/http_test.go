@@ -0,0 +1,133 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestClient 创建指向 FakeServer 的 Client，重试间隔缩短以加快测试
+func newTestClient(t *testing.T) (*HTTPClient, *FakeServer) {
+	fake := NewFakeServer("wxapp", "secret")
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	c := NewHTTPClient(server.URL, "wxapp", "secret")
+	c.RetryDelay = time.Millisecond
+	return c, fake
+}
+
+func TestHTTPClient_Code2Session(t *testing.T) {
+	c, fake := newTestClient(t)
+	ctx := context.Background()
+
+	s, err := c.Code2Session(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, FakeSession("alice"), *s)
+
+	fake.Sessions["preset"] = Session{OpenID: "o1", SessionKey: "k1"}
+	s, err = c.Code2Session(ctx, "preset")
+	assert.NoError(t, err)
+	assert.Equal(t, "o1", s.OpenID)
+
+	// 业务错误不重试
+	_, err = c.Code2Session(ctx, "invalid")
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, 40029, apiErr.Code)
+	}
+
+	// 密钥错误
+	bad := NewHTTPClient(c.BaseURL, "wxapp", "wrong")
+	_, err = bad.Code2Session(ctx, "alice")
+	assert.Error(t, err)
+}
+
+func TestHTTPClient_Retry(t *testing.T) {
+	c, fake := newTestClient(t)
+
+	// 连续两次 500 后成功
+	fake.FailNext(2)
+	_, err := c.Code2Session(context.Background(), "alice")
+	assert.NoError(t, err)
+
+	// 超过重试次数
+	fake.FailNext(c.MaxRetries + 1)
+	_, err = c.Code2Session(context.Background(), "alice")
+	assert.Error(t, err)
+
+	// 发送订阅消息不是幂等的，失败后不重试，避免重复发送
+	_, err = c.AccessToken(context.Background())
+	assert.NoError(t, err)
+	fake.FailNext(1)
+	err = c.SendSubscribeMessage(context.Background(), SubscribeMessage{ToUser: "o1", TemplateID: "tpl"})
+	assert.Error(t, err)
+	assert.Empty(t, fake.Sent())
+	assert.NoError(t, c.SendSubscribeMessage(context.Background(), SubscribeMessage{ToUser: "o1", TemplateID: "tpl"}))
+	assert.Len(t, fake.Sent(), 1)
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, retryable(&transportError{errors.New("connection reset")}))
+	assert.True(t, retryable(&statusError{code: 502}))
+	assert.True(t, retryable(&APIError{Code: errCodeBusy}))
+	assert.False(t, retryable(&transportError{context.Canceled}))
+	assert.False(t, retryable(&statusError{code: 400}))
+	assert.False(t, retryable(&APIError{Code: 40029}))
+	assert.False(t, retryable(errors.New("微信响应解析失败: unexpected EOF")), "响应解析失败不重试")
+}
+
+func TestHTTPClient_AccessTokenCache(t *testing.T) {
+	c, fake := newTestClient(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	t1, err := c.AccessToken(ctx)
+	assert.NoError(t, err)
+	t2, _ := c.AccessToken(ctx)
+	assert.Equal(t, t1, t2)
+	assert.Equal(t, 1, fake.TokenRequests())
+
+	// 临近过期时提前刷新
+	now = now.Add(2*time.Hour - tokenRefreshMargin)
+	t3, _ := c.AccessToken(ctx)
+	assert.NotEqual(t, t1, t3)
+	assert.Equal(t, 2, fake.TokenRequests())
+
+	// 服务端让 token 失效后，调用接口时自动刷新并重试
+	fake.ExpireToken()
+	err = c.SendSubscribeMessage(ctx, SubscribeMessage{ToUser: "o1", TemplateID: "tpl", Data: map[string]string{"thing1": "麻婆豆腐"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fake.TokenRequests())
+	if sent := fake.Sent(); assert.Len(t, sent, 1) {
+		assert.Equal(t, "麻婆豆腐", sent[0].Data["thing1"])
+	}
+}
+
+func TestHTTPClient_CheckText(t *testing.T) {
+	c, fake := newTestClient(t)
+	fake.BlockedWords = []string{"赌博"}
+
+	r, err := c.CheckText(context.Background(), "o1", "这家的水煮鱼很好吃", SceneComment)
+	assert.NoError(t, err)
+	assert.True(t, r.Pass())
+
+	r, err = c.CheckText(context.Background(), "o1", "楼下有人赌博", SceneComment)
+	assert.NoError(t, err)
+	assert.False(t, r.Pass())
+	assert.Equal(t, SuggestRisky, r.Suggest)
+}
+
+func TestNew(t *testing.T) {
+	c, stop, err := New(config.WxConfig{AppID: "wxapp", AppSecret: "secret"})
+	assert.NoError(t, err)
+	stop()
+	assert.Equal(t, DefaultBaseURL, c.(*HTTPClient).BaseURL)
+}
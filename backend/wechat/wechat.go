@@ -0,0 +1,95 @@
+// Package wechat 封装微信小程序服务端接口。业务代码只依赖 Client 接口，
+// 线上使用 HTTPClient，测试和本地开发可以把 HTTPClient 指向 FakeServer。
+package wechat
+
+import (
+	"context"
+	"fmt"
+
+	"backend/config"
+)
+
+// DefaultBaseURL 微信接口地址
+const DefaultBaseURL = "https://api.weixin.qq.com"
+
+// FakeBaseURL 配置 wx.base_url 为该值时在本地启动 FakeServer，不访问微信。
+// FakeServer 接受任意 code，只有使用 -tags dev 编译的开发版本才允许启用
+const FakeBaseURL = "fake"
+
+// Session code2session 的结果
+type Session struct {
+	OpenID     string
+	SessionKey string
+	UnionID    string // 小程序绑定到微信开放平台后才会返回
+}
+
+// SubscribeMessage 订阅消息
+type SubscribeMessage struct {
+	ToUser     string            // 接收者 openid
+	TemplateID string            // 订阅消息模板 ID
+	Page       string            // 点击消息后跳转的小程序页面，可为空
+	Data       map[string]string // 模板字段，如 {"thing1": "今日推荐：麻婆豆腐"}
+	State      string            // developer、trial 或 formal，为空时为正式版
+}
+
+// 内容安全检测的场景值
+const (
+	SceneProfile = 1 // 资料
+	SceneComment = 2 // 评论
+	SceneForum   = 3 // 论坛
+	SceneSocial  = 4 // 社交日志
+)
+
+// 内容安全检测建议
+const (
+	SuggestPass   = "pass"
+	SuggestReview = "review"
+	SuggestRisky  = "risky"
+)
+
+// SecurityResult 内容安全检测结果
+type SecurityResult struct {
+	Suggest string // pass、review 或 risky
+	Label   int    // 命中的标签，100 为正常
+}
+
+// Pass 内容可以直接发布
+func (r SecurityResult) Pass() bool {
+	return r.Suggest == SuggestPass
+}
+
+// Client 微信小程序服务端接口
+type Client interface {
+	// Code2Session 用 wx.login 得到的 code 换取 openid、session_key 和 unionid
+	Code2Session(ctx context.Context, code string) (*Session, error)
+	// AccessToken 获取接口调用凭证，实现方负责缓存和刷新
+	AccessToken(ctx context.Context) (string, error)
+	// SendSubscribeMessage 发送订阅消息
+	SendSubscribeMessage(ctx context.Context, msg SubscribeMessage) error
+	// CheckText 文本内容安全检测（msg_sec_check 2.0），openID 为发布内容的用户
+	CheckText(ctx context.Context, openID, content string, scene int) (*SecurityResult, error)
+}
+
+// APIError 微信接口返回的业务错误
+type APIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("微信返回错误 %d: %s", e.Code, e.Msg)
+}
+
+// New 根据配置创建 Client。wx.base_url 为 fake 时在本地启动 FakeServer（不是开发版本时返回错误），
+// 返回的 stop 用于关闭它；其他情况下 stop 什么也不做
+func New(cfg config.WxConfig) (Client, func(), error) {
+	baseURL := cfg.BaseURL
+	stop := func() {}
+	if baseURL == FakeBaseURL {
+		var err error
+		if baseURL, stop, err = startFake(cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+	return NewHTTPClient(baseURL, cfg.AppID, cfg.AppSecret), stop, nil
+}